@@ -0,0 +1,26 @@
+package api
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin endpoints require "Authorization: Bearer <token>"; without a configured token they are off
+func requireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Admin API disabled, set http.api_token"})
+			return
+		}
+
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid or missing API token"})
+			return
+		}
+
+		c.Next()
+	}
+}
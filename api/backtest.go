@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	suricata "firefighter/core"
+	"firefighter/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Candidate policy; omitted parts are taken from the live policy
+type backtestRequest struct {
+	// Merged over the live scoring model field by field
+	Scoring  json.RawMessage          `json:"scoring"`
+	Suppress *[]suricata.SuppressRule `json:"suppress"`
+	Monitor  *bool                    `json:"monitor"`
+	Window   string                   `json:"window"` // e.g. "10m"
+	// Alert range (RFC 3339), zero = unbounded
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Replaying stored alerts under a candidate policy and diffing with blocked_ips
+func runBacktest(db data.Repository, wm *suricata.WindowManager, escalation suricata.EscalationPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req backtestRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid JSON: " + err.Error()})
+			return
+		}
+
+		live := wm.Policy()
+
+		// Round trip through JSON so the candidate never shares maps with the live policy
+		var scoring suricata.ScoringModel
+		raw, _ := json.Marshal(live.Scoring)
+		_ = json.Unmarshal(raw, &scoring)
+		if len(req.Scoring) > 0 {
+			if err := json.Unmarshal(req.Scoring, &scoring); err != nil {
+				c.JSON(400, gin.H{"error": "Invalid scoring: " + err.Error()})
+				return
+			}
+		}
+
+		suppress, monitor := live.Suppress, live.Monitor
+		if req.Suppress != nil {
+			suppress = *req.Suppress
+		}
+		if req.Monitor != nil {
+			monitor = *req.Monitor
+		}
+
+		policy, err := suricata.NewPolicy(scoring, suppress, monitor)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		window := wm.Duration
+		if req.Window != "" {
+			window, err = time.ParseDuration(req.Window)
+			if err != nil || window <= 0 {
+				c.JSON(400, gin.H{"error": "window must be a positive duration"})
+				return
+			}
+		}
+
+		bt := &suricata.Backtest{
+			Policy:     policy,
+			Window:     window,
+			IPv6Prefix: wm.IPv6Prefix,
+			Escalation: escalation,
+			Whitelist:  wm.Whitelist,
+			From:       req.From,
+			To:         req.To,
+		}
+		report, err := bt.Run(c.Request.Context(), db)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Backtest failed: " + err.Error()})
+			return
+		}
+		c.JSON(200, report)
+	}
+}
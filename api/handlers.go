@@ -1,17 +1,21 @@
 package api
 
 import (
+	"context"
 	suricata "firefighter/core"
 	"firefighter/data"
+	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func getBlocked(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ips, err := db.GetBlocked()
+		ips, err := db.GetBlocked(c.Request.Context())
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -20,23 +24,24 @@ func getBlocked(db data.Repository) gin.HandlerFunc {
 	}
 }
 
-func unblockIP(db data.Repository, wm *suricata.WindowManager) gin.HandlerFunc {
+func unblockIP(db data.Repository, wm *suricata.WindowManager, enforcer suricata.Enforcer) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.Param("ip")
+		ip := data.CanonicalIP(strings.TrimPrefix(c.Param("ip"), "/"))
 
-		if err := suricata.UnblockIP(ip); err != nil {
+		if err := enforcer.Unblock(ip); err != nil {
 			c.JSON(500, gin.H{"error": "Failed to unblock in firewall: " + err.Error()})
 			return
 		}
 
-		if err := db.UnblockIP(ip); err != nil {
+		// The firewall rule is already gone, finish even if the client left
+		if err := db.UnblockIP(context.WithoutCancel(c.Request.Context()), ip); err != nil {
 			c.JSON(500, gin.H{"error": "Failed to remove from database: " + err.Error()})
 			return
 		}
 
 		wm.RemoveIP(ip)
 
-		BroadcastUnblock(ip)
+		BroadcastUnblock(ip, "Manually unblocked")
 
 		c.JSON(200, gin.H{"message": "IP unblocked successfully"})
 	}
@@ -44,7 +49,7 @@ func unblockIP(db data.Repository, wm *suricata.WindowManager) gin.HandlerFunc {
 
 func getWhitelisted(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ips, err := db.GetWhitelistDetails()
+		ips, err := db.GetWhitelistDetails(c.Request.Context())
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve whitelisted IPs"})
 			return
@@ -53,41 +58,67 @@ func getWhitelisted(db data.Repository) gin.HandlerFunc {
 	}
 }
 
-func addToWhitelist(db data.Repository) gin.HandlerFunc {
+func addToWhitelist(db data.Repository, whitelist *suricata.Whitelist) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.Param("ip")
+		ip := strings.TrimPrefix(c.Param("ip"), "/")
 
 		var req struct {
 			Description string `json:"description"`
+			ExpiresAt   int64  `json:"expires_at"` // unix seconds
+			ExpiresIn   string `json:"expires_in"` // e.g. "72h"
 		}
 
 		if err := c.BindJSON(&req); err != nil {
 			req.Description = ""
 		}
 
-		if err := db.AddToWhitelist(ip, req.Description); err != nil {
+		if _, _, err := data.ParseWhitelistEntry(ip); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		expiresAt := req.ExpiresAt
+		if req.ExpiresIn != "" {
+			d, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || d <= 0 {
+				c.JSON(400, gin.H{"error": "Invalid expires_in duration"})
+				return
+			}
+			expiresAt = time.Now().Add(d).Unix()
+		}
+
+		if err := db.AddToWhitelist(c.Request.Context(), ip, req.Description, expiresAt); err != nil {
 			c.JSON(500, gin.H{"error": "Failed to add IP to whitelist"})
 			return
 		}
 
+		if err := whitelist.Load(context.WithoutCancel(c.Request.Context()), db); err != nil {
+			log.Printf("Whitelist reload error: %v", err)
+		}
+
 		c.JSON(200, gin.H{"status": "IP added to whitelist"})
 	}
 }
 
-func removeFromWhitelist(db data.Repository) gin.HandlerFunc {
+func removeFromWhitelist(db data.Repository, whitelist *suricata.Whitelist) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.Param("ip")
-		if err := db.RemoveFromWhitelist(ip); err != nil {
+		ip := strings.TrimPrefix(c.Param("ip"), "/")
+		if err := db.RemoveFromWhitelist(c.Request.Context(), ip); err != nil {
 			c.JSON(500, gin.H{"error": "Failed to remove IP from whitelist"})
 			return
 		}
+
+		if err := whitelist.Load(context.WithoutCancel(c.Request.Context()), db); err != nil {
+			log.Printf("Whitelist reload error: %v", err)
+		}
+
 		c.JSON(200, gin.H{"status": "IP removed from whitelist"})
 	}
 }
 
 func getStats(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		stats, err := db.GetStats()
+		stats, err := db.GetStats(c.Request.Context())
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve stats"})
 			return
@@ -100,7 +131,7 @@ func getHourlyAlerts(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		daysStr := c.DefaultQuery("days", "7")
 		days, _ := strconv.Atoi(daysStr)
-		data, err := db.GetHourlyAlerts(days)
+		data, err := db.GetHourlyAlerts(c.Request.Context(), days)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve hourly data"})
 			return
@@ -113,7 +144,7 @@ func getTopIPs(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		limitStr := c.DefaultQuery("limit", "10")
 		limit, _ := strconv.Atoi(limitStr)
-		data, err := db.GetTopIPs(limit)
+		data, err := db.GetTopIPs(c.Request.Context(), limit)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve top IPs"})
 			return
@@ -126,7 +157,7 @@ func getAlertCategories(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		daysStr := c.DefaultQuery("days", "7")
 		days, _ := strconv.Atoi(daysStr)
-		data, err := db.GetAlertCategories(days)
+		data, err := db.GetAlertCategories(c.Request.Context(), days)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve categories"})
 			return
@@ -144,7 +175,7 @@ func getRecentAlerts(db data.Repository) gin.HandlerFunc {
 			return
 		}
 
-		alerts, err := db.GetRecentAlerts(limit)
+		alerts, err := db.GetRecentAlerts(c.Request.Context(), limit)
 		if err != nil {
 			log.Printf("GetRecentAlerts error: %v", err)
 			c.JSON(500, gin.H{"error": "Failed to retrieve recent alerts"})
@@ -159,7 +190,7 @@ func getAlertBuckets(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		daysStr := c.DefaultQuery("days", "7")
 		days, _ := strconv.Atoi(daysStr)
-		data, err := db.GetAlertBuckets(days)
+		data, err := db.GetAlertBuckets(c.Request.Context(), days)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve alert buckets"})
 			return
@@ -172,7 +203,7 @@ func getBlockBuckets(db data.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		daysStr := c.DefaultQuery("days", "7")
 		days, _ := strconv.Atoi(daysStr)
-		data, err := db.GetBlockBuckets(days)
+		data, err := db.GetBlockBuckets(c.Request.Context(), days)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve block buckets"})
 			return
@@ -189,7 +220,7 @@ func getAlertsByIPQuery(db data.Repository) gin.HandlerFunc {
 			return
 		}
 
-		alerts, err := db.GetAlertsByIP(ip, 100)
+		alerts, err := db.GetAlertsByIP(c.Request.Context(), ip, 100)
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -207,7 +238,7 @@ func getBlockedByIPQuery(db data.Repository) gin.HandlerFunc {
 			return
 		}
 
-		blocks, err := db.GetBlockedByIP(ip)
+		blocks, err := db.GetBlockedByIP(c.Request.Context(), ip)
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -224,7 +255,7 @@ func getActivity(db data.Repository) gin.HandlerFunc {
 		limitStr := c.DefaultQuery("limit", "100")
 		limit, _ := strconv.Atoi(limitStr)
 
-		activity, err := db.GetActivity(search, typeFilter, limit) // ⬅️ DODAJ typeFilter
+		activity, err := db.GetActivity(c.Request.Context(), search, typeFilter, limit) // ⬅️ DODAJ typeFilter
 		if err != nil {
 			log.Printf("GetActivity error: %v", err)
 			c.JSON(500, gin.H{"error": "Failed to retrieve activity"})
@@ -234,3 +265,133 @@ func getActivity(db data.Repository) gin.HandlerFunc {
 		c.JSON(200, gin.H{"activity": activity})
 	}
 }
+
+// Read-only view of the live scoring model
+func getScoring(wm *suricata.WindowManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"scoring": wm.Policy().Scoring})
+	}
+}
+
+func getPolicy(wm *suricata.WindowManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"policy": wm.Policy()})
+	}
+}
+
+func reloadPolicy(reload func() error, wm *suricata.WindowManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if reload == nil {
+			c.JSON(501, gin.H{"error": "Policy reload not available"})
+			return
+		}
+
+		// Invalid config leaves the running policy untouched
+		if err := reload(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"status": "Policy reloaded", "policy": wm.Policy()})
+	}
+}
+
+func modeStatus(mode *suricata.EnforcementMode, wm *suricata.WindowManager) gin.H {
+	policyMonitor := wm.Policy().Monitor
+	effective := suricata.ModeEnforce
+	if mode.Monitor() || policyMonitor {
+		effective = suricata.ModeMonitor
+	}
+	return gin.H{"mode": mode.String(), "policy_monitor": policyMonitor, "effective": effective}
+}
+
+func getMode(mode *suricata.EnforcementMode, wm *suricata.WindowManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, modeStatus(mode, wm))
+	}
+}
+
+// Switching global mode, e.g. promoting monitor to enforcement
+func setMode(db data.Repository, mode *suricata.EnforcementMode, wm *suricata.WindowManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Mode string `json:"mode"`
+		}
+		if err := c.BindJSON(&req); err != nil || (req.Mode != suricata.ModeEnforce && req.Mode != suricata.ModeMonitor) {
+			c.JSON(400, gin.H{"error": "mode must be \"enforce\" or \"monitor\""})
+			return
+		}
+
+		previous := mode.String()
+		mode.Set(req.Mode)
+		if previous != req.Mode {
+			_ = db.LogActivity(context.WithoutCancel(c.Request.Context()), "mode_change", "", fmt.Sprintf("%s -> %s", previous, req.Mode), "")
+		}
+
+		c.JSON(200, modeStatus(mode, wm))
+	}
+}
+
+// Live sliding windows, highest score first
+func getWindows(wm *suricata.WindowManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limitStr := c.DefaultQuery("limit", "100")
+		limit, _ := strconv.Atoi(limitStr)
+
+		windows := wm.Snapshot()
+		total := len(windows)
+		if limit > 0 && len(windows) > limit {
+			windows = windows[:limit]
+		}
+
+		c.JSON(200, gin.H{
+			"windows":      windows,
+			"total":        total,
+			"event_time":   wm.EventTime(),
+			"late_dropped": wm.LateDropped(),
+		})
+	}
+}
+
+// EVE records received per event type
+func getEventCounts(events *suricata.EventDispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"counts": events.Counts()})
+	}
+}
+
+func getWriterStats(writer *data.AlertWriter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, writer.Stats())
+	}
+}
+
+// Agreement of each shadow policy with the live decisions; from/to are unix seconds, default the last 7 days
+func getShadowSummary(db data.Repository, shadows *suricata.Shadows) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		from, err := strconv.ParseInt(c.DefaultQuery("from", strconv.FormatInt(now.Add(-7*24*time.Hour).Unix(), 10)), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from"})
+			return
+		}
+		to, err := strconv.ParseInt(c.DefaultQuery("to", "0"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to"})
+			return
+		}
+
+		var names []string
+		if shadows != nil {
+			names = shadows.Names()
+		}
+		summaries, err := db.GetShadowSummary(c.Request.Context(), names, from, to)
+		if err != nil {
+			log.Printf("GetShadowSummary error: %v", err)
+			c.JSON(500, gin.H{"error": "Failed to retrieve shadow summary"})
+			return
+		}
+
+		c.JSON(200, gin.H{"from": from, "to": to, "policies": summaries})
+	}
+}
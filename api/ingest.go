@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+
+	suricata "firefighter/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of one POST /api/v1/alerts request
+const (
+	maxIngestBatch = 1000
+	maxIngestBody  = 4 << 20
+)
+
+// Alert ingestion for third-party tools
+type IngestSettings struct {
+	// Source label → bearer token; the label is stored with every alert the token submits
+	Sources map[string]string
+	// Rate limit of each source
+	Limits map[string]RateLimit
+	// Main pipeline channel (same as the inputs feed)
+	Alerts chan<- suricata.Alert
+	// Rate limits and timestamp bounds run on it, nil = SystemClock
+	Clock suricata.Clock
+}
+
+// Resolving the bearer token to its source label
+func requireSourceToken(sources map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(sources) == 0 {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Alert ingestion disabled, configure ingest.sources"})
+			return
+		}
+
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok {
+			for name, token := range sources {
+				if subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+					c.Set("ingest_source", name)
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatusJSON(401, gin.H{"error": "Invalid or missing ingest token"})
+	}
+}
+
+type ingestError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// Single alert object or an array of them, fields as in core.Alert (EVE names).
+// Only valid alerts count against the rate limit.
+func ingestAlerts(s IngestSettings, clock suricata.Clock, limiter *rateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		source := c.GetString("ingest_source")
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIngestBody))
+		if err != nil {
+			c.JSON(413, gin.H{"error": fmt.Sprintf("Body larger than %d bytes", maxIngestBody)})
+			return
+		}
+
+		var alerts []suricata.Alert
+		body = bytes.TrimSpace(body)
+		if len(body) > 0 && body[0] == '[' {
+			err = json.Unmarshal(body, &alerts)
+		} else {
+			var single suricata.Alert
+			err = json.Unmarshal(body, &single)
+			alerts = []suricata.Alert{single}
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid JSON: " + err.Error()})
+			return
+		}
+		if len(alerts) == 0 {
+			c.JSON(400, gin.H{"error": "No alerts in request"})
+			return
+		}
+		if len(alerts) > maxIngestBatch {
+			c.JSON(413, gin.H{"error": fmt.Sprintf("At most %d alerts per request", maxIngestBatch)})
+			return
+		}
+
+		rejected := []ingestError{}
+		valid := alerts[:0]
+		now := clock.Now()
+		for i := range alerts {
+			a := alerts[i]
+			if err := suricata.PrepareExternalAlert(&a, source, now); err != nil {
+				rejected = append(rejected, ingestError{Index: i, Error: err.Error()})
+				continue
+			}
+			valid = append(valid, a)
+		}
+		if len(valid) == 0 {
+			c.JSON(400, gin.H{"source": source, "queued": 0, "dropped": 0, "rejected": rejected})
+			return
+		}
+
+		if ok, wait := limiter.take(source, len(valid)); !ok {
+			if wait < 0 {
+				c.JSON(413, gin.H{"error": fmt.Sprintf("Batch larger than the rate limit burst (%d)", s.Limits[source].Burst)})
+				return
+			}
+			c.Header("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+			c.JSON(429, gin.H{"error": "Rate limit exceeded", "source": source})
+			return
+		}
+
+		queued, dropped := 0, 0
+		for _, a := range valid {
+			// Never block the HTTP handler on a full pipeline
+			select {
+			case s.Alerts <- a:
+				queued++
+			default:
+				dropped++
+			}
+		}
+
+		if dropped > 0 {
+			slog.Warn("Alert queue full, ingested alerts dropped", "source", source, "dropped", dropped)
+		}
+
+		status := 202
+		if queued == 0 {
+			status = 503
+		}
+		c.JSON(status, gin.H{
+			"source":   source,
+			"queued":   queued,
+			"dropped":  dropped,
+			"rejected": rejected,
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"firefighter/config"
+	suricata "firefighter/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ingestNow = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+const (
+	honeypotToken = "0123456789abcdef"
+	wafToken      = "fedcba9876543210"
+)
+
+// Ingest settings built from the config like the service does
+func ingestServer(t *testing.T, cfg config.IngestConfig, queue int) (*gin.Engine, chan suricata.Alert, *suricata.ManualClock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	alerts := make(chan suricata.Alert, queue)
+	clock := suricata.NewManualClock(ingestNow)
+	s := IngestSettings{
+		Sources: map[string]string{},
+		Limits:  map[string]RateLimit{},
+		Alerts:  alerts,
+		Clock:   clock,
+	}
+	for name, src := range cfg.Sources {
+		rate, burst := cfg.Limit(name)
+		s.Sources[name] = src.Token
+		s.Limits[name] = RateLimit{Rate: rate, Burst: burst}
+	}
+	return SetupRouter(Deps{Ingest: s}), alerts, clock
+}
+
+func defaultIngest() config.IngestConfig {
+	return config.IngestConfig{Rate: 50, Burst: 500, Sources: map[string]config.IngestSource{
+		"honeypot": {Token: honeypotToken},
+	}}
+}
+
+type ingestResponse struct {
+	Error    string        `json:"error"`
+	Source   string        `json:"source"`
+	Queued   int           `json:"queued"`
+	Dropped  int           `json:"dropped"`
+	Rejected []ingestError `json:"rejected"`
+}
+
+func postAlerts(t *testing.T, r *gin.Engine, auth, body string) (*httptest.ResponseRecorder, ingestResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts", strings.NewReader(body))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp ingestResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response %q: %v", w.Body, err)
+	}
+	return w, resp
+}
+
+// A valid alert five seconds before ingestNow
+func ingestAlert(sid int) string {
+	return `{"timestamp":"2026-01-02T03:04:00.000000+0000","src_ip":"198.51.100.7","dest_ip":"192.0.2.1","dest_port":22,"proto":"TCP","alert":{"signature_id":` +
+		strconv.Itoa(sid) + `}}`
+}
+
+func batch(n int) string {
+	alerts := make([]string, n)
+	for i := range alerts {
+		alerts[i] = ingestAlert(1000 + i)
+	}
+	return "[" + strings.Join(alerts, ",") + "]"
+}
+
+func TestIngestAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.IngestConfig
+		auth   string
+		status int
+	}{
+		{"ingestion disabled", config.IngestConfig{}, "Bearer " + honeypotToken, 403},
+		{"missing token", defaultIngest(), "", 401},
+		{"wrong token", defaultIngest(), "Bearer " + wafToken, 401},
+		{"not a bearer token", defaultIngest(), "Token " + honeypotToken, 401},
+		{"valid token", defaultIngest(), "Bearer " + honeypotToken, 202},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, alerts, _ := ingestServer(t, tt.cfg, 10)
+			w, _ := postAlerts(t, r, tt.auth, ingestAlert(1000))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != 202 {
+				if len(alerts) != 0 {
+					t.Error("alert queued without a valid token")
+				}
+				return
+			}
+			a := <-alerts
+			if a.Source != "honeypot" || a.Alert.Signature != "honeypot alert 1000" {
+				t.Errorf("source %q, signature %q", a.Source, a.Alert.Signature)
+			}
+		})
+	}
+}
+
+func TestIngestLimits(t *testing.T) {
+	rate, burst := 1.0, 2
+	cfg := config.IngestConfig{Rate: 50, Burst: 500, Sources: map[string]config.IngestSource{
+		"honeypot": {Token: honeypotToken, Rate: &rate, Burst: &burst},
+		"waf":      {Token: wafToken},
+	}}
+	r, _, clock := ingestServer(t, cfg, 1000)
+	honeypot, waf := "Bearer "+honeypotToken, "Bearer "+wafToken
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		auth    string
+		body    string
+		status  int
+	}{
+		{"invalid alerts take no tokens", 0, honeypot, `[{"src_ip":"198.51.100.7"},{"src_ip":"198.51.100.7"}]`, 400},
+		{"within burst", 0, honeypot, batch(2), 202},
+		{"bucket empty", 0, honeypot, ingestAlert(1000), 429},
+		{"batch larger than burst", 0, honeypot, batch(3), 413},
+		{"other source has the global limit", 0, waf, batch(3), 202},
+		{"refilled", time.Second, honeypot, ingestAlert(1000), 202},
+	}
+	for _, s := range steps {
+		clock.Advance(s.advance)
+		w, _ := postAlerts(t, r, s.auth, s.body)
+		if w.Code != s.status {
+			t.Errorf("%s: status %d, want %d: %s", s.name, w.Code, s.status, w.Body)
+		}
+		if w.Code == 429 && w.Header().Get("Retry-After") != "1" {
+			t.Errorf("%s: Retry-After %q, want 1", s.name, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestIngestValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert string
+		err   string // empty = accepted
+	}{
+		{"valid", ingestAlert(1000), ""},
+		{"no timestamp", `{"src_ip":"198.51.100.7","alert":{"signature_id":1000}}`, ""},
+		{"missing signature_id", `{"src_ip":"198.51.100.7","alert":{}}`, "signature_id must be positive"},
+		{"reserved sid", `{"src_ip":"198.51.100.7","alert":{"signature_id":9900001}}`, "is reserved"},
+		{"severity out of range", `{"src_ip":"198.51.100.7","alert":{"signature_id":1000,"severity":4}}`, "severity must be between 1 and 3"},
+		{"port out of range", `{"src_ip":"198.51.100.7","dest_port":70000,"alert":{"signature_id":1000}}`, "dest_port out of range"},
+		{"invalid address", `{"src_ip":"scanner.example","alert":{"signature_id":1000}}`, "src_ip"},
+		{"invalid timestamp", `{"timestamp":"yesterday","src_ip":"198.51.100.7","alert":{"signature_id":1000}}`, "invalid timestamp"},
+		{"far future", `{"timestamp":"2099-01-01T00:00:00Z","src_ip":"198.51.100.7","alert":{"signature_id":1000}}`, "outside"},
+		{"just ahead", `{"timestamp":"2026-01-02T03:08:05Z","src_ip":"198.51.100.7","alert":{"signature_id":1000}}`, ""},
+		{"too old", `{"timestamp":"2025-12-31T03:04:05Z","src_ip":"198.51.100.7","alert":{"signature_id":1000}}`, "outside"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, alerts, _ := ingestServer(t, defaultIngest(), 10)
+			// Paired with a valid alert, only the tested one may be rejected
+			w, resp := postAlerts(t, r, "Bearer "+honeypotToken, "["+tt.alert+","+ingestAlert(2000)+"]")
+			if w.Code != 202 {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if tt.err == "" {
+				if resp.Queued != 2 || len(resp.Rejected) != 0 {
+					t.Errorf("queued %d, rejected %+v, want both queued", resp.Queued, resp.Rejected)
+				}
+				if a := <-alerts; a.ParsedTime.IsZero() {
+					t.Error("accepted alert without event time")
+				}
+				return
+			}
+			if resp.Queued != 1 || len(resp.Rejected) != 1 || resp.Rejected[0].Index != 0 ||
+				!strings.Contains(resp.Rejected[0].Error, tt.err) {
+				t.Errorf("queued %d, rejected %+v, want index 0 rejected with %q", resp.Queued, resp.Rejected, tt.err)
+			}
+		})
+	}
+
+	r, _, _ := ingestServer(t, defaultIngest(), 10)
+	if w, _ := postAlerts(t, r, "Bearer "+honeypotToken, `{"alert":`); w.Code != 400 {
+		t.Errorf("invalid JSON: status %d, want 400", w.Code)
+	}
+	if w, _ := postAlerts(t, r, "Bearer "+honeypotToken, `[]`); w.Code != 400 {
+		t.Errorf("empty batch: status %d, want 400", w.Code)
+	}
+}
+
+func TestIngestQueueFull(t *testing.T) {
+	r, alerts, _ := ingestServer(t, defaultIngest(), 1)
+
+	w, resp := postAlerts(t, r, "Bearer "+honeypotToken, batch(3))
+	if w.Code != 202 || resp.Queued != 1 || resp.Dropped != 2 {
+		t.Errorf("status %d, queued %d, dropped %d, want 202, 1, 2", w.Code, resp.Queued, resp.Dropped)
+	}
+	w, resp = postAlerts(t, r, "Bearer "+honeypotToken, ingestAlert(1000))
+	if w.Code != 503 || resp.Dropped != 1 {
+		t.Errorf("status %d, dropped %d, want 503, 1", w.Code, resp.Dropped)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("%d alerts queued, want 1", len(alerts))
+	}
+}
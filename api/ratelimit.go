@@ -0,0 +1,73 @@
+package api
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	suricata "firefighter/core"
+)
+
+// Token bucket per key (ingest source): rate tokens per second, up to burst
+type rateLimiter struct {
+	limits map[string]RateLimit
+	clock  suricata.Clock
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// Alerts per second and bucket size, Rate 0 = unlimited
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Keys without a limit are not limited
+func newRateLimiter(limits map[string]RateLimit, clock suricata.Clock) *rateLimiter {
+	return &rateLimiter{
+		limits:  limits,
+		clock:   clock,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Taking n tokens at once; when there are not enough nothing is taken and
+// the wait until there would be is returned
+func (l *rateLimiter) take(key string, n int) (bool, time.Duration) {
+	limit := l.limits[key]
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+	rate, burst := limit.Rate, float64(limit.Burst)
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	need := float64(n)
+	if need <= b.tokens {
+		b.tokens -= need
+		return true, 0
+	}
+	if need > burst {
+		// Can never succeed, caller should split the batch
+		return false, -1
+	}
+	wait := time.Duration((need - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
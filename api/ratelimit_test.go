@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	suricata "firefighter/core"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	clock := suricata.NewManualClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	l := newRateLimiter(map[string]RateLimit{
+		"honeypot": {Rate: 10, Burst: 20},
+		"script":   {Rate: 0, Burst: 1},
+	}, clock)
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		n       int
+		ok      bool
+		wait    time.Duration
+	}{
+		{"full bucket", 0, "honeypot", 15, true, 0},
+		{"5 left", 0, "honeypot", 10, false, 500 * time.Millisecond},
+		{"refused take leaves tokens", 0, "honeypot", 5, true, 0},
+		{"refilled at rate", time.Second, "honeypot", 10, true, 0},
+		{"capped at burst", time.Hour, "honeypot", 21, false, -1},
+		{"burst after idle", 0, "honeypot", 20, true, 0},
+		{"empty", 0, "honeypot", 1, false, 100 * time.Millisecond},
+		{"unlimited", 0, "script", 1000, true, 0},
+		{"unknown key unlimited", 0, "other", 1000, true, 0},
+	}
+	for _, s := range steps {
+		clock.Advance(s.advance)
+		ok, wait := l.take(s.key, s.n)
+		if ok != s.ok || wait != s.wait {
+			t.Errorf("%s: take(%q, %d) = %v, %s, want %v, %s", s.name, s.key, s.n, ok, wait, s.ok, s.wait)
+		}
+	}
+}
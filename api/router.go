@@ -3,31 +3,60 @@ package api
 import (
 	suricata "firefighter/core"
 	"firefighter/data"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/gin-contrib/cors"
 )
 
-func SetupRouter(db data.Repository, wm *suricata.WindowManager) *gin.Engine {
+// Services the HTTP API works on
+type Deps struct {
+	DB        data.Repository
+	Windows   *suricata.WindowManager
+	Enforcer  suricata.Enforcer
+	Whitelist *suricata.Whitelist
+	Mode      *suricata.EnforcementMode
+	Events    *suricata.EventDispatcher
+
+	// Built frontend (vite dist), empty serves the API only
+	FrontendDir string
+	// Bearer token for admin endpoints, empty disables them
+	APIToken string
+
+	// Re-reads policy and whitelist (same path as SIGHUP)
+	ReloadPolicy func() error
+	// Block durations, used by backtests to simulate blocks
+	Escalation suricata.EscalationPolicy
+	// Shadow policies scored next to the live one
+	Shadows *suricata.Shadows
+	// Write-behind alert persistence, for queue depth
+	Writer *data.AlertWriter
+
+	// POST /api/v1/alerts
+	Ingest IngestSettings
+}
+
+func SetupRouter(d Deps) *gin.Engine {
 	r := gin.Default()
+	db, wm := d.DB, d.Windows
 
 	apiGroup := r.Group("/api")
 	apiGroup.Use(cors.New(cors.Config{
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{"GET", "POST", "DELETE"},
-		AllowHeaders: []string{"Origin", "Content-Type"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Authorization"},
 	}))
 	{
 		// Blocked IPs
 		apiGroup.GET("/blocked", getBlocked(db))
 		apiGroup.GET("/blocked/by_ip", getBlockedByIPQuery(db))
-		apiGroup.POST("/unblock/:ip", unblockIP(db, wm))
+		apiGroup.POST("/unblock/*ip", unblockIP(db, wm, d.Enforcer)) // *ip: IPv6 prefixes contain "/"
 
 		// Whitelist
 		apiGroup.GET("/whitelist", getWhitelisted(db))
-		apiGroup.POST("/whitelist/:ip", addToWhitelist(db))
-		apiGroup.DELETE("/whitelist/:ip", removeFromWhitelist(db))
+		apiGroup.POST("/whitelist/*ip", addToWhitelist(db, d.Whitelist)) // CIDR entries contain "/"
+		apiGroup.DELETE("/whitelist/*ip", removeFromWhitelist(db, d.Whitelist))
 
 		// Stats & Analytics
 		apiGroup.GET("/stats", getStats(db))
@@ -38,17 +67,47 @@ func SetupRouter(db data.Repository, wm *suricata.WindowManager) *gin.Engine {
 		apiGroup.GET("/stats/alerts/buckets", getAlertBuckets(db))
 		apiGroup.GET("/stats/blocks/buckets", getBlockBuckets(db))
 		apiGroup.GET("/stats/alerts/by_ip", getAlertsByIPQuery(db))
+		apiGroup.GET("/stats/writer", getWriterStats(d.Writer))
 
 		apiGroup.GET("/activity", getActivity(db))
+
+		// Detection
+		apiGroup.GET("/scoring", getScoring(wm))
+		apiGroup.GET("/policy", getPolicy(wm))
+		apiGroup.GET("/mode", getMode(d.Mode, wm))
+		apiGroup.GET("/windows", getWindows(wm))
+		apiGroup.GET("/events", getEventCounts(d.Events))
+		apiGroup.GET("/shadow/summary", getShadowSummary(db, d.Shadows))
+	}
+
+	// Admin
+	admin := apiGroup.Group("", requireToken(d.APIToken))
+	{
+		admin.POST("/policy/reload", reloadPolicy(d.ReloadPolicy, wm))
+		admin.POST("/mode", setMode(db, d.Mode, wm))
+		admin.POST("/backtest", runBacktest(db, wm, d.Escalation))
+	}
+
+	// Ingestion for third-party tools, authenticated per source
+	v1 := r.Group("/api/v1", requireSourceToken(d.Ingest.Sources))
+	{
+		clock := d.Ingest.Clock
+		if clock == nil {
+			clock = suricata.SystemClock{}
+		}
+		v1.POST("/alerts", ingestAlerts(d.Ingest, clock, newRateLimiter(d.Ingest.Limits, clock)))
 	}
 
 	r.GET("/ws", handleWebSocket)
 
-	r.Static("/assets", "/home/lucas/firefighter/frontend/dist/assets")
-	r.StaticFile("/", "/home/lucas/firefighter/frontend/dist/index.html")
-	r.NoRoute(func(c *gin.Context) {
-		c.File("/home/lucas/firefighter/frontend/dist/index.html")
-	})
+	if d.FrontendDir != "" {
+		index := filepath.Join(d.FrontendDir, "index.html")
+		r.Static("/assets", filepath.Join(d.FrontendDir, "assets"))
+		r.StaticFile("/", index)
+		r.NoRoute(func(c *gin.Context) {
+			c.File(index)
+		})
+	}
 
 	return r
 }
@@ -25,6 +25,7 @@ type WebSocketMessage struct {
 	Protocol string `json:"protocol,omitempty"`
 	SrcPort  string `json:"src_port,omitempty"`
 	DstPort  string `json:"dst_port,omitempty"`
+	Host     string `json:"host,omitempty"` // HTTP Host / TLS SNI
 
 	// Blokady
 	Score         string `json:"score"`
@@ -35,6 +36,7 @@ type WebSocketMessage struct {
 	UniqueProtos  string `json:"unique_protos"`
 	UniqueFlows   string `json:"unique_flows"`
 	Categories    string `json:"categories"`
+	UnblockTime   int64  `json:"unblock_time,omitempty"`
 }
 
 type Hub struct {
@@ -108,7 +110,7 @@ func (h *Hub) Run() {
 	}
 }
 
-func BroadcastAlert(ip, signature string, sid, severity, srcPort, dstPort int, protocol, category string) {
+func BroadcastAlert(ip, signature string, sid, severity, srcPort, dstPort int, protocol, category, host string) {
 	hub.broadcast <- WebSocketMessage{
 		Type:      "alert",
 		IP:        ip,
@@ -120,12 +122,22 @@ func BroadcastAlert(ip, signature string, sid, severity, srcPort, dstPort int, p
 		Protocol:  protocol,
 		SrcPort:   fmt.Sprintf("%d", srcPort),
 		DstPort:   fmt.Sprintf("%d", dstPort),
+		Host:      host,
 	}
 }
 
-func BroadcastBlockWithScore(ip, reason string, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows int, categories, details string) {
-	hub.broadcast <- WebSocketMessage{
-		Type:          "block",
+func BroadcastBlockWithScore(ip, reason string, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows int, categories, details string, unblockTime int64) {
+	hub.broadcast <- scoreMessage("block", ip, reason, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows, categories, details, unblockTime)
+}
+
+// Decision made in monitor mode, nothing was blocked
+func BroadcastWouldBlock(ip, reason string, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows int, categories, details string) {
+	hub.broadcast <- scoreMessage("would_block", ip, reason, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows, categories, details, 0)
+}
+
+func scoreMessage(msgType, ip, reason string, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows int, categories, details string, unblockTime int64) WebSocketMessage {
+	return WebSocketMessage{
+		Type:          msgType,
 		IP:            ip,
 		Reason:        reason,
 		Score:         fmt.Sprintf("%d", score),
@@ -137,14 +149,15 @@ func BroadcastBlockWithScore(ip, reason string, score, alertCount, severityScore
 		UniqueProtos:  fmt.Sprintf("%d", uniqueProtos),
 		UniqueFlows:   fmt.Sprintf("%d", uniqueFlows),
 		Categories:    categories,
+		UnblockTime:   unblockTime,
 	}
 }
 
-func BroadcastUnblock(ip string) {
+func BroadcastUnblock(ip, reason string) {
 	hub.broadcast <- WebSocketMessage{
 		Type:      "unblock",
 		IP:        ip,
-		Reason:    "Manually unblocked",
+		Reason:    reason,
 		Timestamp: time.Now().Unix(),
 	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	suricata "firefighter/core"
+	"firefighter/data"
+)
+
+// firefighter backtest [flags]
+//
+// The candidate policy is the one the config flags and file describe
+// (e.g. -config candidate.yaml or -threshold 40), alerts and block history
+// come from its database.
+func runBacktest(args []string) error {
+	fs := flag.NewFlagSet("firefighter backtest", flag.ContinueOnError)
+	from := fs.String("from", "", "start of the alert range, RFC 3339 or YYYY-MM-DD (default: oldest alert)")
+	to := fs.String("to", "", "end of the alert range, exclusive (default: newest alert)")
+	asJSON := fs.Bool("json", false, "print the full report as JSON")
+
+	cfg, err := loadOfflineConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	bt := &suricata.Backtest{
+		Window:     cfg.Analysis.Window,
+		IPv6Prefix: cfg.Analysis.IPv6Prefix,
+	}
+	if bt.From, err = parseTimeFlag(*from); err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	if bt.To, err = parseTimeFlag(*to); err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	bt.Policy, _ = cfg.Policy() // validated in config.Load
+	steps, _ := cfg.Blocking.Steps()
+	bt.Escalation = suricata.EscalationPolicy{Steps: steps, Lookback: cfg.Blocking.Lookback}
+
+	db, err := data.New(cfg.Database.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	defer db.Close()
+
+	report, err := bt.Run(context.Background(), db)
+	if err != nil {
+		return fmt.Errorf("backtest failed: %w", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printBacktest(report)
+	return nil
+}
+
+func parseTimeFlag(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("expected RFC 3339 time or YYYY-MM-DD")
+}
+
+func printBacktest(r *suricata.BacktestReport) {
+	fmt.Println("=== BACKTEST ===")
+	fmt.Printf("Range: %s .. %s, alerts: %d, window: %s, threshold: %d\n",
+		r.From.Format(time.DateTime), r.To.Format(time.DateTime), r.Alerts, r.Window, r.Policy.Scoring.Threshold)
+	if r.Note != "" {
+		fmt.Printf("Note: %s\n", r.Note)
+	}
+	fmt.Printf("Candidate decisions: %d, actual blocks: %d\n", len(r.WouldBlock), len(r.Actual))
+
+	fmt.Printf("\nBlocked by both (%d):\n", len(r.Diff.Both))
+	for _, m := range r.Diff.Both {
+		fmt.Printf("  %-40s candidate %s  actual %s  (%+ds)\n",
+			m.IP, m.CandidateAt.Format(time.DateTime), m.ActualAt.Format(time.DateTime), m.DeltaSeconds)
+	}
+
+	fmt.Printf("\nOnly the candidate would block (%d):\n", len(r.Diff.CandidateOnly))
+	for _, b := range r.Diff.CandidateOnly {
+		fmt.Printf("  %-40s %s  score %-4d %s\n", b.IP, b.At.Format(time.DateTime), b.Score, b.Reason)
+	}
+
+	fmt.Printf("\nOnly actually blocked (%d):\n", len(r.Diff.ActualOnly))
+	for _, b := range r.Diff.ActualOnly {
+		fmt.Printf("  %-40s %s  score %-4d %s\n", b.IP, b.At.Format(time.DateTime), b.Score, b.Reason)
+	}
+}
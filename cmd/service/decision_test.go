@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	suricata "firefighter/core"
+	"firefighter/data"
+)
+
+// Records blocks and would_block entries; lookups fail on demand
+type decisionRepo struct {
+	data.Repository
+	whitelist  []data.WhitelistDetails
+	blocked    map[string]bool
+	history    []data.BlockedIPDetails
+	blockedErr error
+	historyErr error
+
+	added       []addedBlock
+	wouldBlocks []string
+}
+
+type addedBlock struct {
+	ip          string
+	unblockTime int64
+}
+
+func (r *decisionRepo) GetWhitelistDetails(context.Context) ([]data.WhitelistDetails, error) {
+	return r.whitelist, nil
+}
+
+func (r *decisionRepo) IsBlocked(_ context.Context, ip string) (bool, error) {
+	return r.blocked[ip], r.blockedErr
+}
+
+func (r *decisionRepo) GetBlockedByIP(context.Context, string) ([]data.BlockedIPDetails, error) {
+	return r.history, r.historyErr
+}
+
+func (r *decisionRepo) AddBlocked(_ context.Context, ip, _ string, _, _, _, _, _, _ int, _, _ string, unblockTime int64) error {
+	r.added = append(r.added, addedBlock{ip, unblockTime})
+	return nil
+}
+
+func (r *decisionRepo) LogActivity(_ context.Context, activityType, ip, _, _ string) error {
+	if activityType == "would_block" {
+		r.wouldBlocks = append(r.wouldBlocks, ip)
+	}
+	return nil
+}
+
+func TestDecisionHandler(t *testing.T) {
+	const ip = "198.51.100.7"
+	escalation := suricata.EscalationPolicy{Steps: []time.Duration{time.Hour, 24 * time.Hour, 0}}
+
+	tests := []struct {
+		name        string
+		repo        decisionRepo
+		mode        string
+		monitor     bool          // decision of a monitor-only policy
+		duration    time.Duration // of the block, -1 = none, 0 = permanent
+		wouldBlocks int
+	}{
+		{name: "first offence", duration: time.Hour},
+		{name: "repeat offence", repo: decisionRepo{history: []data.BlockedIPDetails{{Timestamp: time.Now().Unix()}}}, duration: 24 * time.Hour},
+		{name: "third offence is permanent", repo: decisionRepo{history: []data.BlockedIPDetails{{}, {}}}, duration: 0},
+		{name: "history lookup fails", repo: decisionRepo{
+			history:    []data.BlockedIPDetails{{}, {}, {}},
+			historyErr: context.DeadlineExceeded,
+		}, duration: time.Hour},
+		{name: "whitelisted", repo: decisionRepo{whitelist: []data.WhitelistDetails{{IP: "198.51.100.0/24"}}}, duration: -1},
+		{name: "already blocked", repo: decisionRepo{blocked: map[string]bool{ip: true}}, duration: -1},
+		{name: "block state lookup fails", repo: decisionRepo{blockedErr: errors.New("database is locked")}, duration: -1},
+		{name: "monitor mode", mode: suricata.ModeMonitor, duration: -1, wouldBlocks: 1},
+		{name: "monitor-only policy", monitor: true, duration: -1, wouldBlocks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			whitelist := suricata.NewWhitelist()
+			if err := whitelist.Load(context.Background(), &repo); err != nil {
+				t.Fatal(err)
+			}
+			enforcer := suricata.NewDryRunEnforcer()
+			mode := tt.mode
+			if mode == "" {
+				mode = suricata.ModeEnforce
+			}
+
+			h := &decisionHandler{
+				db:         &repo,
+				enforcer:   enforcer,
+				whitelist:  whitelist,
+				mode:       suricata.NewEnforcementMode(mode),
+				escalation: escalation,
+				timeout:    time.Second,
+			}
+			before := time.Now()
+			h.handle(suricata.BlockDecision{IP: ip, Reason: "test", Score: 100, Monitor: tt.monitor})
+
+			list, _ := enforcer.List()
+			if tt.duration < 0 {
+				if len(list) != 0 || len(repo.added) != 0 {
+					t.Errorf("blocked %q, saved %+v, want no block", list, repo.added)
+				}
+			} else {
+				if !reflect.DeepEqual(list, []string{ip}) || len(repo.added) != 1 {
+					t.Fatalf("blocked %q, saved %+v, want one block of %s", list, repo.added, ip)
+				}
+				got := repo.added[0].unblockTime
+				if tt.duration == 0 && got != 0 {
+					t.Errorf("unblock time %d, want permanent", got)
+				}
+				if tt.duration > 0 {
+					lo, hi := before.Add(tt.duration).Unix(), time.Now().Add(tt.duration).Unix()
+					if got < lo || got > hi {
+						t.Errorf("unblock time %d, want %s from now", got, tt.duration)
+					}
+				}
+			}
+			if len(repo.wouldBlocks) != tt.wouldBlocks {
+				t.Errorf("%d would_block entries, want %d", len(repo.wouldBlocks), tt.wouldBlocks)
+			}
+		})
+	}
+}
@@ -2,26 +2,60 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	api "firefighter/api"
+	"firefighter/config"
 	suricata "firefighter/core"
 	"firefighter/data"
 )
 
+// Offline tools: firefighter <command> [flags]
+var subcommands = map[string]func(args []string) error{
+	"replay":   runReplay,
+	"backtest": runBacktest,
+	"migrate":  runMigrate,
+}
+
+// Config for an offline command; nothing is served, so a missing frontend dist is fine
+func loadOfflineConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	return config.LoadFlags(fs, append([]string{"-frontend", "none"}, args...))
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// ← DODANE: Setup loggera (tekstowy)
-	os.MkdirAll("/var/log/firefighter", 0755)
-	logFile, err := os.OpenFile("/var/log/firefighter/app.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	os.MkdirAll(cfg.Log.Dir, 0755)
+	logFile, err := os.OpenFile(filepath.Join(cfg.Log.Dir, "app.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		log.Fatal("Cannot open log file:", err)
 	}
@@ -30,49 +64,166 @@ func main() {
 	logger := slog.New(slog.NewTextHandler(logFile, nil))
 	slog.SetDefault(logger)
 
-	slog.Info("System starting", "version", "1.0.0") // ← DODANE
+	slog.Info("System starting", "version", "1.0.0", "config", cfg.Path) // ← DODANE
 
-	db, err := data.New("/home/lucas/firefighter/data/firefighter.db")
+	db, err := data.New(cfg.Database.Path)
 	if err != nil {
 		slog.Error("Database connection failed", "error", err) // ← DODANE
 		log.Fatal("Unable to open database:", err)
 	}
 	defer db.Close()
-	slog.Info("Database connected", "path", "/home/lucas/firefighter/data/firefighter.db") // ← DODANE
+	slog.Info("Database connected", "path", cfg.Database.Path) // ← DODANE
+
+	// Alerts are persisted in batches behind the main loop
+	writer := data.NewAlertWriter(db, cfg.Database.BatchSize, cfg.Database.FlushInterval, cfg.Database.Timeout, cfg.Database.QueueSize)
 
 	go api.StartHub()
 
-	wm := suricata.NewWindowManager(600 * time.Second)
+	enforcer, err := suricata.NewEnforcer(cfg.Firewall.Driver)
+	if err != nil {
+		slog.Error("Firewall backend setup failed", "error", err)
+		log.Fatal("Unable to set up firewall backend:", err)
+	}
+
+	whitelist := suricata.NewWhitelist()
+	if err := whitelist.Load(context.Background(), db); err != nil {
+		slog.Error("Whitelist load failed", "error", err)
+		log.Fatal("Unable to load whitelist:", err)
+	}
+
+	wm := suricata.NewWindowManager(cfg.Analysis.Window)
+	policy, _ := cfg.Policy() // validated in config.Load
+	wm.SetPolicy(policy)
+	wm.IPv6Prefix = cfg.Analysis.IPv6Prefix
+	wm.Lateness = cfg.Analysis.Lateness
+	wm.MaxSkew = cfg.Analysis.MaxSkew
+	wm.Whitelist = whitelist
+
+	// Time-limited blocks
+	steps, _ := cfg.Blocking.Steps() // validated in config.Load
+	escalation := suricata.EscalationPolicy{Steps: steps, Lookback: cfg.Blocking.Lookback}
+
+	// Shadow policies: scored on every alert, decisions only recorded
+	shadows := &suricata.Shadows{DB: db}
+	for _, sh := range cfg.Shadows {
+		policy, _ := cfg.ShadowPolicy(sh) // validated in config.Load
+		shadows.Policies = append(shadows.Policies,
+			suricata.NewShadowPolicy(sh.Name, policy, cfg.ShadowWindow(sh), wm, db, escalation))
+		slog.Info("Shadow policy enabled", "name", sh.Name, "threshold", policy.Scoring.Threshold)
+	}
+
+	expiry := suricata.NewExpiryScheduler(db, enforcer, wm, cfg.Blocking.ExpiryInterval)
+	expiry.Whitelist = whitelist
+	expiry.OnUnblock = func(ip string) {
+		api.BroadcastUnblock(ip, "Block expired")
+	}
+	stopExpiry := make(chan struct{})
+	go expiry.Run(stopExpiry)
+
+	// Rollups and purging of old rows
+	retention := &suricata.RetentionScheduler{
+		DB:            db,
+		Tables:        cfg.Retention.PurgeTargets(),
+		HourlyRollups: cfg.Retention.HourlyRollups,
+		BatchSize:     cfg.Retention.BatchSize,
+		Interval:      cfg.Retention.Interval,
+	}
+	stopRetention := make(chan struct{})
+	retentionDone := make(chan struct{})
+	go func() {
+		defer close(retentionDone)
+		retention.Run(stopRetention)
+	}()
+
+	// All EVE record types; alerts go to alertChan, the rest only enrich alerts,
+	// feed the counts of GET /api/events and the stats handler below
+	events := suricata.NewEventDispatcher()
+	events.Handle(suricata.EventStats, captureDropWatcher())
+
+	// Fed by inputs and POST /api/v1/alerts
+	alertChan := make(chan suricata.Alert, 1000)
 
 	// HTTP server
-	r := api.SetupRouter(db, wm)
+	mode := suricata.NewEnforcementMode(cfg.Blocking.Mode)
+	if mode.Monitor() {
+		slog.Warn("Monitor mode: decisions are logged as would_block, nothing is blocked")
+	}
+
+	// Hot reload: policy from the config file, whitelist from the database
+	reload := func() error {
+		return reloadPolicy(cfg, db, wm, whitelist)
+	}
+
+	r := api.SetupRouter(api.Deps{
+		DB:           db,
+		Windows:      wm,
+		Enforcer:     enforcer,
+		Whitelist:    whitelist,
+		Mode:         mode,
+		Events:       events,
+		FrontendDir:  cfg.HTTP.FrontendDir,
+		APIToken:     cfg.HTTP.APIToken,
+		ReloadPolicy: reload,
+		Escalation:   escalation,
+		Shadows:      shadows,
+		Writer:       writer,
+		Ingest:       ingestSettings(cfg.Ingest, alertChan),
+	})
 
 	go func() {
-		slog.Info("HTTP server starting", "port", 8080) // ← DODANE
-		if err := r.Run(":8080"); err != nil {
+		slog.Info("HTTP server starting", "listen", cfg.HTTP.Listen) // ← DODANE
+		if err := r.Run(cfg.HTTP.Listen); err != nil {
 			slog.Error("HTTP server failed", "error", err) // ← DODANE
 			log.Fatal("Failed to run server:", err)
 		}
 	}()
 
 	// Suricata setup
-	suricataCmd := exec.Command("sudo", "suricata",
-		"-c", "/etc/suricata/suricata.yaml",
-		"-i", "enp0s3",
-		"-v")
+	stopSuricata := func() {}
+	if cfg.Suricata.Manage {
+		suricataCmd := exec.Command("sudo", "suricata",
+			"-c", cfg.Suricata.ConfigPath,
+			"-i", cfg.Suricata.Interface,
+			"-v")
+
+		if err := suricataCmd.Start(); err != nil {
+			slog.Error("Suricata start failed", "error", err) // ← DODANE
+			log.Fatal("Failed to start Suricata:", err)
+		}
+		defer suricataCmd.Process.Kill()
+		stopSuricata = func() { suricataCmd.Process.Kill() }
+	}
 
-	if err := suricataCmd.Start(); err != nil {
-		slog.Error("Suricata start failed", "error", err) // ← DODANE
-		log.Fatal("Failed to start Suricata:", err)
+	// Inputs (Suricata socket/file, Snort alert_json, ...)
+	stopInput := make(chan struct{})
+	var inputsDone sync.WaitGroup
+	env := suricata.InputEnv{DB: db, Events: events, AccessLog: cfg.AccessLog}
+	for _, inputCfg := range cfg.InputList() {
+		input, err := suricata.NewInput(inputCfg, env)
+		if err != nil {
+			log.Fatal("Invalid input: ", err)
+		}
+		slog.Info("Starting input", "type", inputCfg.Type, "name", input.Name())
+
+		inputsDone.Add(1)
+		go func() {
+			defer inputsDone.Done()
+			if err := input.Run(alertChan, stopInput); err != nil {
+				slog.Error("Input failed", "name", input.Name(), "error", err) // ← DODANE
+				log.Fatal(err)
+			}
+		}()
 	}
-	defer suricataCmd.Process.Kill()
 
-	alertChan := make(chan suricata.Alert, 1000)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
 
 	go func() {
-		if err := suricata.StartServer(suricata.SuricataSocketPath, alertChan); err != nil {
-			slog.Error("Unix socket server failed", "error", err) // ← DODANE
-			log.Fatal(err)
+		for range hupChan {
+			slog.Info("SIGHUP received, reloading policy")
+			if err := reload(); err != nil {
+				slog.Error("Policy reload failed, keeping current policy", "error", err)
+			}
 		}
 	}()
 
@@ -83,13 +234,28 @@ func main() {
 	go func() {
 		<-sigChan
 		slog.Info("Shutdown signal received, stopping gracefully") // ← DODANE
+		close(stopExpiry)
+		close(stopRetention)
+		<-retentionDone // no purge batch in flight when the database closes
+		close(stopInput)
+		inputsDone.Wait() // file inputs save their checkpoints
+		writer.Close()    // queued alerts are written before the database closes
 		db.Close()
 		slog.Info("Database closed") // ← DODANE
-		suricataCmd.Process.Kill()
+		stopSuricata()
 		slog.Info("Firefighter stopped") // ← DODANE
 		os.Exit(0)
 	}()
 
+	decisions := &decisionHandler{
+		db:         db,
+		enforcer:   enforcer,
+		whitelist:  whitelist,
+		mode:       mode,
+		escalation: escalation,
+		timeout:    cfg.Database.Timeout,
+	}
+
 	slog.Info("Firefighter started successfully") // ← DODANE
 	fmt.Println("Firefighter started! Waiting for alerts...")
 
@@ -98,10 +264,7 @@ func main() {
 		suricata.HandleAlert(alert)
 
 		// Zapisz alert do bazy
-		if err := db.AddAlert(alert.SrcIP, alert.Alert.SignatureID, alert.Alert.Signature); err != nil {
-			slog.Error("Failed to save alert to database", "error", err) // ← DODANE
-			log.Printf("Database error: %v", err)
-		}
+		writer.Add(alert.Record())
 
 		api.BroadcastAlert(
 			alert.SrcIP,
@@ -112,74 +275,214 @@ func main() {
 			alert.DstPort,
 			alert.Proto,
 			alert.Alert.Category,
+			alert.ServerName(),
 		)
 
-		wm.Add(alert)
-		wm.PrintAll()
+		key := wm.Add(alert)
+		ctx, cancel := decisions.call()
+		shadows.Evaluate(ctx, alert)
+		cancel()
 
 		// === ANALIZA I BLOKOWANIE ===
-		decisions := wm.AnalyzeAlerts(db)
-
-		for _, decision := range decisions {
-			// 1. Sprawdź whitelist
-			if whitelisted, _ := db.IsWhitelisted(decision.IP); whitelisted {
-				slog.Info("IP whitelisted, skipping block", "ip", decision.IP) // ← DODANE
-				fmt.Printf("⚪ IP %s is whitelisted - skipping\n", decision.IP)
-				continue
-			}
+		// Tylko okno IP, które właśnie dostało alert
+		ctx, cancel = decisions.call()
+		decision, ok := wm.AnalyzeIP(ctx, db, key)
+		cancel()
+		if !ok {
+			continue
+		}
 
-			// 2. Sprawdź czy już zablokowany
-			if blocked, _ := db.IsBlocked(decision.IP); blocked {
-				slog.Warn("IP already blocked, skipping", "ip", decision.IP) // ← DODANE
-				fmt.Printf("⚠️  IP %s already blocked - skipping\n", decision.IP)
-				continue
-			}
+		decisions.handle(decision)
+	}
+}
 
-			// 3. Blokuj w firewall
-			if err := suricata.BlockIP(decision.IP); err != nil {
-				slog.Error("Firewall block failed", "ip", decision.IP, "error", err) // ← DODANE
-				log.Printf("❌ Firewall block failed for %s: %v", decision.IP, err)
-				continue
-			}
+// What happens to a block decision; shared by the alert loop and replay
+type decisionHandler struct {
+	db         data.Repository
+	enforcer   suricata.Enforcer
+	whitelist  *suricata.Whitelist
+	mode       *suricata.EnforcementMode
+	escalation suricata.EscalationPolicy
+	// Deadline of each database call (database.timeout)
+	timeout time.Duration
+}
 
-			// 4. Zapisz do bazy z pełnymi danymi
-			categoriesStr := formatCategories(decision.Categories)
-
-			if err := db.AddBlocked(
-				decision.IP,
-				decision.Reason,
-				decision.Score,
-				decision.AlertCount,
-				decision.SeverityScore,
-				decision.UniquePorts,
-				decision.UniqueProtos,
-				decision.UniqueFlows,
-				categoriesStr,
-				decision.Details,
-			); err != nil {
-				slog.Error("Failed to save block to database", "ip", decision.IP, "error", err) // ← DODANE
-				log.Printf("Database save failed for %s: %v", decision.IP, err)
-			} else {
-				slog.Info("IP blocked successfully", "ip", decision.IP, "score", decision.Score, "reason", decision.Reason) // ← DODANE
-				fmt.Printf("🚫 BLOCKED: %s - %s (Score: %d)\n", decision.IP, decision.Reason, decision.Score)
-
-				api.BroadcastBlockWithScore(
-					decision.IP,
-					decision.Reason,
-					decision.Score,
-					decision.AlertCount,
-					decision.SeverityScore,
-					decision.UniquePorts,
-					decision.UniqueProtos,
-					decision.UniqueFlows,
-					formatCategories(decision.Categories),
-					decision.Details,
-				)
-			}
+// Context for one database call on the alert path
+func (h *decisionHandler) call() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), h.timeout)
+}
+
+func (h *decisionHandler) handle(decision suricata.BlockDecision) {
+	// 1. Sprawdź whitelist
+	if h.whitelist.Contains(decision.IP) {
+		slog.Info("IP whitelisted, skipping block", "ip", decision.IP) // ← DODANE
+		fmt.Printf("⚪ IP %s is whitelisted - skipping\n", decision.IP)
+		return
+	}
+
+	// 2. Sprawdź czy już zablokowany
+	ctx, cancel := h.call()
+	blocked, err := h.db.IsBlocked(ctx, decision.IP)
+	cancel()
+	if err != nil {
+		// Blocking anyway could add a second blocked_ips row; the next alert decides again
+		slog.Error("Block state lookup failed, skipping", "ip", decision.IP, "error", err)
+		return
+	}
+	if blocked {
+		slog.Warn("IP already blocked, skipping", "ip", decision.IP) // ← DODANE
+		fmt.Printf("⚠️  IP %s already blocked - skipping\n", decision.IP)
+		return
+	}
+
+	// 3. Tryb monitor - tylko zapis i broadcast, bez blokady
+	if h.mode.Monitor() || decision.Monitor {
+		ctx, cancel := h.call()
+		defer cancel()
+		recordWouldBlock(ctx, h.db, decision)
+		return
+	}
+
+	// 4. Czas blokady na podstawie historii
+	ctx, cancel = h.call()
+	unblockTime, duration, err := h.escalation.UnblockTime(ctx, h.db, decision.IP, time.Now())
+	cancel()
+	if err != nil {
+		// Unknown history is treated as a first offence, never as permanent
+		unblockTime, duration = h.escalation.After(0, time.Now())
+		slog.Error("Block history lookup failed, using first block duration", "ip", decision.IP, "duration", duration, "error", err)
+	}
+
+	// 5. Blokuj w firewall
+	if err := h.enforcer.Block(decision.IP); err != nil {
+		slog.Error("Firewall block failed", "ip", decision.IP, "error", err) // ← DODANE
+		log.Printf("❌ Firewall block failed for %s: %v", decision.IP, err)
+		return
+	}
+
+	// 6. Zapisz do bazy z pełnymi danymi
+	categoriesStr := formatCategories(decision.Categories)
+
+	ctx, cancel = h.call()
+	defer cancel()
+	if err := h.db.AddBlocked(
+		ctx,
+		decision.IP,
+		decision.Reason,
+		decision.Score,
+		decision.AlertCount,
+		decision.SeverityScore,
+		decision.UniquePorts,
+		decision.UniqueProtos,
+		decision.UniqueFlows,
+		categoriesStr,
+		decision.Details,
+		unblockTime,
+	); err != nil {
+		slog.Error("Failed to save block to database", "ip", decision.IP, "error", err) // ← DODANE
+		log.Printf("Database save failed for %s: %v", decision.IP, err)
+	} else {
+		slog.Info("IP blocked successfully", "ip", decision.IP, "score", decision.Score, "reason", decision.Reason, "duration", duration) // ← DODANE
+		fmt.Printf("🚫 BLOCKED: %s - %s (Score: %d)\n", decision.IP, decision.Reason, decision.Score)
+
+		api.BroadcastBlockWithScore(
+			decision.IP,
+			decision.Reason,
+			decision.Score,
+			decision.AlertCount,
+			decision.SeverityScore,
+			decision.UniquePorts,
+			decision.UniqueProtos,
+			decision.UniqueFlows,
+			formatCategories(decision.Categories),
+			decision.Details,
+			unblockTime,
+		)
+	}
+}
+
+// Warning when Suricata's stats show the kernel dropping packets (alerts may be missed)
+func captureDropWatcher() suricata.EventHandler {
+	var lastDrops atomic.Int64
+	return func(e suricata.EveEvent) {
+		if e.Stats == nil {
+			return
+		}
+		drops := e.Stats.Capture.KernelDrops
+		if prev := lastDrops.Swap(drops); drops > prev {
+			slog.Warn("Suricata capture is dropping packets",
+				"kernel_drops", drops, "new_drops", drops-prev,
+				"kernel_packets", e.Stats.Capture.KernelPackets)
 		}
 	}
 }
 
+// Persisting and broadcasting a decision that monitor mode kept from the firewall
+func recordWouldBlock(ctx context.Context, db data.Repository, decision suricata.BlockDecision) {
+	if err := db.LogActivity(ctx, "would_block", decision.IP, decision.Reason, fmt.Sprintf("%d", decision.Score)); err != nil {
+		slog.Error("Failed to save would_block to database", "ip", decision.IP, "error", err)
+	}
+
+	slog.Info("IP would be blocked (monitor mode)", "ip", decision.IP, "score", decision.Score, "reason", decision.Reason)
+	fmt.Printf("👁  WOULD BLOCK: %s - %s (Score: %d)\n", decision.IP, decision.Reason, decision.Score)
+
+	api.BroadcastWouldBlock(
+		decision.IP,
+		decision.Reason,
+		decision.Score,
+		decision.AlertCount,
+		decision.SeverityScore,
+		decision.UniquePorts,
+		decision.UniqueProtos,
+		decision.UniqueFlows,
+		formatCategories(decision.Categories),
+		decision.Details,
+	)
+}
+
+// Re-reading config and swapping in the new policy; settings that need a restart are only reported
+func reloadPolicy(current *config.Config, db data.Repository, wm *suricata.WindowManager, whitelist *suricata.Whitelist) error {
+	next, err := config.Load(os.Args[1:])
+	if err != nil {
+		return err
+	}
+
+	policy, err := next.Policy()
+	if err != nil {
+		return err
+	}
+
+	if err := whitelist.Load(context.Background(), db); err != nil {
+		return fmt.Errorf("whitelist reload: %w", err)
+	}
+	wm.SetPolicy(policy)
+
+	if next.Database != current.Database || next.HTTP != current.HTTP ||
+		next.Suricata != current.Suricata || next.Analysis != current.Analysis ||
+		next.Firewall != current.Firewall || next.Retention != current.Retention || !slices.Equal(next.Inputs, current.Inputs) ||
+		!reflect.DeepEqual(next.AccessLog, current.AccessLog) || !reflect.DeepEqual(next.Shadows, current.Shadows) {
+		slog.Warn("Config changes outside scoring/suppress need a restart to take effect")
+	}
+
+	slog.Info("Policy reloaded", "threshold", policy.Scoring.Threshold, "suppress_rules", len(policy.Suppress))
+	return nil
+}
+
+// Tokens and effective rate limits of the ingest sources
+func ingestSettings(cfg config.IngestConfig, alerts chan<- suricata.Alert) api.IngestSettings {
+	s := api.IngestSettings{
+		Sources: make(map[string]string, len(cfg.Sources)),
+		Limits:  make(map[string]api.RateLimit, len(cfg.Sources)),
+		Alerts:  alerts,
+	}
+	for name, src := range cfg.Sources {
+		rate, burst := cfg.Limit(name)
+		s.Sources[name] = src.Token
+		s.Limits[name] = api.RateLimit{Rate: rate, Burst: burst}
+	}
+	return s
+}
+
 // Helper: konwertuje map[string]int do stringa "Category1:5, Category2:3"
 type catPair struct {
 	name  string
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"firefighter/data"
+)
+
+// firefighter migrate status [flags]
+//
+// Migrations run by themselves when the service opens the database; status
+// only reads it and lists what is applied and what the next start would apply.
+func runMigrate(args []string) error {
+	if len(args) == 0 || args[0] != "status" {
+		return errors.New("usage: firefighter migrate status [flags]")
+	}
+
+	fs := flag.NewFlagSet("firefighter migrate status", flag.ContinueOnError)
+	cfg, err := loadOfflineConfig(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	status, err := data.Migrations(cfg.Database.Path)
+	if err != nil {
+		return fmt.Errorf("unable to read schema version: %w", err)
+	}
+
+	current, pending := 0, 0
+	fmt.Printf("Database: %s\n\n", cfg.Database.Path)
+	for _, m := range status {
+		applied := "pending"
+		if m.AppliedAt != 0 {
+			applied = time.Unix(m.AppliedAt, 0).Format(time.DateTime)
+			current = m.Version
+		} else {
+			pending++
+		}
+		fmt.Printf("  %04d  %-24s %s\n", m.Version, m.Name, applied)
+	}
+	fmt.Printf("\nSchema version: %d, pending: %d\n", current, pending)
+	return nil
+}
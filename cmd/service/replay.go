@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	api "firefighter/api"
+	"firefighter/config"
+	suricata "firefighter/core"
+	"firefighter/data"
+)
+
+// Alerts written to the database per transaction
+const importBatch = 1000
+
+// firefighter replay [flags] eve.json[.gz]...
+//
+// Feeds archived EVE files through the same parsing as the socket reader,
+// scored by WindowManager on event time.
+// Default: only reports what would have been blocked, nothing is written.
+// --enforce imports the alerts with their original timestamps and enforces
+// decisions like the service, with block durations running from now;
+// --import-only only fills the alerts table.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("firefighter replay", flag.ContinueOnError)
+	speed := fs.Float64("speed", 0, "replay speed relative to the capture (60 = a minute per second), 0 = as fast as possible")
+	enforce := fs.Bool("enforce", false, "import alerts and block like the service (default: only report what would have been blocked)")
+	fs.Bool("no-enforce", true, "the default, kept for older scripts")
+	importOnly := fs.Bool("import-only", false, "only import alerts into the database, no analysis")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: firefighter replay [flags] eve.json[.gz]...")
+		fs.PrintDefaults()
+	}
+
+	cfg, err := loadOfflineConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		fs.Usage()
+		return errors.New("replay: no input files")
+	}
+	if *enforce && *importOnly {
+		return errors.New("replay: --enforce and --import-only are mutually exclusive")
+	}
+	if *speed < 0 {
+		return errors.New("replay: --speed must not be negative")
+	}
+
+	db, err := data.New(cfg.Database.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	defer db.Close()
+
+	var enforcer suricata.Enforcer
+	if *enforce {
+		if enforcer, err = suricata.NewEnforcer(cfg.Firewall.Driver); err != nil {
+			return fmt.Errorf("unable to set up firewall backend: %w", err)
+		}
+		go api.StartHub()
+	}
+	r, err := newReplay(cfg, db, enforcer, *importOnly)
+	if err != nil {
+		return err
+	}
+	r.speed = *speed
+
+	started := time.Now()
+	for _, path := range files {
+		if err := r.replayFile(path); err != nil {
+			return err
+		}
+	}
+	r.report(len(files), time.Since(started))
+	return nil
+}
+
+// Analysis of a replay run; decisions go to enforcer when set (--enforce),
+// otherwise blocks are only simulated and nothing is written
+func newReplay(cfg *config.Config, db data.Repository, enforcer suricata.Enforcer, importOnly bool) (*replay, error) {
+	r := &replay{
+		events:  suricata.NewEventDispatcher(),
+		clock:   suricata.NewManualClock(time.Time{}),
+		db:      db,
+		writeDB: enforcer != nil || importOnly,
+	}
+	if importOnly {
+		return r, nil
+	}
+
+	whitelist := suricata.NewWhitelist()
+	if err := whitelist.Load(context.Background(), db); err != nil {
+		return nil, fmt.Errorf("unable to load whitelist: %w", err)
+	}
+
+	r.wm = suricata.NewWindowManager(cfg.Analysis.Window)
+	policy, _ := cfg.Policy() // validated in config.Load
+	r.wm.SetPolicy(policy)
+	r.wm.IPv6Prefix = cfg.Analysis.IPv6Prefix
+	r.wm.Lateness = cfg.Analysis.Lateness
+	r.wm.MaxSkew = cfg.Analysis.MaxSkew
+	r.wm.Whitelist = whitelist
+	r.wm.Clock = r.clock
+
+	steps, _ := cfg.Blocking.Steps() // validated in config.Load
+	escalation := suricata.EscalationPolicy{Steps: steps, Lookback: cfg.Blocking.Lookback}
+
+	if enforcer == nil {
+		r.simulated = suricata.NewSimulatedBlocks(db, r.clock, escalation)
+		return r, nil
+	}
+	r.decisions = &decisionHandler{
+		db:         db,
+		enforcer:   enforcer,
+		whitelist:  whitelist,
+		mode:       suricata.NewEnforcementMode(cfg.Blocking.Mode),
+		escalation: escalation,
+		timeout:    cfg.Database.Timeout,
+	}
+	return r, nil
+}
+
+// State of one replay run
+type replay struct {
+	speed  float64
+	events *suricata.EventDispatcher
+	clock  *suricata.ManualClock
+	db     data.Repository
+	// Alerts are imported with --enforce and --import-only
+	writeDB bool
+
+	// nil with --import-only
+	wm *suricata.WindowManager
+	// Exactly one is set when analysing: --enforce or the simulation
+	decisions *decisionHandler
+	simulated *suricata.SimulatedBlocks
+
+	batch []data.AlertRecord
+	err   error
+
+	// Pacing: event time ↔ wall time of the first alert
+	firstEvent time.Time
+	firstWall  time.Time
+	lastSweep  time.Time
+
+	alerts      int
+	imported    int
+	decided     int
+	wouldBlocks []wouldBlock
+}
+
+type wouldBlock struct {
+	at       time.Time
+	decision suricata.BlockDecision
+	duration time.Duration
+}
+
+func (r *replay) replayFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// gzip is recognised by content, rotated archives are not always named .gz
+	var in io.Reader = bufio.NewReader(f)
+	if magic, _ := in.(*bufio.Reader).Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	fmt.Printf("Replaying %s\n", path)
+	if err := suricata.DecodeLines(in, r.events, r.handle); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return r.flush()
+}
+
+// Called for every decoded alert, in file order
+func (r *replay) handle(alert suricata.Alert) {
+	if r.err != nil {
+		return
+	}
+	r.alerts++
+	r.pace(alert.ParsedTime)
+
+	if r.writeDB {
+		r.batch = append(r.batch, alert.Record())
+		if len(r.batch) >= importBatch {
+			if r.err = r.flush(); r.err != nil {
+				return
+			}
+		}
+	}
+
+	if r.wm == nil {
+		return
+	}
+
+	r.clock.Set(alert.ParsedTime)
+	if now := r.clock.Now(); now.Sub(r.lastSweep) >= time.Minute {
+		r.wm.Sweep()
+		if r.simulated != nil {
+			r.simulated.Prune(now)
+		}
+		r.lastSweep = now
+	}
+
+	key := r.wm.Add(alert)
+	if r.simulated != nil {
+		if decision, ok := r.wm.AnalyzeIP(context.Background(), r.simulated, key); ok {
+			r.decided++
+			r.recordWouldBlock(decision)
+		}
+		return
+	}
+
+	ctx, cancel := r.decisions.call()
+	decision, ok := r.wm.AnalyzeIP(ctx, r.db, key)
+	cancel()
+	if !ok {
+		return
+	}
+	// Enforcement reads the database, so it must see the alerts before the decision
+	if r.err = r.flush(); r.err != nil {
+		return
+	}
+	r.decided++
+	r.decisions.handle(decision)
+}
+
+// Sleeping so event time advances speed times faster than wall time
+func (r *replay) pace(t time.Time) {
+	if r.speed == 0 {
+		return
+	}
+	if r.firstWall.IsZero() {
+		r.firstEvent, r.firstWall = t, time.Now()
+		return
+	}
+	due := r.firstWall.Add(time.Duration(float64(t.Sub(r.firstEvent)) / r.speed))
+	if wait := time.Until(due); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+func (r *replay) flush() error {
+	if len(r.batch) == 0 {
+		return nil
+	}
+	if err := r.db.ImportAlerts(context.Background(), r.batch); err != nil {
+		return fmt.Errorf("alert import failed: %w", err)
+	}
+	r.imported += len(r.batch)
+	r.batch = r.batch[:0]
+	return nil
+}
+
+func (r *replay) recordWouldBlock(decision suricata.BlockDecision) {
+	at := r.clock.Now()
+	// Monitor-only policies never block, so the IP keeps being scored
+	var duration time.Duration
+	if !decision.Monitor {
+		duration = r.simulated.Block(decision.IP)
+	}
+	r.wouldBlocks = append(r.wouldBlocks, wouldBlock{at: at, decision: decision, duration: duration})
+
+	fmt.Printf("👁  %s WOULD BLOCK: %s - %s (Score: %d)\n",
+		at.Format(time.DateTime), decision.IP, decision.Reason, decision.Score)
+}
+
+func (r *replay) report(files int, took time.Duration) {
+	fmt.Println("=== REPLAY SUMMARY ===")
+	fmt.Printf("Files: %d, alerts: %d, took %s\n", files, r.alerts, took.Round(time.Millisecond))
+	counts := r.events.Counts()
+	for _, eventType := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Printf("  %-10s %d\n", eventType, counts[eventType])
+	}
+	if r.writeDB {
+		fmt.Printf("Imported alerts: %d\n", r.imported)
+	}
+	if r.wm == nil {
+		return
+	}
+	fmt.Printf("Late alerts dropped: %d\n", r.wm.LateDropped())
+	fmt.Printf("Block decisions: %d\n", r.decided)
+
+	if r.simulated == nil {
+		return
+	}
+	for _, wb := range r.wouldBlocks {
+		until := "permanent"
+		switch {
+		case wb.decision.Monitor:
+			until = "monitor"
+		case wb.duration > 0:
+			until = wb.duration.String()
+		}
+		fmt.Printf("  %s  %-40s score %-4d %-10s %s\n",
+			wb.at.Format(time.DateTime), wb.decision.IP, wb.decision.Score, until,
+			formatCategories(wb.decision.Categories))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"firefighter/config"
+	suricata "firefighter/core"
+	"firefighter/data"
+)
+
+// decisionRepo that also takes imported alerts
+type replayRepo struct {
+	decisionRepo
+	imported int
+}
+
+func (r *replayRepo) ImportAlerts(_ context.Context, alerts []data.AlertRecord) error {
+	r.imported += len(alerts)
+	return nil
+}
+
+var replayStart = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// High severity alerts from a scanner, a second apart, and one from a bystander
+func writeEve(t *testing.T) string {
+	t.Helper()
+	var lines []string
+	for i, src := range []string{"203.0.113.9", "203.0.113.9", "198.51.100.1", "203.0.113.9"} {
+		ts := replayStart.Add(time.Duration(i) * time.Second).Format("2006-01-02T15:04:05.000000-0700")
+		lines = append(lines, fmt.Sprintf(`{"timestamp":%q,"flow_id":%d,"event_type":"alert","src_ip":%q,"src_port":40000,"dest_ip":"192.0.2.1","dest_port":%d,"proto":"TCP","alert":{"signature_id":%d,"signature":"scan","category":"Attempted Information Leak","severity":1}}`,
+			ts, i+1, src, 22+i, 2000000+i))
+	}
+	lines = append(lines, `{"timestamp":"2025-06-01T12:00:05.000000+0000","event_type":"stats","stats":{"uptime":5}}`)
+
+	path := filepath.Join(t.TempDir(), "eve.json")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name       string
+		enforce    bool
+		importOnly bool
+		blocked    []string
+		imported   int
+		decided    int
+	}{
+		{name: "report only", decided: 1},
+		{name: "enforce", enforce: true, blocked: []string{"203.0.113.9"}, imported: 4, decided: 1},
+		{name: "import only", importOnly: true, imported: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &replayRepo{}
+			dry := suricata.NewDryRunEnforcer()
+			var enforcer suricata.Enforcer
+			if tt.enforce {
+				enforcer = dry
+			}
+
+			r, err := newReplay(config.Default(), repo, enforcer, tt.importOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.replayFile(writeEve(t)); err != nil {
+				t.Fatal(err)
+			}
+
+			if list, _ := dry.List(); len(list)+len(tt.blocked) > 0 && !reflect.DeepEqual(list, tt.blocked) {
+				t.Errorf("firewall holds %q, want %q", list, tt.blocked)
+			}
+			if len(repo.added) != len(tt.blocked) {
+				t.Errorf("%d blocks saved, want %d", len(repo.added), len(tt.blocked))
+			}
+			if repo.imported != tt.imported || r.imported != tt.imported {
+				t.Errorf("imported %d (counted %d), want %d", repo.imported, r.imported, tt.imported)
+			}
+			if r.alerts != 4 || r.decided != tt.decided {
+				t.Errorf("%d alerts, %d decisions, want 4, %d", r.alerts, r.decided, tt.decided)
+			}
+			if len(repo.wouldBlocks) != 0 {
+				t.Errorf("would_block written: %q", repo.wouldBlocks)
+			}
+
+			if tt.enforce || tt.importOnly {
+				return
+			}
+			// Reported on event time of the second alert, for the escalation's first step
+			at := replayStart.Add(time.Second)
+			if len(r.wouldBlocks) != 1 || r.wouldBlocks[0].decision.IP != "203.0.113.9" ||
+				!r.wouldBlocks[0].at.Equal(at) || r.wouldBlocks[0].duration != time.Hour {
+				t.Errorf("would block %+v, want 203.0.113.9 at %s for 1h", r.wouldBlocks, at)
+			}
+		})
+	}
+}
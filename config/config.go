@@ -0,0 +1,661 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	suricata "firefighter/core"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Used when neither -config nor FIREFIGHTER_CONFIG is given; missing file = defaults only
+const DefaultPath = "/etc/firefighter/config.yaml"
+
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	Log      LogConfig      `yaml:"log"`
+	HTTP     HTTPConfig     `yaml:"http"`
+	Suricata SuricataConfig `yaml:"suricata"`
+	// Alert sources; empty = the single Suricata input from suricata.input
+	Inputs []suricata.InputConfig `yaml:"inputs"`
+	// POST /api/v1/alerts
+	Ingest IngestConfig `yaml:"ingest"`
+	// Patterns for nginx_access inputs; lists replace the defaults as a whole
+	AccessLog suricata.AccessLogRules `yaml:"access_log"`
+	Analysis  AnalysisConfig          `yaml:"analysis"`
+	Firewall  FirewallConfig          `yaml:"firewall"`
+	Blocking  BlockingConfig          `yaml:"blocking"`
+	Retention RetentionConfig         `yaml:"retention"`
+	Scoring   suricata.ScoringModel   `yaml:"scoring"`
+	Suppress  []suricata.SuppressRule `yaml:"suppress"`
+	// Policy-level monitor mode, reloadable
+	Monitor bool `yaml:"monitor"`
+	// Alternative policies scored next to the live one, never enforced
+	Shadows []ShadowConfig `yaml:"shadow_policies"`
+
+	// File the config was read from, empty when running on defaults
+	Path string `yaml:"-"`
+}
+
+type DatabaseConfig struct {
+	Path string `yaml:"path"`
+	// Alerts are written in transactions of up to batch_size, at least every flush_interval
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// Alerts waiting to be written; beyond this they are dropped, analysis goes on
+	QueueSize int `yaml:"queue_size"`
+	// Deadline of each database call on the alert path (alert batches, block
+	// checks and writes), so a stuck database cannot hold up analysis
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type LogConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+type HTTPConfig struct {
+	Listen string `yaml:"listen"`
+	// Bearer token for admin endpoints (policy reload etc.), empty disables them
+	APIToken string `yaml:"api_token"`
+	// Built frontend (vite dist), empty disables static file serving
+	FrontendDir string `yaml:"frontend_dir"`
+}
+
+type IngestConfig struct {
+	// Source label → bearer token, empty disables the endpoint
+	Sources map[string]IngestSource `yaml:"sources"`
+	// Alerts per second per source (0 = unlimited) and burst size
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Ingest source: either just its token or a mapping that can also override
+// the rate limit ("honeypot: {token: ..., rate: 200, burst: 2000}")
+type IngestSource struct {
+	Token string   `yaml:"token"`
+	Rate  *float64 `yaml:"rate"`
+	Burst *int     `yaml:"burst"`
+}
+
+func (s *IngestSource) UnmarshalYAML(unmarshal func(any) error) error {
+	var token string
+	if err := unmarshal(&token); err == nil {
+		*s = IngestSource{Token: token}
+		return nil
+	}
+	type plain IngestSource
+	return unmarshal((*plain)(s))
+}
+
+// Rate limit of a source, ingest.rate/burst unless it sets its own
+func (c IngestConfig) Limit(name string) (float64, int) {
+	rate, burst := c.Rate, c.Burst
+	if src, ok := c.Sources[name]; ok {
+		if src.Rate != nil {
+			rate = *src.Rate
+		}
+		if src.Burst != nil {
+			burst = *src.Burst
+		}
+	}
+	return rate, burst
+}
+
+type SuricataConfig struct {
+	// Start and stop the Suricata process (off for hosts that only read Snort/remote logs)
+	Manage     bool   `yaml:"manage"`
+	ConfigPath string `yaml:"config_path"`
+	Interface  string `yaml:"interface"`
+	SocketPath string `yaml:"socket_path"`
+	// Where alerts come from: "socket" (eve unix_stream) or "file" (tail EveFile)
+	Input   string `yaml:"input"`
+	EveFile string `yaml:"eve_file"`
+	// First start without a checkpoint reads the existing eve file instead of skipping to its end
+	EveFromStart bool `yaml:"eve_from_start"`
+}
+
+type AnalysisConfig struct {
+	Window time.Duration `yaml:"window"`
+	// How late (behind the newest alert) an alert may arrive and still be scored
+	Lateness time.Duration `yaml:"lateness"`
+	// How far ahead of the host clock an alert timestamp may be, later ones are clamped
+	MaxSkew time.Duration `yaml:"max_skew"`
+	// Group IPv6 sources by this prefix length, 0 = per address
+	IPv6Prefix int `yaml:"ipv6_prefix"`
+}
+
+type FirewallConfig struct {
+	Driver string `yaml:"driver"`
+}
+
+type BlockingConfig struct {
+	// Global startup mode: "enforce" or "monitor" (switchable at runtime via the API)
+	Mode string `yaml:"mode"`
+	// Durations of consecutive blocks, "permanent" for no expiry; the last one repeats
+	Durations      []string      `yaml:"durations"`
+	Lookback       time.Duration `yaml:"lookback"`
+	ExpiryInterval time.Duration `yaml:"expiry_interval"`
+}
+
+// How long raw rows are kept, 0 = forever; counts of purged alerts and
+// blocks stay in the hourly/daily rollups
+type RetentionConfig struct {
+	Alerts      time.Duration `yaml:"alerts"`
+	ActivityLog time.Duration `yaml:"activity_log"`
+	// Lifted blocks only; must cover blocking.lookback
+	BlockedIPs      time.Duration `yaml:"blocked_ips"`
+	ShadowDecisions time.Duration `yaml:"shadow_decisions"`
+	// Older hourly rollups are folded into daily ones
+	HourlyRollups time.Duration `yaml:"hourly_rollups"`
+	Interval      time.Duration `yaml:"interval"`
+	// Rows deleted per statement, keeps each write lock short
+	BatchSize int `yaml:"batch_size"`
+}
+
+type ShadowConfig struct {
+	Name string `yaml:"name"`
+	// Complete scoring model; omitted = the live scoring section
+	Scoring *suricata.ScoringModel `yaml:"scoring"`
+	// Omitted = the live suppress rules, [] = none
+	Suppress []suricata.SuppressRule `yaml:"suppress"`
+	// 0 = analysis.window
+	Window time.Duration `yaml:"window"`
+}
+
+// Values matching the behaviour before the config file existed
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			Path:          "/home/lucas/firefighter/data/firefighter.db",
+			BatchSize:     200,
+			FlushInterval: 500 * time.Millisecond,
+			QueueSize:     10000,
+			Timeout:       5 * time.Second,
+		},
+		Log: LogConfig{Dir: "/var/log/firefighter"},
+		HTTP: HTTPConfig{
+			Listen:      ":8080",
+			FrontendDir: "/home/lucas/firefighter/frontend/dist",
+		},
+		Suricata: SuricataConfig{
+			Manage:     true,
+			ConfigPath: "/etc/suricata/suricata.yaml",
+			Interface:  "enp0s3",
+			SocketPath: "/var/run/suricata/eve.sock",
+			Input:      "socket",
+			EveFile:    "/var/log/suricata/eve.json",
+		},
+		Analysis: AnalysisConfig{
+			Window:   600 * time.Second,
+			Lateness: suricata.DefaultLateness,
+			MaxSkew:  suricata.DefaultMaxSkew,
+		},
+		AccessLog: suricata.DefaultAccessLogRules(),
+		Ingest:    IngestConfig{Rate: 50, Burst: 500},
+		Firewall:  FirewallConfig{Driver: "firewalld"},
+		Blocking: BlockingConfig{
+			Mode:           suricata.ModeEnforce,
+			Durations:      []string{"1h", "24h", "168h", "permanent"},
+			ExpiryInterval: time.Minute,
+		},
+		Retention: RetentionConfig{
+			HourlyRollups: 90 * 24 * time.Hour,
+			Interval:      time.Hour,
+			BatchSize:     500,
+		},
+		Scoring: suricata.DefaultScoring(),
+	}
+}
+
+// Load builds the config from defaults, the YAML file, FIREFIGHTER_* environment
+// variables and command line flags, in that order of precedence, and validates it
+func Load(args []string) (*Config, error) {
+	return LoadFlags(flag.NewFlagSet("firefighter", flag.ContinueOnError), args)
+}
+
+// LoadFlags is Load on the caller's flag set, so subcommands can register
+// their own flags next to the config ones
+func LoadFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	configPath := fs.String("config", "", "path to YAML config file (env FIREFIGHTER_CONFIG)")
+	dbPath := fs.String("db", "", "SQLite database path")
+	logDir := fs.String("log-dir", "", "directory for app.log")
+	listen := fs.String("listen", "", "HTTP listen address, e.g. :8080")
+	frontend := fs.String("frontend", "", "frontend dist directory, \"none\" disables it")
+	iface := fs.String("interface", "", "network interface Suricata listens on")
+	suricataConfig := fs.String("suricata-config", "", "suricata.yaml path")
+	socket := fs.String("socket", "", "EVE unix socket path")
+	input := fs.String("input", "", "alert input: socket or file")
+	eveFile := fs.String("eve-file", "", "eve.json path tailed by the file input")
+	window := fs.Duration("window", 0, "sliding window duration")
+	lateness := fs.Duration("lateness", 0, "how late an alert may arrive and still be scored")
+	threshold := fs.Int("threshold", 0, "block score threshold")
+	ipv6Prefix := fs.Int("ipv6-prefix", -1, "aggregate IPv6 sources by prefix length, 0 = per address")
+	firewall := fs.String("firewall", "", "firewall driver: firewalld, nftables, ipset, dryrun")
+	mode := fs.String("mode", "", "enforce or monitor (log would_block only)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path, explicit := *configPath, true
+	if path == "" {
+		path = os.Getenv("FIREFIGHTER_CONFIG")
+	}
+	if path == "" {
+		path, explicit = DefaultPath, false
+	}
+
+	if err := cfg.readFile(path, explicit); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	// Only flags given on the command line override file and env
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "db":
+			cfg.Database.Path = *dbPath
+		case "log-dir":
+			cfg.Log.Dir = *logDir
+		case "listen":
+			cfg.HTTP.Listen = *listen
+		case "frontend":
+			cfg.HTTP.FrontendDir = *frontend
+		case "interface":
+			cfg.Suricata.Interface = *iface
+		case "suricata-config":
+			cfg.Suricata.ConfigPath = *suricataConfig
+		case "socket":
+			cfg.Suricata.SocketPath = *socket
+		case "input":
+			cfg.Suricata.Input = *input
+		case "eve-file":
+			cfg.Suricata.EveFile = *eveFile
+		case "window":
+			cfg.Analysis.Window = *window
+		case "lateness":
+			cfg.Analysis.Lateness = *lateness
+		case "threshold":
+			cfg.Scoring.Threshold = *threshold
+		case "ipv6-prefix":
+			cfg.Analysis.IPv6Prefix = *ipv6Prefix
+		case "firewall":
+			cfg.Firewall.Driver = *firewall
+		case "mode":
+			cfg.Blocking.Mode = *mode
+		}
+	})
+
+	if cfg.HTTP.FrontendDir == "none" {
+		cfg.HTTP.FrontendDir = ""
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string, explicit bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	if err := yaml.UnmarshalWithOptions(content, c, yaml.DisallowUnknownField()); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	c.Path = path
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	strs := map[string]*string{
+		"FIREFIGHTER_DB":              &c.Database.Path,
+		"FIREFIGHTER_LOG_DIR":         &c.Log.Dir,
+		"FIREFIGHTER_LISTEN":          &c.HTTP.Listen,
+		"FIREFIGHTER_API_TOKEN":       &c.HTTP.APIToken,
+		"FIREFIGHTER_FRONTEND":        &c.HTTP.FrontendDir,
+		"FIREFIGHTER_INTERFACE":       &c.Suricata.Interface,
+		"FIREFIGHTER_SURICATA_CONFIG": &c.Suricata.ConfigPath,
+		"FIREFIGHTER_SOCKET":          &c.Suricata.SocketPath,
+		"FIREFIGHTER_INPUT":           &c.Suricata.Input,
+		"FIREFIGHTER_EVE_FILE":        &c.Suricata.EveFile,
+		"FIREFIGHTER_FIREWALL":        &c.Firewall.Driver,
+		"FIREFIGHTER_MODE":            &c.Blocking.Mode,
+	}
+	for name, dst := range strs {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"FIREFIGHTER_WINDOW":   &c.Analysis.Window,
+		"FIREFIGHTER_LATENESS": &c.Analysis.Lateness,
+	}
+	for name, dst := range durations {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = d
+		}
+	}
+
+	ints := map[string]*int{
+		"FIREFIGHTER_THRESHOLD":   &c.Scoring.Threshold,
+		"FIREFIGHTER_IPV6_PREFIX": &c.Analysis.IPv6Prefix,
+	}
+	for name, dst := range ints {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: expected integer, got %q", name, v)
+			}
+			*dst = n
+		}
+	}
+	return nil
+}
+
+// Validate reports every problem at once so a broken deployment is fixed in one go
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Database.Path == "" {
+		fail("database.path must not be empty")
+	} else if dir := filepath.Dir(c.Database.Path); !isDir(dir) {
+		fail("database.path: directory %s does not exist", dir)
+	}
+	if c.Database.BatchSize <= 0 {
+		fail("database.batch_size must be positive, got %d", c.Database.BatchSize)
+	}
+	if c.Database.FlushInterval <= 0 {
+		fail("database.flush_interval must be positive, got %s", c.Database.FlushInterval)
+	}
+	if c.Database.QueueSize < c.Database.BatchSize {
+		fail("database.queue_size must be at least database.batch_size, got %d", c.Database.QueueSize)
+	}
+	if c.Database.Timeout <= 0 {
+		fail("database.timeout must be positive, got %s", c.Database.Timeout)
+	}
+
+	if c.Log.Dir == "" {
+		fail("log.dir must not be empty")
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTP.Listen); err != nil {
+		fail("http.listen %q: expected host:port, e.g. :8080", c.HTTP.Listen)
+	}
+	if c.HTTP.FrontendDir != "" && !isDir(c.HTTP.FrontendDir) {
+		fail("http.frontend_dir: %s does not exist (use \"none\" to serve the API only)", c.HTTP.FrontendDir)
+	}
+
+	// Only needed to start Suricata
+	if c.Suricata.Manage {
+		if c.Suricata.Interface == "" {
+			fail("suricata.interface must not be empty when suricata.manage is true")
+		}
+		if c.Suricata.ConfigPath == "" {
+			fail("suricata.config_path must not be empty when suricata.manage is true")
+		}
+	}
+	if len(c.Inputs) == 0 {
+		switch c.Suricata.Input {
+		case "socket":
+			if c.Suricata.SocketPath == "" {
+				fail("suricata.socket_path must not be empty")
+			}
+		case "file":
+			if c.Suricata.EveFile == "" {
+				fail("suricata.eve_file must not be empty")
+			}
+		default:
+			fail("suricata.input %q: expected socket or file", c.Suricata.Input)
+		}
+	}
+	types := suricata.InputTypes()
+	for i, in := range c.Inputs {
+		if !slices.Contains(types, in.Type) {
+			fail("inputs[%d].type %q: expected one of %s", i, in.Type, strings.Join(types, ", "))
+		}
+		if in.Path == "" {
+			fail("inputs[%d].path must not be empty", i)
+		}
+	}
+	if c.Ingest.Rate < 0 {
+		fail("ingest.rate must not be negative")
+	}
+	if c.Ingest.Rate > 0 && c.Ingest.Burst <= 0 {
+		fail("ingest.burst must be positive when ingest.rate is set")
+	}
+	builtin := []string{suricata.SourceSuricata, suricata.SourceSnort, suricata.SourceAuthLog, suricata.SourceNginx}
+	tokens := make(map[string]string)
+	for name, src := range c.Ingest.Sources {
+		switch {
+		case name == "" || slices.Contains(builtin, name):
+			fail("ingest.sources: %q is reserved", name)
+		case len(src.Token) < 16:
+			fail("ingest.sources.%s: token must be at least 16 characters", name)
+		case tokens[src.Token] != "":
+			fail("ingest.sources.%s: token already used by %s", name, tokens[src.Token])
+		}
+		tokens[src.Token] = name
+
+		rate, burst := c.Ingest.Limit(name)
+		if rate < 0 {
+			fail("ingest.sources.%s.rate must not be negative", name)
+		}
+		if rate > 0 && burst <= 0 {
+			fail("ingest.sources.%s.burst must be positive when a rate is set", name)
+		}
+	}
+	if err := c.AccessLog.Validate(); err != nil {
+		for _, e := range unwrapJoined(err) {
+			fail("access_log.%v", e)
+		}
+	}
+
+	if c.Analysis.Window <= 0 {
+		fail("analysis.window must be positive, got %s", c.Analysis.Window)
+	}
+	if c.Analysis.Lateness < 0 {
+		fail("analysis.lateness must not be negative, got %s", c.Analysis.Lateness)
+	}
+	if c.Analysis.MaxSkew < 0 {
+		fail("analysis.max_skew must not be negative, got %s", c.Analysis.MaxSkew)
+	}
+	if c.Analysis.IPv6Prefix < 0 || c.Analysis.IPv6Prefix > 128 {
+		fail("analysis.ipv6_prefix must be between 0 and 128, got %d", c.Analysis.IPv6Prefix)
+	}
+
+	switch c.Firewall.Driver {
+	case "firewalld", "nftables", "ipset", "dryrun":
+	default:
+		fail("firewall.driver %q: expected firewalld, nftables, ipset or dryrun", c.Firewall.Driver)
+	}
+
+	if _, err := c.Policy(); err != nil {
+		for _, e := range unwrapJoined(err) {
+			fail("%v", e)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, sh := range c.Shadows {
+		switch {
+		case sh.Name == "" || sh.Name == "live":
+			fail("shadow_policies[%d].name %q is empty or reserved", i, sh.Name)
+		case names[sh.Name]:
+			fail("shadow_policies[%d].name %q is used twice", i, sh.Name)
+		}
+		names[sh.Name] = true
+		if sh.Window < 0 {
+			fail("shadow_policies[%d].window must not be negative", i)
+		}
+		if _, err := c.ShadowPolicy(sh); err != nil {
+			for _, e := range unwrapJoined(err) {
+				fail("shadow_policies[%d].%v", i, e)
+			}
+		}
+	}
+
+	if c.Blocking.Mode != suricata.ModeEnforce && c.Blocking.Mode != suricata.ModeMonitor {
+		fail("blocking.mode %q: expected enforce or monitor", c.Blocking.Mode)
+	}
+	if _, err := c.Blocking.Steps(); err != nil {
+		fail("blocking.durations: %v", err)
+	}
+	if c.Blocking.Lookback < 0 {
+		fail("blocking.lookback must not be negative")
+	}
+	if c.Blocking.ExpiryInterval <= 0 {
+		fail("blocking.expiry_interval must be positive, got %s", c.Blocking.ExpiryInterval)
+	}
+
+	retention := c.Retention.Tables()
+	for _, table := range slices.Sorted(maps.Keys(retention)) {
+		if retention[table] < 0 {
+			fail("retention.%s must not be negative", table)
+		}
+	}
+	if keep := c.Retention.BlockedIPs; keep > 0 {
+		// Escalation counts previous blocks from blocked_ips
+		if c.Blocking.Lookback == 0 {
+			fail("retention.blocked_ips requires blocking.lookback, escalation counts the whole block history")
+		} else if keep < c.Blocking.Lookback {
+			fail("retention.blocked_ips (%s) must not be shorter than blocking.lookback (%s)", keep, c.Blocking.Lookback)
+		}
+	}
+	// The hourly chart shows the last 168 hours
+	if c.Retention.HourlyRollups < 7*24*time.Hour {
+		fail("retention.hourly_rollups must be at least 168h, got %s", c.Retention.HourlyRollups)
+	}
+	if c.Retention.Interval <= 0 {
+		fail("retention.interval must be positive, got %s", c.Retention.Interval)
+	}
+	if c.Retention.BatchSize <= 0 {
+		fail("retention.batch_size must be positive, got %d", c.Retention.BatchSize)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Configured inputs, or the one the suricata section describes
+func (c *Config) InputList() []suricata.InputConfig {
+	if len(c.Inputs) > 0 {
+		return c.Inputs
+	}
+	if c.Suricata.Input == "file" {
+		return []suricata.InputConfig{{
+			Type:      suricata.InputSuricataFile,
+			Path:      c.Suricata.EveFile,
+			FromStart: c.Suricata.EveFromStart,
+		}}
+	}
+	return []suricata.InputConfig{{Type: suricata.InputSuricataSocket, Path: c.Suricata.SocketPath}}
+}
+
+// Detection policy (scoring + suppression), the part that can be hot-reloaded
+func (c *Config) Policy() (*suricata.Policy, error) {
+	return suricata.NewPolicy(c.Scoring, c.Suppress, c.Monitor)
+}
+
+// Policy of a shadow; never monitor-only, its decisions are not enforced anyway
+func (c *Config) ShadowPolicy(sh ShadowConfig) (*suricata.Policy, error) {
+	scoring := c.Scoring
+	if sh.Scoring != nil {
+		scoring = *sh.Scoring
+	}
+	suppress := c.Suppress
+	if sh.Suppress != nil {
+		suppress = sh.Suppress
+	}
+	return suricata.NewPolicy(scoring, suppress, false)
+}
+
+// Window of a shadow, analysis.window unless set
+func (c *Config) ShadowWindow(sh ShadowConfig) time.Duration {
+	if sh.Window > 0 {
+		return sh.Window
+	}
+	return c.Analysis.Window
+}
+
+// Retention per table, keyed by table name
+func (r RetentionConfig) Tables() map[string]time.Duration {
+	return map[string]time.Duration{
+		"alerts":           r.Alerts,
+		"activity_log":     r.ActivityLog,
+		"blocked_ips":      r.BlockedIPs,
+		"shadow_decisions": r.ShadowDecisions,
+	}
+}
+
+// What the retention job purges: the tables, plus the would_block rows of
+// activity_log, kept as long as the shadow decisions they are compared with
+func (r RetentionConfig) PurgeTargets() map[string]time.Duration {
+	targets := r.Tables()
+	targets["would_block"] = r.ShadowDecisions
+	return targets
+}
+
+// Parsed block durations, 0 stands for permanent
+func (b BlockingConfig) Steps() ([]time.Duration, error) {
+	if len(b.Durations) == 0 {
+		return nil, errors.New("at least one duration is required")
+	}
+
+	steps := make([]time.Duration, 0, len(b.Durations))
+	for _, s := range b.Durations {
+		if strings.EqualFold(strings.TrimSpace(s), "permanent") {
+			steps = append(steps, 0)
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%q is neither a positive duration nor \"permanent\"", s)
+		}
+		steps = append(steps, d)
+	}
+	return steps, nil
+}
+
+// Splitting errors.Join result back into single errors
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Loading YAML from a temporary file, with paths that exist in the sandbox
+func loadYAML(t *testing.T, content string, args ...string) (*Config, error) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := []string{"-config", path, "-db", filepath.Join(dir, "firefighter.db"), "-frontend", "none"}
+	return Load(append(base, args...))
+}
+
+func TestLoadValid(t *testing.T) {
+	cfg, err := loadYAML(t, `
+suricata:
+  manage: false
+  interface: ""
+analysis:
+  window: 5m
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Analysis.Window != 5*time.Minute || cfg.HTTP.FrontendDir != "" {
+		t.Errorf("window %s, frontend %q", cfg.Analysis.Window, cfg.HTTP.FrontendDir)
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	_, err := loadYAML(t, `
+database:
+  batch_size: 0
+http:
+  listen: "8080"
+suricata:
+  manage: true
+  interface: ""
+  config_path: ""
+  input: pipe
+analysis:
+  window: 0s
+  ipv6_prefix: 129
+firewall:
+  driver: pf
+blocking:
+  mode: audit
+  durations: [1h, forever]
+  expiry_interval: 0s
+ingest:
+  sources:
+    suricata: "0123456789abcdef"
+    scanner: short
+    honeypot:
+      token: "fedcba9876543210"
+      rate: -1
+access_log:
+  error_burst: 0
+retention:
+  hourly_rollups: 24h
+  blocked_ips: 720h
+`)
+	if err == nil {
+		t.Fatal("Load() succeeded, want validation errors")
+	}
+
+	want := []string{
+		"database.batch_size must be positive",
+		"http.listen \"8080\"",
+		"suricata.interface must not be empty when suricata.manage is true",
+		"suricata.config_path must not be empty when suricata.manage is true",
+		"suricata.input \"pipe\"",
+		"analysis.window must be positive",
+		"analysis.ipv6_prefix must be between 0 and 128",
+		"firewall.driver \"pf\"",
+		"blocking.mode \"audit\"",
+		"blocking.durations",
+		"blocking.expiry_interval must be positive",
+		"ingest.sources: \"suricata\" is reserved",
+		"ingest.sources.scanner: token must be at least 16 characters",
+		"ingest.sources.honeypot.rate must not be negative",
+		"access_log.error_burst must be at least 1",
+		"retention.blocked_ips requires blocking.lookback",
+		"retention.hourly_rollups must be at least 168h",
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("error does not report %q", w)
+		}
+	}
+	if n := strings.Count(err.Error(), "\n") + 1; n != len(want) {
+		t.Errorf("%d problems reported, want %d:\n%v", n, len(want), err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	content := `
+suricata:
+  manage: false
+analysis:
+  window: 5m
+  lateness: 10s
+blocking:
+  mode: monitor
+`
+	t.Setenv("FIREFIGHTER_WINDOW", "7m")
+	t.Setenv("FIREFIGHTER_MODE", "enforce")
+
+	cfg, err := loadYAML(t, content, "-window", "9m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Analysis.Window != 9*time.Minute {
+		t.Errorf("window %s, want the flag's 9m", cfg.Analysis.Window)
+	}
+	if cfg.Blocking.Mode != "enforce" {
+		t.Errorf("mode %q, want the environment's enforce", cfg.Blocking.Mode)
+	}
+	if cfg.Analysis.Lateness != 10*time.Second {
+		t.Errorf("lateness %s, want the file's 10s", cfg.Analysis.Lateness)
+	}
+	if cfg.Firewall.Driver != "firewalld" {
+		t.Errorf("firewall %q, want the default firewalld", cfg.Firewall.Driver)
+	}
+}
+
+func TestIngestSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources string
+		source  string
+		token   string
+		rate    float64
+		burst   int
+		wantErr string
+	}{
+		{
+			name:    "plain token",
+			sources: `scanner: "0123456789abcdef"`,
+			source:  "scanner", token: "0123456789abcdef", rate: 50, burst: 500,
+		},
+		{
+			name:    "mapping with own limits",
+			sources: "honeypot:\n      token: \"fedcba9876543210\"\n      rate: 200\n      burst: 2000",
+			source:  "honeypot", token: "fedcba9876543210", rate: 200, burst: 2000,
+		},
+		{
+			name:    "mapping with rate only",
+			sources: "honeypot:\n      token: \"fedcba9876543210\"\n      rate: 0",
+			source:  "honeypot", token: "fedcba9876543210", rate: 0, burst: 500,
+		},
+		{
+			name:    "unknown source gets global limits",
+			sources: `scanner: "0123456789abcdef"`,
+			source:  "other", rate: 50, burst: 500,
+		},
+		{
+			name:    "unknown field",
+			sources: "honeypot:\n      token: \"fedcba9876543210\"\n      limit: 5",
+			wantErr: "limit",
+		},
+		{
+			name:    "token used twice",
+			sources: "a: \"0123456789abcdef\"\n    b: \"0123456789abcdef\"",
+			wantErr: "token already used",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadYAML(t, "suricata:\n  manage: false\ningest:\n  sources:\n    "+tt.sources+"\n")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Ingest.Sources[tt.source].Token; got != tt.token {
+				t.Errorf("token %q, want %q", got, tt.token)
+			}
+			rate, burst := cfg.Ingest.Limit(tt.source)
+			if rate != tt.rate || burst != tt.burst {
+				t.Errorf("Limit(%q) = %v, %d, want %v, %d", tt.source, rate, burst, tt.rate, tt.burst)
+			}
+		})
+	}
+}
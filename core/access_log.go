@@ -0,0 +1,337 @@
+package suricata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogRules decide which access log lines become alerts. Each matching
+// request raises at most one alert, checked in order: scanner path,
+// suspicious user agent, error status. Error statuses are ordinary on their
+// own, so they only raise an alert per ErrorBurst of them from one client.
+type AccessLogRules struct {
+	// Regular expressions matched against the request path (without query)
+	ScannerPaths []string `json:"scanner_paths" yaml:"scanner_paths"`
+	// Case-insensitive regular expressions matched against User-Agent
+	UserAgents []string `json:"user_agents" yaml:"user_agents"`
+	// Statuses that count as failed requests (401/403 auth, anything else not-found style)
+	ErrorStatuses []int `json:"error_statuses" yaml:"error_statuses"`
+	// Error statuses one client needs within ErrorWindow (event time) for an alert; 1 = every one
+	ErrorBurst  int           `json:"error_burst" yaml:"error_burst"`
+	ErrorWindow time.Duration `json:"error_window" yaml:"error_window"`
+}
+
+func DefaultAccessLogRules() AccessLogRules {
+	return AccessLogRules{
+		ScannerPaths: []string{
+			`/wp-login\.php`, `/xmlrpc\.php`, `/\.env`, `/\.git/`, `/\.aws/`,
+			`/phpmyadmin`, `/vendor/phpunit/`, `/cgi-bin/`, `/actuator/`,
+			`/etc/passwd`, `\.\./`, `/boaform/`, `/HNAP1`,
+		},
+		UserAgents: []string{
+			`sqlmap`, `nikto`, `nmap`, `masscan`, `zgrab`, `nuclei`,
+			`gobuster`, `dirbuster`, `wpscan`, `fuzz faster u fool`,
+		},
+		ErrorStatuses: []int{401, 403, 404},
+		ErrorBurst:    20,
+		ErrorWindow:   time.Minute,
+	}
+}
+
+// Compiled rules, built once per input
+type accessMatcher struct {
+	paths    []*regexp.Regexp
+	agents   []*regexp.Regexp
+	statuses []int
+}
+
+func (r AccessLogRules) compile() (*accessMatcher, error) {
+	m := &accessMatcher{statuses: r.ErrorStatuses}
+	var errs []error
+	if r.ErrorBurst < 1 {
+		errs = append(errs, fmt.Errorf("error_burst must be at least 1, got %d", r.ErrorBurst))
+	}
+	if r.ErrorBurst > 1 && r.ErrorWindow <= 0 {
+		errs = append(errs, fmt.Errorf("error_window must be positive when error_burst is above 1"))
+	}
+	for i, p := range r.ScannerPaths {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scanner_paths[%d]: %w", i, err))
+			continue
+		}
+		m.paths = append(m.paths, re)
+	}
+	for i, p := range r.UserAgents {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user_agents[%d]: %w", i, err))
+			continue
+		}
+		m.agents = append(m.agents, re)
+	}
+	return m, errors.Join(errs...)
+}
+
+func (r AccessLogRules) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+// One request, whatever format it was logged in
+type accessEntry struct {
+	remoteAddr string
+	time       time.Time
+	method     string
+	path       string
+	status     int
+	userAgent  string
+	host       string
+}
+
+// AccessLogDecoder turns nginx access log lines (combined format or a JSON
+// log_format) into synthetic alerts in the HTTP part of the reserved SID range
+type AccessLogDecoder struct {
+	// Port the server listens on, reported as the alert's destination port
+	Port    int
+	matcher *accessMatcher
+
+	burst  int
+	window time.Duration
+	mu     sync.Mutex
+	errors map[string]*errorCount // by client address
+}
+
+// Error statuses of one client in the window starting at start
+type errorCount struct {
+	start time.Time
+	n     int
+}
+
+// Clients tracked before windows that ended are dropped
+const maxErrorClients = 100000
+
+func NewAccessLogDecoder(rules AccessLogRules, port int) (*AccessLogDecoder, error) {
+	m, err := rules.compile()
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		port = 80
+	}
+	return &AccessLogDecoder{
+		Port:    port,
+		matcher: m,
+		burst:   rules.ErrorBurst,
+		window:  rules.ErrorWindow,
+		errors:  make(map[string]*errorCount),
+	}, nil
+}
+
+// Counting an error status of the client; true when it completes a burst,
+// after which counting starts over
+func (d *AccessLogDecoder) errorBurst(e accessEntry) bool {
+	if d.burst <= 1 {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.errors[e.remoteAddr]
+	if !ok || e.time.Sub(c.start) >= d.window {
+		if !ok && len(d.errors) >= maxErrorClients {
+			d.pruneErrors(e.time)
+		}
+		c = &errorCount{start: e.time}
+		d.errors[e.remoteAddr] = c
+	}
+	c.n++
+	if c.n < d.burst {
+		return false
+	}
+	delete(d.errors, e.remoteAddr)
+	return true
+}
+
+// Dropping clients whose window has ended; caller holds mu
+func (d *AccessLogDecoder) pruneErrors(now time.Time) {
+	for addr, c := range d.errors {
+		if now.Sub(c.start) >= d.window {
+			delete(d.errors, addr)
+		}
+	}
+}
+
+func (d *AccessLogDecoder) Decode(line []byte) (Alert, bool) {
+	var entry accessEntry
+	var ok bool
+	if len(line) > 0 && line[0] == '{' {
+		entry, ok = parseAccessJSON(line)
+	} else {
+		entry, ok = parseCombined(string(line))
+	}
+	if !ok {
+		return Alert{}, false
+	}
+
+	sid, severity, category, name := d.match(entry)
+	if sid == 0 {
+		return Alert{}, false
+	}
+	if sid == SIDHTTPAuthFailure || sid == SIDHTTPNotFound {
+		if !d.errorBurst(entry) {
+			return Alert{}, false
+		}
+		if d.burst > 1 {
+			name = fmt.Sprintf("%s (%d within %s)", name, d.burst, d.window)
+		}
+	}
+
+	alert := Alert{
+		EventType:  EventAlert,
+		Timestamp:  entry.time.Format(time.RFC3339Nano),
+		ParsedTime: entry.time,
+		SrcIP:      entry.remoteAddr,
+		DstPort:    d.Port,
+		Proto:      "TCP",
+		AppProto:   "http",
+		Source:     SourceNginx,
+		HTTP: &EveHTTP{
+			Hostname:  entry.host,
+			URL:       entry.path,
+			UserAgent: entry.userAgent,
+			Method:    entry.method,
+			Status:    entry.status,
+		},
+	}
+	alert.Alert.SignatureID = sid
+	alert.Alert.Severity = severity
+	alert.Alert.Category = category
+	alert.Alert.Signature = fmt.Sprintf("%s: %s %s -> %d", name, entry.method, entry.path, entry.status)
+
+	if err := normalizeAlert(&alert); err != nil {
+		return Alert{}, false
+	}
+	return alert, true
+}
+
+func (d *AccessLogDecoder) match(e accessEntry) (sid, severity int, category, name string) {
+	path := e.path
+	if u, err := url.PathUnescape(path); err == nil {
+		path = u
+	}
+	for _, re := range d.matcher.paths {
+		if re.MatchString(path) {
+			return SIDHTTPScannerPath, 2, CategoryHTTPAttack, "HTTP scanner path"
+		}
+	}
+	for _, re := range d.matcher.agents {
+		if re.MatchString(e.userAgent) {
+			return SIDHTTPScannerAgent, 2, CategoryHTTPAttack, "HTTP scanner user agent"
+		}
+	}
+	if slices.Contains(d.matcher.statuses, e.status) {
+		if e.status == 401 || e.status == 403 {
+			return SIDHTTPAuthFailure, 3, CategoryHTTPErrors, "HTTP auth failure"
+		}
+		return SIDHTTPNotFound, 3, CategoryHTTPErrors, "HTTP error status"
+	}
+	return 0, 0, "", ""
+}
+
+// $remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"
+var combinedRe = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d{3}) \S+(?: "[^"]*" "([^"]*)")?`)
+
+const nginxTimeLocal = "02/Jan/2006:15:04:05 -0700"
+
+func parseCombined(line string) (accessEntry, bool) {
+	m := combinedRe.FindStringSubmatch(line)
+	if m == nil {
+		return accessEntry{}, false
+	}
+	t, err := time.Parse(nginxTimeLocal, m[2])
+	if err != nil {
+		return accessEntry{}, false
+	}
+	status, _ := strconv.Atoi(m[4])
+	method, path := splitRequest(m[3])
+	return accessEntry{
+		remoteAddr: m[1],
+		time:       t,
+		method:     method,
+		path:       path,
+		status:     status,
+		userAgent:  m[5],
+	}, true
+}
+
+// "GET /path?q HTTP/1.1" → method, path without query
+func splitRequest(request string) (string, string) {
+	fields := strings.Fields(request)
+	if len(fields) < 2 {
+		return "", request
+	}
+	path, _, _ := strings.Cut(fields[1], "?")
+	return fields[0], path
+}
+
+// JSON log_format; keys follow the nginx variable names
+func parseAccessJSON(line []byte) (accessEntry, bool) {
+	var raw map[string]any
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return accessEntry{}, false
+	}
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			switch v := raw[k].(type) {
+			case string:
+				if v != "" {
+					return v
+				}
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		return ""
+	}
+
+	e := accessEntry{
+		remoteAddr: str("remote_addr", "client_ip"),
+		method:     str("request_method", "method"),
+		userAgent:  str("http_user_agent", "user_agent"),
+		host:       str("host", "server_name"),
+	}
+	e.status, _ = strconv.Atoi(str("status"))
+
+	if uri := str("request_uri", "uri"); uri != "" {
+		e.path, _, _ = strings.Cut(uri, "?")
+	} else {
+		e.method, e.path = splitRequest(str("request"))
+	}
+
+	if ts := str("time_iso8601", "@timestamp", "time"); ts != "" {
+		e.time, _ = time.Parse(time.RFC3339Nano, ts)
+	}
+	if ts := str("time_local"); ts != "" && e.time.IsZero() {
+		e.time, _ = time.Parse(nginxTimeLocal, ts)
+	}
+	if ts := str("msec"); ts != "" && e.time.IsZero() {
+		if f, err := strconv.ParseFloat(ts, 64); err == nil {
+			e.time = time.UnixMilli(int64(f * 1000))
+		}
+	}
+	if e.time.IsZero() {
+		e.time = time.Now()
+	}
+
+	return e, e.remoteAddr != "" && e.status != 0
+}
@@ -0,0 +1,210 @@
+package suricata
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAccessLogDecode(t *testing.T) {
+	rules := DefaultAccessLogRules()
+	rules.ErrorBurst = 1
+
+	tests := []struct {
+		name     string
+		line     string
+		ok       bool
+		sid      int
+		category string
+		src      string
+		host     string
+		path     string
+		at       time.Time
+	}{
+		{
+			name: "combined scanner path",
+			line: `198.51.100.7 - - [02/Jan/2026:03:04:05 +0100] "GET /wp-login.php?x=1 HTTP/1.1" 404 162 "-" "Mozilla/5.0"`,
+			ok:   true, sid: SIDHTTPScannerPath, category: CategoryHTTPAttack, src: "198.51.100.7", path: "/wp-login.php",
+			at: time.Date(2026, 1, 2, 2, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "escaped traversal",
+			line: `198.51.100.7 - - [02/Jan/2026:03:04:05 +0000] "GET /static/%2e%2e/%2e%2e/etc/passwd HTTP/1.1" 400 0 "-" "curl/8"`,
+			ok:   true, sid: SIDHTTPScannerPath, category: CategoryHTTPAttack, src: "198.51.100.7", path: "/static/%2e%2e/%2e%2e/etc/passwd",
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "scanner user agent on a good request",
+			line: `2001:db8::7 - - [02/Jan/2026:03:04:05 +0000] "GET / HTTP/1.1" 200 612 "-" "Mozilla/5.00 (Nikto/2.1.6)"`,
+			ok:   true, sid: SIDHTTPScannerAgent, category: CategoryHTTPAttack, src: "2001:db8::7", path: "/",
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "auth failure",
+			line: `198.51.100.8 - bob [02/Jan/2026:03:04:05 +0000] "POST /login HTTP/1.1" 401 0 "-" "Mozilla/5.0"`,
+			ok:   true, sid: SIDHTTPAuthFailure, category: CategoryHTTPErrors, src: "198.51.100.8", path: "/login",
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "common format without referer and agent",
+			line: `198.51.100.9 - - [02/Jan/2026:03:04:05 +0000] "GET /missing HTTP/1.0" 404 0`,
+			ok:   true, sid: SIDHTTPNotFound, category: CategoryHTTPErrors, src: "198.51.100.9", path: "/missing",
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "JSON log_format",
+			line: `{"time_iso8601":"2026-01-02T03:04:05+00:00","remote_addr":"::ffff:198.51.100.10","request_method":"GET","request_uri":"/.env?a","status":"404","http_user_agent":"Go-http-client/1.1","host":"www.example"}`,
+			ok:   true, sid: SIDHTTPScannerPath, category: CategoryHTTPAttack, src: "198.51.100.10", host: "www.example", path: "/.env",
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "JSON with numeric status and msec",
+			line: `{"msec":1767323045.5,"remote_addr":"198.51.100.11","request":"GET /admin HTTP/1.1","status":403}`,
+			ok:   true, sid: SIDHTTPAuthFailure, category: CategoryHTTPErrors, src: "198.51.100.11", path: "/admin",
+			at: time.UnixMilli(1767323045500),
+		},
+		{
+			name: "ordinary request",
+			line: `198.51.100.7 - - [02/Jan/2026:03:04:05 +0000] "GET /index.html HTTP/1.1" 200 612 "-" "Mozilla/5.0"`,
+		},
+		{
+			name: "server error is not an error status by default",
+			line: `198.51.100.7 - - [02/Jan/2026:03:04:05 +0000] "GET /api HTTP/1.1" 502 0 "-" "Mozilla/5.0"`,
+		},
+		{
+			name: "JSON without address",
+			line: `{"time_iso8601":"2026-01-02T03:04:05+00:00","request_uri":"/.env","status":"404"}`,
+		},
+		{name: "garbage", line: `GET /wp-login.php`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewAccessLogDecoder(rules, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, ok := d.Decode([]byte(tt.line))
+			if ok != tt.ok {
+				t.Fatalf("Decode ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if a.Source != SourceNginx || a.DstPort != 80 {
+				t.Errorf("source %q, port %d", a.Source, a.DstPort)
+			}
+			if a.Alert.SignatureID != tt.sid || a.Alert.Category != tt.category {
+				t.Errorf("sid %d category %q, want %d %q", a.Alert.SignatureID, a.Alert.Category, tt.sid, tt.category)
+			}
+			if a.SrcIP != tt.src || a.HTTP.Hostname != tt.host || a.HTTP.URL != tt.path {
+				t.Errorf("src %q host %q path %q, want %q %q %q", a.SrcIP, a.HTTP.Hostname, a.HTTP.URL, tt.src, tt.host, tt.path)
+			}
+			if !a.ParsedTime.Equal(tt.at) {
+				t.Errorf("time %v, want %v", a.ParsedTime, tt.at)
+			}
+		})
+	}
+}
+
+func TestAccessLogErrorBurst(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	line := func(src string, at time.Duration) []byte {
+		return fmt.Appendf(nil, `%s - - [%s] "GET /missing HTTP/1.1" 404 0 "-" "Mozilla/5.0"`,
+			src, start.Add(at).Format(nginxTimeLocal))
+	}
+
+	type request struct {
+		src string
+		at  time.Duration
+	}
+	// n requests from src, one second apart from at
+	repeat := func(src string, at time.Duration, n int) []request {
+		out := make([]request, n)
+		for i := range out {
+			out[i] = request{src, at + time.Duration(i)*time.Second}
+		}
+		return out
+	}
+	concat := func(parts ...[]request) []request {
+		var out []request
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		requests []request
+		alerts   int
+	}{
+		{"below the burst", repeat("198.51.100.7", 0, 4), 0},
+		{"one burst", repeat("198.51.100.7", 0, 5), 1},
+		{"counting starts over after a burst", repeat("198.51.100.7", 0, 10), 2},
+		{"clients are counted apart", concat(
+			repeat("198.51.100.7", 0, 3),
+			repeat("198.51.100.8", 0, 3),
+			repeat("198.51.100.7", 3*time.Second, 2),
+		), 1},
+		{"window restarts after it ends", concat(
+			repeat("198.51.100.7", 0, 4),
+			repeat("198.51.100.7", 10*time.Second, 4),
+		), 0},
+		{"burst right at the end of the window", concat(
+			repeat("198.51.100.7", 0, 4),
+			repeat("198.51.100.7", 9*time.Second, 1),
+		), 1},
+	}
+
+	rules := DefaultAccessLogRules()
+	rules.ErrorBurst = 5
+	rules.ErrorWindow = 10 * time.Second
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewAccessLogDecoder(rules, 8080)
+			if err != nil {
+				t.Fatal(err)
+			}
+			alerts := 0
+			for _, r := range tt.requests {
+				a, ok := d.Decode(line(r.src, r.at))
+				if !ok {
+					continue
+				}
+				alerts++
+				if want := "HTTP error status (5 within 10s): GET /missing -> 404"; a.Alert.Signature != want {
+					t.Errorf("signature %q, want %q", a.Alert.Signature, want)
+				}
+			}
+			if alerts != tt.alerts {
+				t.Errorf("%d alerts, want %d", alerts, tt.alerts)
+			}
+		})
+	}
+}
+
+func TestAccessLogRulesValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *AccessLogRules)
+		ok     bool
+	}{
+		{"defaults", func(r *AccessLogRules) {}, true},
+		{"every error alerts", func(r *AccessLogRules) { r.ErrorBurst, r.ErrorWindow = 1, 0 }, true},
+		{"zero burst", func(r *AccessLogRules) { r.ErrorBurst = 0 }, false},
+		{"burst without window", func(r *AccessLogRules) { r.ErrorWindow = 0 }, false},
+		{"bad path regexp", func(r *AccessLogRules) { r.ScannerPaths = []string{`(`} }, false},
+		{"bad agent regexp", func(r *AccessLogRules) { r.UserAgents = []string{`[`} }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := DefaultAccessLogRules()
+			tt.modify(&r)
+			if err := r.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
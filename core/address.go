@@ -0,0 +1,53 @@
+package suricata
+
+import (
+	"fmt"
+	"net/netip"
+
+	"firefighter/data"
+)
+
+// Parsing and canonicalizing src/dest addresses of an alert
+func normalizeAlert(alert *Alert) error {
+	addr, err := netip.ParseAddr(alert.SrcIP)
+	if err != nil {
+		return fmt.Errorf("invalid src_ip %q: %w", alert.SrcIP, err)
+	}
+
+	alert.SrcAddr = addr.Unmap().WithZone("")
+	alert.SrcIP = alert.SrcAddr.String()
+	alert.DstIP = data.CanonicalIP(alert.DstIP)
+	return nil
+}
+
+// Parsing firewall target, single addresses become /32 or /128 prefixes
+func parseTarget(target string) (netip.Prefix, error) {
+	canonical := data.CanonicalIP(target)
+
+	if addr, err := netip.ParseAddr(canonical); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(canonical)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address or prefix %q", target)
+	}
+	return prefix, nil
+}
+
+// Formatting prefix the way firewall tools expect (bare address for single hosts)
+func formatTarget(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// Source address of an alert, parsing SrcIP when the reader did not set SrcAddr
+func alertAddr(a Alert) netip.Addr {
+	if a.SrcAddr.IsValid() {
+		return a.SrcAddr
+	}
+	addr, _ := netip.ParseAddr(data.CanonicalIP(a.SrcIP))
+	return addr
+}
@@ -1,24 +1,93 @@
 package suricata
 
 import (
+	"net/netip"
 	"time"
+
+	"firefighter/data"
 )
 
 type Alert struct {
-	Timestamp string `json:"timestamp"`
-	FlowID    uint64 `json:"flow_id"`
-	EventType string `json:"event_type"`
-	SrcIP     string `json:"src_ip"`
-	SrcPort   int    `json:"src_port"`
-	DstIP     string `json:"dest_ip"`
-	DstPort   int    `json:"dest_port"`
-	Proto     string `json:"proto"`
-	Alert     struct {
-		Signature   string `json:"signature"`
-		Category    string `json:"category"`
-		Severity    int    `json:"severity"`
-		SignatureID int    `json:"signature_id"`
-	} `json:"alert"`
-
-	ParsedTime time.Time `json:"-"`
+	Timestamp string    `json:"timestamp"`
+	FlowID    uint64    `json:"flow_id"`
+	EventType string    `json:"event_type"`
+	SrcIP     string    `json:"src_ip"`
+	SrcPort   int       `json:"src_port"`
+	DstIP     string    `json:"dest_ip"`
+	DstPort   int       `json:"dest_port"`
+	Proto     string    `json:"proto"`
+	AppProto  string    `json:"app_proto,omitempty"`
+	Alert     AlertInfo `json:"alert"`
+
+	// Metadata Suricata attaches to alerts (or that was seen earlier on the same flow)
+	Flow *EveFlow `json:"flow,omitempty"`
+	HTTP *EveHTTP `json:"http,omitempty"`
+	TLS  *EveTLS  `json:"tls,omitempty"`
+	DNS  *EveDNS  `json:"dns,omitempty"`
+
+	// Input kind that produced the alert: suricata, snort, auth_log, nginx
+	Source string `json:"-"`
+
+	ParsedTime time.Time  `json:"-"`
+	SrcAddr    netip.Addr `json:"-"`
+}
+
+// Alert sources stored with every alert
+const (
+	SourceSuricata = "suricata"
+	SourceSnort    = "snort"
+	SourceAuthLog  = "auth_log"
+	SourceNginx    = "nginx"
+)
+
+type AlertInfo struct {
+	Signature   string `json:"signature"`
+	Category    string `json:"category"`
+	Severity    int    `json:"severity"`
+	SignatureID int    `json:"signature_id"`
+}
+
+// Server name the alerting flow talked to: HTTP Host or TLS SNI
+func (a Alert) ServerName() string {
+	if a.HTTP != nil && a.HTTP.Hostname != "" {
+		return a.HTTP.Hostname
+	}
+	if a.TLS != nil {
+		return a.TLS.SNI
+	}
+	return ""
+}
+
+// Database record of the alert, stamped with its event time
+func (a Alert) Record() data.AlertRecord {
+	ts := a.ParsedTime
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	r := data.AlertRecord{
+		IP:        a.SrcIP,
+		SrcPort:   a.SrcPort,
+		DstIP:     a.DstIP,
+		DstPort:   a.DstPort,
+		Proto:     a.Proto,
+		SID:       a.Alert.SignatureID,
+		Message:   a.Alert.Signature,
+		Severity:  a.Alert.Severity,
+		Category:  a.Alert.Category,
+		FlowID:    a.FlowID,
+		Source:    a.Source,
+		Timestamp: ts,
+		AppProto:  a.AppProto,
+	}
+	if a.HTTP != nil {
+		r.Hostname = a.HTTP.Hostname
+	}
+	if a.TLS != nil {
+		r.SNI = a.TLS.SNI
+	}
+	if a.Flow != nil {
+		r.BytesToServer = a.Flow.BytesToServer
+		r.BytesToClient = a.Flow.BytesToClient
+	}
+	return r
 }
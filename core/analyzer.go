@@ -1,6 +1,7 @@
 package suricata
 
 import (
+	"context"
 	"firefighter/data"
 	"fmt"
 	"log"
@@ -17,122 +18,205 @@ type BlockDecision struct {
 	UniqueProtos  int
 	UniqueFlows   int
 	Categories    map[string]int
+	// Produced by a monitor-only policy
+	Monitor bool
 }
 
-func (wm *WindowManager) AnalyzeAlerts(db data.Repository) []BlockDecision {
-	var decisions []BlockDecision
-	baseThreshold := 30
+// Aggregates of one window under a policy
+type windowStats struct {
+	Count         int
+	Score         int
+	SeverityScore int
+	Categories    map[string]int
+	UniquePorts   int
+	UniqueProtos  int
+	UniqueSIDs    int
+	UniqueFlows   int
+	UniqueHosts   int
+	InstantSID    int
+}
 
-	for ip, window := range wm.Windows {
-		// Cleanup pustych okien
-		if window.Events.Len() == 0 {
-			delete(wm.Windows, ip)
-			continue
+// Scoring one window from its running aggregates; caller holds the window's shard lock
+func scoreWindow(policy *Policy, window *SlidingWindow) windowStats {
+	window.usePolicy(policy)
+	model := policy.Scoring
+	agg := &window.agg
+
+	stats := windowStats{
+		Count:         agg.count,
+		SeverityScore: agg.severityScore,
+		Categories:    make(map[string]int, len(agg.categories)),
+		UniquePorts:   len(agg.ports),
+		UniqueProtos:  len(agg.protos),
+		UniqueSIDs:    len(agg.sids),
+		UniqueFlows:   len(agg.flows),
+		UniqueHosts:   len(agg.hosts),
+	}
+	for sid := range agg.instant {
+		if stats.InstantSID == 0 || sid < stats.InstantSID {
+			stats.InstantSID = sid
 		}
+	}
 
-		stats := struct {
-			Count         int
-			SeverityScore int
-			Categories    map[string]int
-			UniquePorts   map[int]bool
-			UniqueProtos  map[string]bool
-			UniqueSIDs    map[int]bool
-			UniqueFlows   map[uint64]bool
-		}{
-			Categories:   make(map[string]int),
-			UniquePorts:  make(map[int]bool),
-			UniqueProtos: make(map[string]bool),
-			UniqueSIDs:   make(map[int]bool),
-			UniqueFlows:  make(map[uint64]bool),
-		}
+	// Obliczanie końcowego scoringu
+	score := stats.SeverityScore
+	for category, n := range agg.categories {
+		stats.Categories[category] = n
+		score += model.categoryWeight(category)
+	}
+	score += stats.UniquePorts * model.PortWeight
+	score += stats.UniqueProtos * model.ProtoWeight
+	score += stats.UniqueSIDs * model.SIDWeight
+	score += stats.UniqueHosts * model.HostWeight
+
+	// Flow scoring - wiele flow z jednego IP = podejrzane
+	if model.FlowMinimum > 0 && stats.UniqueFlows >= model.FlowMinimum {
+		score += stats.UniqueFlows * model.FlowWeight
+	}
 
-		// Pętla przez wszystkie alerty w sliding window
-		for e := window.Events.Front(); e != nil; e = e.Next() {
-			a := e.Value.(Alert)
-			stats.Count++
-
-			// Severity scoring
-			switch a.Alert.Severity {
-			case 1:
-				stats.SeverityScore += 10
-			case 2:
-				stats.SeverityScore += 5
-			case 3:
-				stats.SeverityScore += 2
-			}
+	stats.Score = score
+	return stats
+}
+
+func (s windowStats) overThreshold(model ScoringModel) bool {
+	return s.Count > 0 && (s.Score >= model.Threshold || s.InstantSID != 0)
+}
+
+// Window over the threshold, waiting for whitelist/blocked checks
+type candidate struct {
+	ip     string
+	window *SlidingWindow
+	stats  windowStats
+}
+
+// Re-evaluating the single window an alert was just added to (key as returned by Add)
+func (wm *WindowManager) AnalyzeIP(ctx context.Context, db data.Repository, key string) (BlockDecision, bool) {
+	policy := wm.Policy()
+
+	s := wm.shard(key)
+	s.mu.Lock()
+	window, exists := s.windows[key]
+	if !exists || window.Events.Len() == 0 {
+		s.mu.Unlock()
+		return BlockDecision{}, false
+	}
+	stats := scoreWindow(policy, window)
+	s.mu.Unlock()
+
+	if !stats.overThreshold(policy.Scoring) {
+		return BlockDecision{}, false
+	}
+	return wm.decide(ctx, db, policy, candidate{ip: key, window: window, stats: stats})
+}
 
-			// Agregacja statystyk
-			stats.Categories[a.Alert.Category]++
-			stats.UniquePorts[a.DstPort] = true
-			stats.UniqueProtos[a.Proto] = true
-			stats.UniqueSIDs[a.Alert.SignatureID] = true
+// Full pass over every window; the alert loop uses AnalyzeIP, this is for
+// re-evaluating everything at once (e.g. after a policy reload)
+func (wm *WindowManager) AnalyzeAlerts(ctx context.Context, db data.Repository) []BlockDecision {
+	policy := wm.Policy()
+
+	// Faza 1: scoring pod lockiem sharda, bez zapytań do bazy
+	var candidates []candidate
+	for i := range wm.shards {
+		s := &wm.shards[i]
+		s.mu.Lock()
+		for ip, window := range s.windows {
+			// Cleanup pustych okien
+			if window.Events.Len() == 0 {
+				delete(s.windows, ip)
+				continue
+			}
 
-			// Flow tracking
-			if a.FlowID != 0 {
-				stats.UniqueFlows[a.FlowID] = true
+			stats := scoreWindow(policy, window)
+			if stats.overThreshold(policy.Scoring) {
+				candidates = append(candidates, candidate{ip: ip, window: window, stats: stats})
 			}
 		}
+		s.mu.Unlock()
+	}
 
-		// Obliczanie końcowego scoringu
-		score := stats.SeverityScore
-		score += len(stats.Categories) * 5
-		score += len(stats.UniquePorts) * 3
-		score += len(stats.UniqueProtos) * 4
-		score += len(stats.UniqueSIDs)
-
-		// Flow scoring - wiele flow z jednego IP = podejrzane
-		flowCount := len(stats.UniqueFlows)
-		if flowCount >= 5 {
-			score += flowCount * 4
-			log.Printf("⚠️  IP %s ma %d różnych flow - podejrzane skanowanie!", ip, flowCount)
+	// Faza 2: whitelist / blokady i decyzje
+	var decisions []BlockDecision
+	for _, c := range candidates {
+		if d, ok := wm.decide(ctx, db, policy, c); ok {
+			decisions = append(decisions, d)
 		}
+	}
+	return decisions
+}
 
-		// Tworzenie szczegółowego raportu
-		reason := fmt.Sprintf(
-			"Score:%d, Severity:%d, Ports:%d, Protos:%d, SIDs:%d, Flows:%d, Count:%d",
-			score, stats.SeverityScore, len(stats.UniquePorts),
-			len(stats.UniqueProtos), len(stats.UniqueSIDs), flowCount, stats.Count,
-		)
-
-		// Sprawdzanie warunków blokowania
-		isWhitelisted, err := db.IsWhitelisted(ip)
-		if err != nil {
-			log.Printf("Błąd sprawdzania whitelisty dla %s: %v", ip, err)
-		}
+func (wm *WindowManager) decide(ctx context.Context, db data.Repository, policy *Policy, c candidate) (BlockDecision, bool) {
+	ip, stats, score := c.ip, c.stats, c.stats.Score
+	flowCount := stats.UniqueFlows
 
-		isBlocked, err := db.IsBlocked(ip)
-		if err != nil {
-			log.Printf("Błąd sprawdzania statusu blokady dla %s: %v", ip, err)
-		}
+	if policy.Scoring.FlowMinimum > 0 && flowCount >= policy.Scoring.FlowMinimum {
+		log.Printf("⚠️  IP %s ma %d różnych flow - podejrzane skanowanie!", ip, flowCount)
+	}
 
-		// Decyzja o blokowaniu
-		if !isWhitelisted && !isBlocked && score >= baseThreshold {
-			log.Printf("🚨 IP %s przekroczył threshold scoringu (%d), blokada!", ip, score)
-
-			dynamicReason := generateBlockReason(
-				stats.Categories,
-				stats.Count,
-				len(stats.UniquePorts),
-				len(stats.UniqueFlows),
-			)
-
-			decisions = append(decisions, BlockDecision{
-				IP:            ip,
-				Reason:        dynamicReason, // ← ZMIANA
-				Score:         score,
-				Details:       reason,
-				AlertCount:    stats.Count,
-				SeverityScore: stats.SeverityScore,
-				UniquePorts:   len(stats.UniquePorts),
-				UniqueProtos:  len(stats.UniqueProtos),
-				UniqueFlows:   len(stats.UniqueFlows),
-				Categories:    stats.Categories,
-			})
-			window.Events.Init()
-		}
+	// Tworzenie szczegółowego raportu
+	reason := fmt.Sprintf(
+		"Score:%d, Severity:%d, Ports:%d, Protos:%d, SIDs:%d, Flows:%d, Count:%d",
+		score, stats.SeverityScore, stats.UniquePorts,
+		stats.UniqueProtos, stats.UniqueSIDs, flowCount, stats.Count,
+	)
+	if stats.UniqueHosts > 0 {
+		reason += fmt.Sprintf(", Hosts:%d", stats.UniqueHosts)
+	}
+	if stats.InstantSID != 0 {
+		reason += fmt.Sprintf(", InstantSID:%d", stats.InstantSID)
 	}
 
-	return decisions
+	// Sprawdzanie warunków blokowania
+	isWhitelisted, err := wm.isWhitelisted(ctx, db, ip)
+	if err != nil {
+		log.Printf("Błąd sprawdzania whitelisty dla %s: %v", ip, err)
+	}
+
+	isBlocked, err := db.IsBlocked(ctx, ip)
+	if err != nil {
+		log.Printf("Błąd sprawdzania statusu blokady dla %s: %v", ip, err)
+	}
+
+	if isWhitelisted || isBlocked {
+		return BlockDecision{}, false
+	}
+
+	// Decyzja o blokowaniu
+	log.Printf("🚨 IP %s przekroczył threshold scoringu (%d), blokada!", ip, score)
+
+	dynamicReason := generateBlockReason(
+		stats.Categories,
+		stats.Count,
+		stats.UniquePorts,
+		stats.UniqueFlows,
+	)
+
+	// Okno mogło zostać usunięte w międzyczasie (unblock), wtedy Reset nic nie psuje
+	s := wm.shard(ip)
+	s.mu.Lock()
+	c.window.Reset()
+	s.mu.Unlock()
+
+	return BlockDecision{
+		IP:            ip,
+		Reason:        dynamicReason, // ← ZMIANA
+		Score:         score,
+		Details:       reason,
+		AlertCount:    stats.Count,
+		SeverityScore: stats.SeverityScore,
+		UniquePorts:   stats.UniquePorts,
+		UniqueProtos:  stats.UniqueProtos,
+		UniqueFlows:   stats.UniqueFlows,
+		Categories:    stats.Categories,
+		Monitor:       policy.Monitor,
+	}, true
+}
+
+// Using in-memory whitelist when loaded, database otherwise
+func (wm *WindowManager) isWhitelisted(ctx context.Context, db data.Repository, ip string) (bool, error) {
+	if wm.Whitelist != nil {
+		return wm.Whitelist.Contains(ip), nil
+	}
+	return db.IsWhitelisted(ctx, ip)
 }
 
 func generateBlockReason(categories map[string]int, count, ports, flows int) string {
@@ -0,0 +1,131 @@
+package suricata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthLogDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int
+		line     string
+		ok       bool
+		sid      int
+		severity int
+		src      string
+		srcPort  int
+		dstPort  int
+		at       time.Time
+	}{
+		{
+			name: "RFC 3339 failed password",
+			line: "2026-01-02T03:04:05.123456+00:00 bastion sshd[811]: Failed password for root from 198.51.100.7 port 50022 ssh2",
+			ok:   true, sid: SIDSSHFailedPassword, severity: 2, src: "198.51.100.7", srcPort: 50022, dstPort: 22,
+			at: time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC),
+		},
+		{
+			name: "failed password for invalid user on custom port",
+			port: 2222,
+			line: "2026-01-02T03:04:05+01:00 bastion sshd[811]: Failed password for invalid user admin from 2001:db8::7 port 41000 ssh2",
+			ok:   true, sid: SIDSSHFailedPassword, severity: 2, src: "2001:db8::7", srcPort: 41000, dstPort: 2222,
+			at: time.Date(2026, 1, 2, 2, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "short-iso from journalctl",
+			line: "2026-01-02T03:04:05+0000 bastion sshd-session[90]: Invalid user oracle from 198.51.100.8 port 1022",
+			ok:   true, sid: SIDSSHInvalidUser, severity: 2, src: "198.51.100.8", srcPort: 1022, dstPort: 22,
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "failed publickey",
+			line: "2026-01-02T03:04:05Z bastion sshd[1]: Failed publickey for git from 198.51.100.9 port 3 ssh2: ED25519 SHA256:x",
+			ok:   true, sid: SIDSSHFailedPublickey, severity: 3, src: "198.51.100.9", srcPort: 3, dstPort: 22,
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "max auth tries",
+			line: "2026-01-02T03:04:05Z bastion sshd[1]: error: maximum authentication attempts exceeded for root from ::ffff:198.51.100.10 port 4 ssh2 [preauth]",
+			ok:   true, sid: SIDSSHMaxAuthTries, severity: 1, src: "198.51.100.10", srcPort: 4, dstPort: 22,
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "preauth close",
+			line: "2026-01-02T03:04:05Z bastion sshd[1]: Connection closed by invalid user test 198.51.100.11 port 5 [preauth]",
+			ok:   true, sid: SIDSSHPreauthClose, severity: 3, src: "198.51.100.11", srcPort: 5, dstPort: 22,
+			at: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "journald JSON",
+			line: `{"MESSAGE":"Failed password for root from 198.51.100.12 port 6 ssh2","SYSLOG_IDENTIFIER":"sshd","__REALTIME_TIMESTAMP":"1767323045000123"}`,
+			ok:   true, sid: SIDSSHFailedPassword, severity: 2, src: "198.51.100.12", srcPort: 6, dstPort: 22,
+			at: time.UnixMicro(1767323045000123),
+		},
+		{
+			name: "accepted login",
+			line: "2026-01-02T03:04:05Z bastion sshd[1]: Accepted publickey for git from 198.51.100.9 port 3 ssh2",
+		},
+		{
+			name: "other program",
+			line: "2026-01-02T03:04:05Z bastion sudo[1]: Failed password for root from 198.51.100.9 port 3 ssh2",
+		},
+		{
+			name: "hostname with UseDNS",
+			line: "2026-01-02T03:04:05Z bastion sshd[1]: Failed password for root from scanner.example port 3 ssh2",
+		},
+		{
+			name: "journald JSON from another unit",
+			line: `{"MESSAGE":"Failed password for root from 198.51.100.12 port 6 ssh2","SYSLOG_IDENTIFIER":"cron"}`,
+		},
+		{name: "garbage", line: "not a log line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ok := AuthLogDecoder{Port: tt.port}.Decode([]byte(tt.line))
+			if ok != tt.ok {
+				t.Fatalf("Decode ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if a.Source != SourceAuthLog || a.Alert.Category != CategorySSHBruteForce || a.AppProto != "ssh" {
+				t.Errorf("source %q, category %q, app proto %q", a.Source, a.Alert.Category, a.AppProto)
+			}
+			if a.Alert.SignatureID != tt.sid || a.Alert.Severity != tt.severity {
+				t.Errorf("sid %d severity %d, want %d %d", a.Alert.SignatureID, a.Alert.Severity, tt.sid, tt.severity)
+			}
+			if a.SrcIP != tt.src || a.SrcPort != tt.srcPort || a.DstPort != tt.dstPort {
+				t.Errorf("flow %s:%d -> :%d, want %s:%d -> :%d", a.SrcIP, a.SrcPort, a.DstPort, tt.src, tt.srcPort, tt.dstPort)
+			}
+			if !a.ParsedTime.Equal(tt.at) {
+				t.Errorf("time %v, want %v", a.ParsedTime, tt.at)
+			}
+		})
+	}
+}
+
+func TestSyslogTimestamp(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		line string
+		want time.Time
+	}{
+		{"this year", "Jan  2 03:04:05 bastion sshd[1]: x", time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)},
+		{"a day ahead is still this year", "Jan  3 11:00:00 bastion sshd[1]: x", time.Date(2026, 1, 3, 11, 0, 0, 0, time.Local)},
+		{"December belongs to last year", "Dec 31 23:59:59 bastion sshd[1]: x", time.Date(2025, 12, 31, 23, 59, 59, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, program, message, ok := splitAuthLine([]byte(tt.line), now)
+			if !ok || program != "sshd" || message != "x" {
+				t.Fatalf("splitAuthLine = %v %q %q %v", ts, program, message, ok)
+			}
+			if !ts.Equal(tt.want) {
+				t.Errorf("time %v, want %v", ts, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package suricata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"firefighter/data"
+)
+
+// Backtest streams stored alerts in timestamp order through a WindowManager
+// running a candidate policy and compares the blocks it would have made with
+// the blocked_ips history of the same period. Nothing is written or enforced.
+type Backtest struct {
+	Policy     *Policy
+	Window     time.Duration
+	IPv6Prefix int
+	// Durations of simulated blocks; a blocked IP is not scored again until its block ends
+	Escalation EscalationPolicy
+	// nil = whitelist table
+	Whitelist *Whitelist
+	// Alert time range, zero = unbounded
+	From, To time.Time
+}
+
+type BacktestBlock struct {
+	IP       string    `json:"ip"`
+	At       time.Time `json:"at"`
+	Score    int       `json:"score"`
+	Reason   string    `json:"reason"`
+	Details  string    `json:"details,omitempty"`
+	Duration int64     `json:"duration"` // seconds, 0 = permanent
+	// Decision of a monitor-only policy, would not have been enforced
+	Monitor bool `json:"monitor,omitempty"`
+}
+
+// IP blocked both by the candidate and in reality
+type BacktestMatch struct {
+	IP          string    `json:"ip"`
+	CandidateAt time.Time `json:"candidate_at"`
+	ActualAt    time.Time `json:"actual_at"`
+	// Candidate minus actual first block; negative = candidate blocks earlier
+	DeltaSeconds int64 `json:"delta_seconds"`
+}
+
+// Per IP, first block on each side
+type BacktestDiff struct {
+	Both          []BacktestMatch `json:"both"`
+	CandidateOnly []BacktestBlock `json:"candidate_only"`
+	ActualOnly    []BacktestBlock `json:"actual_only"`
+}
+
+type BacktestReport struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Alerts int       `json:"alerts"`
+	Policy *Policy   `json:"policy"`
+	Window string    `json:"window"`
+	// Every candidate decision, in event time order
+	WouldBlock []BacktestBlock `json:"would_block"`
+	// blocked_ips rows in the range
+	Actual []BacktestBlock `json:"actual"`
+	Diff   BacktestDiff    `json:"diff"`
+	Note   string          `json:"note,omitempty"`
+}
+
+// Cancelling ctx stops the run between alerts
+func (b *Backtest) Run(ctx context.Context, db data.Repository) (*BacktestReport, error) {
+	clock := NewManualClock(time.Time{})
+	wm := NewWindowManager(b.Window)
+	wm.SetPolicy(b.Policy)
+	wm.IPv6Prefix = b.IPv6Prefix
+	wm.Whitelist = b.Whitelist
+	wm.Clock = clock
+	sim := NewSimulatedBlocks(db, clock, b.Escalation)
+
+	report := &BacktestReport{
+		From:       b.From,
+		To:         b.To,
+		Policy:     b.Policy,
+		Window:     b.Window.String(),
+		WouldBlock: []BacktestBlock{},
+		Actual:     []BacktestBlock{},
+	}
+
+	var lastSweep time.Time
+	legacy := 0
+	err := db.ScanAlerts(ctx, unixOrZero(b.From), unixOrZero(b.To), func(rec data.AlertDetails) error {
+		alert := alertFromRecord(rec)
+		report.Alerts++
+		// Rows from before full records keep ip, sid, message and source only
+		if rec.Severity == 0 && rec.Proto == "" {
+			legacy++
+		}
+		if report.Alerts == 1 && b.From.IsZero() {
+			report.From = alert.ParsedTime
+		}
+		if b.To.IsZero() {
+			report.To = alert.ParsedTime
+		}
+
+		clock.Set(alert.ParsedTime)
+		if now := clock.Now(); now.Sub(lastSweep) >= time.Minute {
+			wm.Sweep()
+			lastSweep = now
+		}
+
+		key := wm.Add(alert)
+		decision, ok := wm.AnalyzeIP(ctx, sim, key)
+		if !ok {
+			return nil
+		}
+
+		block := BacktestBlock{
+			IP:      decision.IP,
+			At:      clock.Now(),
+			Score:   decision.Score,
+			Reason:  decision.Reason,
+			Details: decision.Details,
+			Monitor: decision.Monitor,
+		}
+		// Monitor-only policies never block, so the IP keeps being scored
+		if !decision.Monitor {
+			block.Duration = int64(sim.Block(decision.IP).Seconds())
+		}
+		report.WouldBlock = append(report.WouldBlock, block)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := db.GetBlocksBetween(ctx, unixOrZero(report.From), unixOrZero(b.To))
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range actual {
+		block := BacktestBlock{
+			IP:      a.IP,
+			At:      time.Unix(a.Timestamp, 0),
+			Score:   a.Score,
+			Reason:  a.Reason,
+			Details: a.Details,
+		}
+		if a.UnblockTime > a.Timestamp {
+			block.Duration = a.UnblockTime - a.Timestamp
+		}
+		report.Actual = append(report.Actual, block)
+	}
+
+	report.Diff = diffBlocks(report.WouldBlock, report.Actual)
+	if legacy > 0 {
+		report.Note = fmt.Sprintf("%d alerts were stored without severity/category/ports/protocol/flow and score lower", legacy)
+	}
+	return report, nil
+}
+
+// Comparing first blocks per IP; both inputs are in time order
+func diffBlocks(candidate, actual []BacktestBlock) BacktestDiff {
+	diff := BacktestDiff{
+		Both:          []BacktestMatch{},
+		CandidateOnly: []BacktestBlock{},
+		ActualOnly:    []BacktestBlock{},
+	}
+
+	firstActual := make(map[string]BacktestBlock)
+	for _, a := range actual {
+		if _, seen := firstActual[a.IP]; !seen {
+			firstActual[a.IP] = a
+		}
+	}
+
+	matched := make(map[string]bool)
+	for _, c := range candidate {
+		if matched[c.IP] {
+			continue
+		}
+		matched[c.IP] = true
+		if a, ok := firstActual[c.IP]; ok {
+			diff.Both = append(diff.Both, BacktestMatch{
+				IP:           c.IP,
+				CandidateAt:  c.At,
+				ActualAt:     a.At,
+				DeltaSeconds: int64(c.At.Sub(a.At).Seconds()),
+			})
+		} else {
+			diff.CandidateOnly = append(diff.CandidateOnly, c)
+		}
+	}
+
+	for _, a := range actual {
+		if matched[a.IP] {
+			continue
+		}
+		matched[a.IP] = true
+		diff.ActualOnly = append(diff.ActualOnly, a)
+	}
+	return diff
+}
+
+// Inverse of Alert.Record, as far as the table keeps it
+func alertFromRecord(r data.AlertDetails) Alert {
+	alert := Alert{
+		EventType:  EventAlert,
+		Timestamp:  r.Timestamp.Format(time.RFC3339),
+		ParsedTime: r.Timestamp,
+		FlowID:     r.FlowID,
+		SrcIP:      r.IP,
+		SrcPort:    r.SrcPort,
+		DstIP:      r.DstIP,
+		DstPort:    r.DstPort,
+		Proto:      r.Proto,
+		AppProto:   r.AppProto,
+		Source:     r.Source,
+	}
+	if r.Hostname != "" {
+		alert.HTTP = &EveHTTP{Hostname: r.Hostname}
+	}
+	if r.SNI != "" {
+		alert.TLS = &EveTLS{SNI: r.SNI}
+	}
+	if r.BytesToServer != 0 || r.BytesToClient != 0 {
+		alert.Flow = &EveFlow{BytesToServer: r.BytesToServer, BytesToClient: r.BytesToClient}
+	}
+	alert.Alert.SignatureID = r.SID
+	alert.Alert.Signature = r.Message
+	alert.Alert.Severity = r.Severity
+	alert.Alert.Category = r.Category
+	return alert
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// SimulatedBlocks is the Repository for dry runs (replay, backtest): reads
+// go to the wrapped database, except IsBlocked, which answers from blocks
+// recorded with Block and lifted on the given clock
+type SimulatedBlocks struct {
+	data.Repository
+	Clock      Clock
+	Escalation EscalationPolicy
+
+	blocks map[string]*simulatedBlock
+}
+
+type simulatedBlock struct {
+	count int
+	start time.Time // of the latest block
+	until time.Time // zero = permanent
+}
+
+func NewSimulatedBlocks(db data.Repository, clock Clock, escalation EscalationPolicy) *SimulatedBlocks {
+	return &SimulatedBlocks{
+		Repository: db,
+		Clock:      clock,
+		Escalation: escalation,
+		blocks:     make(map[string]*simulatedBlock),
+	}
+}
+
+func (s *SimulatedBlocks) IsBlocked(ctx context.Context, ip string) (bool, error) {
+	b, ok := s.blocks[ip]
+	if !ok {
+		return false, nil
+	}
+	return b.until.IsZero() || s.Clock.Now().Before(b.until), nil
+}
+
+// Recording a block with the escalation the service would apply; returns its duration, 0 = permanent
+func (s *SimulatedBlocks) Block(ip string) time.Duration {
+	b, ok := s.blocks[ip]
+	if !ok {
+		b = &simulatedBlock{}
+		s.blocks[ip] = b
+	}
+	now := s.Clock.Now()
+	duration := s.Escalation.Duration(b.count)
+	b.count++
+	b.start = now
+	b.until = time.Time{}
+	if duration > 0 {
+		b.until = now.Add(duration)
+	}
+	return duration
+}
+
+// Forgetting blocks that have ended and started longer than the escalation
+// lookback ago, which the service would not count as previous offences
+// either; with Lookback 0 the whole history counts and nothing is forgotten
+func (s *SimulatedBlocks) Prune(now time.Time) int {
+	if s.Escalation.Lookback <= 0 {
+		return 0
+	}
+	removed := 0
+	for ip, b := range s.blocks {
+		if !b.until.IsZero() && !now.Before(b.until) && b.start.Before(now.Add(-s.Escalation.Lookback)) {
+			delete(s.blocks, ip)
+			removed++
+		}
+	}
+	return removed
+}
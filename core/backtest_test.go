@@ -0,0 +1,125 @@
+package suricata
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"firefighter/data"
+)
+
+// Stored alerts and blocks; any write would hit the nil Repository and panic
+type historyRepo struct {
+	data.Repository
+	alerts []data.AlertDetails
+	blocks []data.BlockedIPDetails
+}
+
+func (r *historyRepo) ScanAlerts(_ context.Context, from, to int64, fn func(data.AlertDetails) error) error {
+	for _, a := range r.alerts {
+		ts := a.Timestamp.Unix()
+		if (from > 0 && ts < from) || (to > 0 && ts > to) {
+			continue
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *historyRepo) GetBlocksBetween(_ context.Context, from, to int64) ([]data.BlockedIPDetails, error) {
+	var out []data.BlockedIPDetails
+	for _, b := range r.blocks {
+		if (from > 0 && b.Timestamp < from) || (to > 0 && b.Timestamp > to) {
+			continue
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+func TestBacktest(t *testing.T) {
+	t0 := testStart
+	var alerts []data.AlertDetails
+	alert := func(ip string, at time.Duration) {
+		i := len(alerts)
+		alerts = append(alerts, data.AlertDetails{ID: i + 1, AlertRecord: data.AlertRecord{
+			IP: ip, DstPort: 22 + i, Proto: "TCP", FlowID: uint64(i + 1),
+			SID: 2000000 + i, Message: "scan", Severity: 1, Category: "Attempted Information Leak",
+			Timestamp: t0.Add(at),
+		}})
+	}
+	// Blocked, still blocked ten minutes later, blocked again (permanently) after the hour
+	for _, at := range []time.Duration{0, time.Second, 10 * time.Minute, 10*time.Minute + time.Second} {
+		alert("203.0.113.9", at)
+	}
+	alert("198.51.100.1", 5*time.Second)
+	alert("203.0.113.9", 2*time.Hour)
+	alert("203.0.113.9", 2*time.Hour+time.Second)
+
+	db := &historyRepo{alerts: alerts, blocks: []data.BlockedIPDetails{
+		{IP: "203.0.113.9", Score: 40, Timestamp: t0.Add(time.Minute).Unix(), UnblockTime: t0.Add(time.Hour + time.Minute).Unix()},
+		{IP: "192.0.2.50", Score: 50, Timestamp: t0.Add(30 * time.Minute).Unix()},
+	}}
+	policy, err := NewPolicy(DefaultScoring(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Backtest{
+		Policy:     policy,
+		Window:     10 * time.Minute,
+		Escalation: EscalationPolicy{Steps: []time.Duration{time.Hour, 0}},
+		Whitelist:  NewWhitelist(),
+	}
+
+	report, err := b.Run(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Alerts != 7 || !report.From.Equal(t0) || !report.To.Equal(t0.Add(2*time.Hour+time.Second)) {
+		t.Errorf("%d alerts from %s to %s", report.Alerts, report.From, report.To)
+	}
+
+	type block struct {
+		ip       string
+		at       time.Time
+		duration int64
+	}
+	var got []block
+	for _, wb := range report.WouldBlock {
+		got = append(got, block{wb.IP, wb.At, wb.Duration})
+	}
+	want := []block{
+		{"203.0.113.9", t0.Add(time.Second), 3600},
+		{"203.0.113.9", t0.Add(2*time.Hour + time.Second), 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("would block %+v, want %+v", got, want)
+	}
+
+	if len(report.Actual) != 2 || report.Actual[0].Duration != 3600 || report.Actual[1].Duration != 0 {
+		t.Errorf("actual blocks %+v", report.Actual)
+	}
+	diff := report.Diff
+	if len(diff.Both) != 1 || diff.Both[0].IP != "203.0.113.9" || diff.Both[0].DeltaSeconds != -59 {
+		t.Errorf("both %+v, want 203.0.113.9 blocked 59s earlier", diff.Both)
+	}
+	if len(diff.CandidateOnly) != 0 || len(diff.ActualOnly) != 1 || diff.ActualOnly[0].IP != "192.0.2.50" {
+		t.Errorf("candidate only %+v, actual only %+v", diff.CandidateOnly, diff.ActualOnly)
+	}
+
+	// A range after the real blocks: the candidate starts with a clean history
+	b.From = t0.Add(90 * time.Minute)
+	report, err = b.Run(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Alerts != 2 || len(report.WouldBlock) != 1 || report.WouldBlock[0].Duration != 3600 {
+		t.Errorf("%d alerts, would block %+v, want one 1h block", report.Alerts, report.WouldBlock)
+	}
+	if len(report.Diff.CandidateOnly) != 1 || len(report.Diff.Both)+len(report.Diff.ActualOnly) != 0 {
+		t.Errorf("diff %+v, want the candidate block only", report.Diff)
+	}
+}
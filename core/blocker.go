@@ -2,50 +2,234 @@ package suricata
 
 import (
 	"fmt"
-	"log/slog" // ← DODANE
+	"log/slog"
 	"os/exec"
+	"slices"
+	"strings"
 )
 
-func BlockIP(ip string) error {
-	cmd := exec.Command("sudo", "firewall-cmd",
-		"--permanent",
-		"--add-rich-rule",
-		fmt.Sprintf("rule family=ipv4 source address=%s drop", ip),
-	)
-	output, err := cmd.CombinedOutput()
+// Enforcer is a firewall backend able to drop traffic from source addresses
+type Enforcer interface {
+	Block(ip string) error
+	Unblock(ip string) error
+	List() ([]string, error)
+	Flush() error
+}
+
+const (
+	DriverFirewalld = "firewalld"
+	DriverNftables  = "nftables"
+	DriverIpset     = "ipset"
+	DriverDryRun    = "dryrun"
+)
+
+// Creating Enforcer for the given driver name
+func NewEnforcer(driver string) (Enforcer, error) {
+	switch driver {
+	case DriverFirewalld, "":
+		return NewFirewalldEnforcer()
+	case DriverNftables:
+		return NewNftablesEnforcer()
+	case DriverIpset:
+		return NewIpsetEnforcer()
+	case DriverDryRun:
+		return NewDryRunEnforcer(), nil
+	default:
+		return nil, fmt.Errorf("unknown firewall driver %q", driver)
+	}
+}
+
+// Running privileged firewall command through sudo
+func runPrivileged(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command("sudo", append([]string{name}, args...)...)
+	return cmd.CombinedOutput()
+}
+
+// Running privileged command with script fed on stdin
+func runPrivilegedInput(input, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command("sudo", append([]string{name}, args...)...)
+	cmd.Stdin = strings.NewReader(input)
+	return cmd.CombinedOutput()
+}
+
+const (
+	firewalldIpset4 = "firefighter-fw4"
+	firewalldIpset6 = "firefighter-fw6"
+)
+
+// FirewalldEnforcer keeps blocked addresses and prefixes in permanent
+// firewalld ipsets (one per family) dropped by one rich rule each, so Flush
+// never touches rich rules the operator added
+type FirewalldEnforcer struct{}
+
+// Creating FirewalldEnforcer and ensuring the ipsets and their drop rules exist
+func NewFirewalldEnforcer() (*FirewalldEnforcer, error) {
+	output, err := runPrivileged("firewall-cmd", "--permanent", "--get-ipsets")
 	if err != nil {
-		slog.Error("Firewall block command failed", "ip", ip, "output", string(output), "error", err) // ← DODANE
-		return fmt.Errorf("error during blocking IP %s: %v, output: %s", ip, err, string(output))
+		return nil, fmt.Errorf("error during listing firewalld ipsets: %v, output: %s", err, string(output))
+	}
+	existing := strings.Fields(string(output))
+
+	families := []struct {
+		set, family, option string
+	}{
+		{firewalldIpset4, "ipv4", "family=inet"},
+		{firewalldIpset6, "ipv6", "family=inet6"},
+	}
+
+	changed := false
+	for _, f := range families {
+		if !slices.Contains(existing, f.set) {
+			out, err := runPrivileged("firewall-cmd", "--permanent", "--new-ipset="+f.set, "--type=hash:net", "--option="+f.option)
+			if err != nil {
+				return nil, fmt.Errorf("error during creating firewalld ipset %s: %v, output: %s", f.set, err, string(out))
+			}
+			changed = true
+		}
+
+		rule := fmt.Sprintf("rule family=%s source ipset=%s drop", f.family, f.set)
+		if _, err := runPrivileged("firewall-cmd", "--permanent", "--query-rich-rule", rule); err != nil {
+			if out, err := runPrivileged("firewall-cmd", "--permanent", "--add-rich-rule", rule); err != nil {
+				return nil, fmt.Errorf("error during adding rich rule for ipset %s: %v, output: %s", f.set, err, string(out))
+			}
+			changed = true
+		}
+	}
+
+	f := &FirewalldEnforcer{}
+	if changed {
+		if err := f.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("firewalld ipsets ready", "sets", []string{firewalldIpset4, firewalldIpset6})
+	return f, nil
+}
+
+// Ipset and entry text for an address or prefix
+func firewalldEntry(ip string) (string, string, error) {
+	target, err := parseTarget(ip)
+	if err != nil {
+		return "", "", err
+	}
+
+	if target.Addr().Is6() {
+		return firewalldIpset6, formatTarget(target), nil
+	}
+	return firewalldIpset4, formatTarget(target), nil
+}
+
+// Per-address rich rule written by releases before the ipsets
+func firewalldLegacyRule(ip string) (string, error) {
+	target, err := parseTarget(ip)
+	if err != nil {
+		return "", err
 	}
 
-	reloadCmd := exec.Command("sudo", "firewall-cmd", "--reload")
-	if out, err := reloadCmd.CombinedOutput(); err != nil {
-		slog.Error("Firewall reload failed", "output", string(out), "error", err) // ← DODANE
+	family := "ipv4"
+	if target.Addr().Is6() {
+		family = "ipv6"
+	}
+	return fmt.Sprintf("rule family=%s source address=%s drop", family, formatTarget(target)), nil
+}
+
+func (f *FirewalldEnforcer) reload() error {
+	if out, err := runPrivileged("firewall-cmd", "--reload"); err != nil {
+		slog.Error("Firewall reload failed", "output", string(out), "error", err)
 		return fmt.Errorf("error during reloading firewalld service: %v, (%s)", err, string(out))
 	}
+	return nil
+}
+
+// Changing an ipset entry in the permanent and the runtime configuration,
+// which spares the reload
+func (f *FirewalldEnforcer) changeEntry(op, set, entry string) ([]byte, error) {
+	if out, err := runPrivileged("firewall-cmd", "--permanent", "--ipset="+set, op+"="+entry); err != nil {
+		return out, err
+	}
+	return runPrivileged("firewall-cmd", "--ipset="+set, op+"="+entry)
+}
+
+func (f *FirewalldEnforcer) Block(ip string) error {
+	set, entry, err := firewalldEntry(ip)
+	if err != nil {
+		return err
+	}
+
+	output, err := f.changeEntry("--add-entry", set, entry)
+	if err != nil {
+		slog.Error("Firewall block command failed", "ip", ip, "output", string(output), "error", err)
+		return fmt.Errorf("error during blocking IP %s: %v, output: %s", ip, err, string(output))
+	}
 
-	slog.Info("IP blocked in firewall", "ip", ip) // ← DODANE
+	slog.Info("IP blocked in firewall", "ip", ip, "driver", DriverFirewalld)
 	return nil
 }
 
-func UnblockIP(ip string) error {
-	cmd := exec.Command("sudo", "firewall-cmd",
-		"--permanent",
-		"--remove-rich-rule",
-		fmt.Sprintf("rule family=ipv4 source address=%s drop", ip),
-	)
-	output, err := cmd.CombinedOutput()
+func (f *FirewalldEnforcer) Unblock(ip string) error {
+	set, entry, err := firewalldEntry(ip)
+	if err != nil {
+		return err
+	}
+
+	output, err := f.changeEntry("--remove-entry", set, entry)
 	if err != nil {
-		slog.Error("Firewall unblock command failed", "ip", ip, "output", string(output), "error", err) // ← DODANE
+		slog.Error("Firewall unblock command failed", "ip", ip, "output", string(output), "error", err)
 		return fmt.Errorf("error during unblocking IP %s: %v, output: %s", ip, err, string(output))
 	}
 
-	reloadCmd := exec.Command("sudo", "firewall-cmd", "--reload")
-	if out, err := reloadCmd.CombinedOutput(); err != nil {
-		slog.Error("Firewall reload failed", "output", string(out), "error", err) // ← DODANE
-		return fmt.Errorf("error during reloading firewalld service: %v, (%s)", err, string(out))
+	// Blocks made before the upgrade are still rich rules for this address
+	rule, err := firewalldLegacyRule(ip)
+	if err != nil {
+		return err
+	}
+	if _, err := runPrivileged("firewall-cmd", "--permanent", "--query-rich-rule", rule); err == nil {
+		output, err := runPrivileged("firewall-cmd", "--permanent", "--remove-rich-rule", rule)
+		if err != nil {
+			return fmt.Errorf("error during unblocking IP %s: %v, output: %s", ip, err, string(output))
+		}
+		if err := f.reload(); err != nil {
+			return err
+		}
 	}
 
-	slog.Info("IP unblocked in firewall", "ip", ip) // ← DODANE
+	slog.Info("IP unblocked in firewall", "ip", ip, "driver", DriverFirewalld)
 	return nil
 }
+
+// Listing entries of Firefighter's ipsets
+func (f *FirewalldEnforcer) List() ([]string, error) {
+	var ips []string
+	for _, set := range []string{firewalldIpset4, firewalldIpset6} {
+		output, err := runPrivileged("firewall-cmd", "--permanent", "--ipset="+set, "--get-entries")
+		if err != nil {
+			return nil, fmt.Errorf("error during listing firewalld ipset %s: %v, output: %s", set, err, string(output))
+		}
+		ips = append(ips, strings.Fields(string(output))...)
+	}
+	return ips, nil
+}
+
+// Emptying Firefighter's ipsets; other rich rules stay
+func (f *FirewalldEnforcer) Flush() error {
+	ips, err := f.List()
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range ips {
+		set, entry, err := firewalldEntry(ip)
+		if err != nil {
+			return err
+		}
+
+		output, err := runPrivileged("firewall-cmd", "--permanent", "--ipset="+set, "--remove-entry="+entry)
+		if err != nil {
+			return fmt.Errorf("error during unblocking IP %s: %v, output: %s", ip, err, string(output))
+		}
+	}
+
+	slog.Info("Firewall rules flushed", "count", len(ips), "driver", DriverFirewalld)
+	return f.reload()
+}
@@ -0,0 +1,91 @@
+package suricata
+
+import (
+	"firefighter/data"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EnforcerAction is a single call recorded by DryRunEnforcer
+type EnforcerAction struct {
+	Op   string    `json:"op"` // "block", "unblock", "flush"
+	IP   string    `json:"ip,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Recorded calls kept by DryRunEnforcer, older ones are dropped
+const maxDryRunActions = 1000
+
+// DryRunEnforcer never touches the firewall, it only records the latest calls
+type DryRunEnforcer struct {
+	mu      sync.Mutex
+	blocked map[string]bool
+	actions []EnforcerAction
+}
+
+func NewDryRunEnforcer() *DryRunEnforcer {
+	return &DryRunEnforcer{blocked: make(map[string]bool)}
+}
+
+func (d *DryRunEnforcer) record(op, ip string) {
+	if len(d.actions) >= maxDryRunActions {
+		n := copy(d.actions, d.actions[len(d.actions)-maxDryRunActions+1:])
+		d.actions = d.actions[:n]
+	}
+	d.actions = append(d.actions, EnforcerAction{Op: op, IP: ip, Time: time.Now()})
+}
+
+func (d *DryRunEnforcer) Block(ip string) error {
+	ip = data.CanonicalIP(ip)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.blocked[ip] = true
+	d.record("block", ip)
+	slog.Info("IP blocked (dry run)", "ip", ip, "driver", DriverDryRun)
+	return nil
+}
+
+func (d *DryRunEnforcer) Unblock(ip string) error {
+	ip = data.CanonicalIP(ip)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.blocked, ip)
+	d.record("unblock", ip)
+	slog.Info("IP unblocked (dry run)", "ip", ip, "driver", DriverDryRun)
+	return nil
+}
+
+func (d *DryRunEnforcer) List() ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ips := make([]string, 0, len(d.blocked))
+	for ip := range d.blocked {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
+
+func (d *DryRunEnforcer) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.blocked = make(map[string]bool)
+	d.record("flush", "")
+	return nil
+}
+
+// Returning copy of the recorded calls, oldest first
+func (d *DryRunEnforcer) Actions() []EnforcerAction {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]EnforcerAction(nil), d.actions...)
+}
@@ -0,0 +1,73 @@
+package suricata
+
+import (
+	"reflect"
+	"testing"
+
+	"firefighter/data"
+)
+
+func TestDryRunEnforcer(t *testing.T) {
+	d := NewDryRunEnforcer()
+	steps := []struct {
+		op   string
+		ip   string
+		list []string
+	}{
+		{"block", "192.0.2.1", []string{"192.0.2.1"}},
+		{"block", "::ffff:192.0.2.1", []string{"192.0.2.1"}},
+		{"block", "2001:0db8::0001", []string{"192.0.2.1", "2001:db8::1"}},
+		{"unblock", "192.0.2.1", []string{"2001:db8::1"}},
+		{"unblock", "203.0.113.1", []string{"2001:db8::1"}}, // not blocked is not an error
+		{"flush", "", []string{}},
+	}
+
+	var want []EnforcerAction
+	for _, s := range steps {
+		var err error
+		switch s.op {
+		case "block":
+			err = d.Block(s.ip)
+		case "unblock":
+			err = d.Unblock(s.ip)
+		case "flush":
+			err = d.Flush()
+		}
+		if err != nil {
+			t.Fatalf("%s %s: %v", s.op, s.ip, err)
+		}
+		list, _ := d.List()
+		if !reflect.DeepEqual(list, s.list) {
+			t.Errorf("after %s %s List() = %q, want %q", s.op, s.ip, list, s.list)
+		}
+		ip := s.ip
+		if ip != "" {
+			ip = data.CanonicalIP(ip)
+		}
+		want = append(want, EnforcerAction{Op: s.op, IP: ip})
+	}
+
+	got := d.Actions()
+	for i := range got {
+		got[i].Time = want[i].Time
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Actions() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDryRunEnforcerHistoryCap(t *testing.T) {
+	d := NewDryRunEnforcer()
+	for i := 0; i < maxDryRunActions+10; i++ {
+		d.Block("192.0.2.1")
+	}
+	d.Unblock("192.0.2.1")
+
+	actions := d.Actions()
+	if len(actions) != maxDryRunActions {
+		t.Fatalf("%d actions kept, want %d", len(actions), maxDryRunActions)
+	}
+	if last := actions[len(actions)-1]; last.Op != "unblock" {
+		t.Errorf("newest action %+v, want unblock", last)
+	}
+}
@@ -0,0 +1,186 @@
+package suricata
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const (
+	ipsetName  = "firefighter"
+	ipsetName6 = "firefighter6"
+)
+
+// IpsetEnforcer keeps blocked addresses and prefixes in hash:net ipsets
+// (one per family) matched by single iptables/ip6tables rules
+type IpsetEnforcer struct{}
+
+// Creating IpsetEnforcer and ensuring the sets and INPUT rules exist
+func NewIpsetEnforcer() (*IpsetEnforcer, error) {
+	families := []struct {
+		set, family, iptables string
+	}{
+		{ipsetName, "inet", "iptables"},
+		{ipsetName6, "inet6", "ip6tables"},
+	}
+
+	// Releases before IPv6 support made "firefighter" a hash:ip set, which
+	// `create -exist` refuses to turn into hash:net
+	if err := upgradeIpset(ipsetName); err != nil {
+		return nil, err
+	}
+
+	for _, f := range families {
+		if out, err := runPrivileged("ipset", "create", f.set, "hash:net", "family", f.family, "-exist"); err != nil {
+			return nil, fmt.Errorf("error during creating ipset %s: %v, output: %s", f.set, err, string(out))
+		}
+
+		rule := []string{"INPUT", "-m", "set", "--match-set", f.set, "src", "-j", "DROP"}
+		if _, err := runPrivileged(f.iptables, append([]string{"-C"}, rule...)...); err != nil {
+			if out, err := runPrivileged(f.iptables, append([]string{"-I"}, rule...)...); err != nil {
+				return nil, fmt.Errorf("error during inserting %s rule: %v, output: %s", f.iptables, err, string(out))
+			}
+		}
+	}
+
+	slog.Info("ipsets ready", "sets", []string{ipsetName, ipsetName6})
+	return &IpsetEnforcer{}, nil
+}
+
+// Recreating an existing hash:ip set as hash:net with the same members;
+// a temporary set keeps the addresses dropped meanwhile
+func upgradeIpset(set string) error {
+	output, err := runPrivileged("ipset", "list", set)
+	if err != nil || !strings.Contains(string(output), "Type: hash:ip\n") {
+		return nil
+	}
+	members := parseIpsetMembers(string(output))
+	slog.Info("Recreating ipset as hash:net", "set", set, "members", len(members))
+
+	run := func(args ...string) error {
+		if out, err := runPrivileged(args[0], args[1:]...); err != nil {
+			return fmt.Errorf("error during upgrading ipset %s: %v, output: %s", set, err, string(out))
+		}
+		return nil
+	}
+	rule := func(op, set string) []string {
+		return []string{"iptables", op, "INPUT", "-m", "set", "--match-set", set, "src", "-j", "DROP"}
+	}
+	create := func(name string) error {
+		if err := run("ipset", "create", name, "hash:net", "family", "inet", "-exist"); err != nil {
+			return err
+		}
+		for _, m := range members {
+			if err := run("ipset", "add", name, m, "-exist"); err != nil {
+				return err
+			}
+		}
+		return run(rule("-I", name)...)
+	}
+
+	tmp := set + "-upgrade"
+	if err := create(tmp); err != nil {
+		return err
+	}
+	if run(rule("-C", set)...) == nil {
+		if err := run(rule("-D", set)...); err != nil {
+			return err
+		}
+	}
+	if err := run("ipset", "destroy", set); err != nil {
+		return err
+	}
+	if err := create(set); err != nil {
+		return err
+	}
+	if err := run(rule("-D", tmp)...); err != nil {
+		return err
+	}
+	return run("ipset", "destroy", tmp)
+}
+
+// Set and entry text for an address or prefix
+func ipsetEntry(ip string) (string, string, error) {
+	target, err := parseTarget(ip)
+	if err != nil {
+		return "", "", err
+	}
+
+	if target.Addr().Is6() {
+		return ipsetName6, formatTarget(target), nil
+	}
+	return ipsetName, formatTarget(target), nil
+}
+
+func (s *IpsetEnforcer) Block(ip string) error {
+	set, entry, err := ipsetEntry(ip)
+	if err != nil {
+		return err
+	}
+
+	output, err := runPrivileged("ipset", "add", set, entry, "-exist")
+	if err != nil {
+		slog.Error("ipset block command failed", "ip", ip, "output", string(output), "error", err)
+		return fmt.Errorf("error during blocking IP %s: %v, output: %s", ip, err, string(output))
+	}
+
+	slog.Info("IP blocked in firewall", "ip", ip, "driver", DriverIpset)
+	return nil
+}
+
+func (s *IpsetEnforcer) Unblock(ip string) error {
+	set, entry, err := ipsetEntry(ip)
+	if err != nil {
+		return err
+	}
+
+	output, err := runPrivileged("ipset", "del", set, entry, "-exist")
+	if err != nil {
+		slog.Error("ipset unblock command failed", "ip", ip, "output", string(output), "error", err)
+		return fmt.Errorf("error during unblocking IP %s: %v, output: %s", ip, err, string(output))
+	}
+
+	slog.Info("IP unblocked in firewall", "ip", ip, "driver", DriverIpset)
+	return nil
+}
+
+func (s *IpsetEnforcer) List() ([]string, error) {
+	var ips []string
+	for _, set := range []string{ipsetName, ipsetName6} {
+		output, err := runPrivileged("ipset", "list", set)
+		if err != nil {
+			return nil, fmt.Errorf("error during listing ipset %s: %v, output: %s", set, err, string(output))
+		}
+		ips = append(ips, parseIpsetMembers(string(output))...)
+	}
+	return ips, nil
+}
+
+func (s *IpsetEnforcer) Flush() error {
+	for _, set := range []string{ipsetName, ipsetName6} {
+		output, err := runPrivileged("ipset", "flush", set)
+		if err != nil {
+			return fmt.Errorf("error during flushing ipset %s: %v, output: %s", set, err, string(output))
+		}
+	}
+
+	slog.Info("Firewall rules flushed", "driver", DriverIpset)
+	return nil
+}
+
+// Members are listed one per line after the "Members:" header
+func parseIpsetMembers(output string) []string {
+	var ips []string
+	inMembers := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "Members:" {
+			inMembers = true
+			continue
+		}
+		if inMembers && line != "" {
+			ips = append(ips, strings.Fields(line)[0])
+		}
+	}
+	return ips
+}
@@ -0,0 +1,77 @@
+package suricata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIpsetMembers(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name: "hash:net members",
+			output: `Name: firefighter
+Type: hash:net
+Revision: 7
+Header: family inet hashsize 1024 maxelem 65536 bucketsize 12 initval 0x5d2f7c1a
+Size in memory: 600
+References: 1
+Number of entries: 3
+Members:
+192.0.2.1
+198.51.100.0/24
+203.0.113.9
+`,
+			want: []string{"192.0.2.1", "198.51.100.0/24", "203.0.113.9"},
+		},
+		{
+			name: "entries with timeout and counters",
+			output: `Name: firefighter6
+Type: hash:net
+Header: family inet6 hashsize 1024 maxelem 65536 timeout 300 counters
+Members:
+2001:db8::1 timeout 120 packets 4 bytes 240
+2001:db8:1::/64 timeout 60 packets 0 bytes 0
+`,
+			want: []string{"2001:db8::1", "2001:db8:1::/64"},
+		},
+		{
+			name:   "empty set",
+			output: "Name: firefighter\nType: hash:net\nMembers:\n",
+		},
+		{
+			name:   "no members header",
+			output: "ipset v7.19: The set with the given name does not exist\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseIpsetMembers(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIpsetMembers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIpsetEntry(t *testing.T) {
+	tests := []struct {
+		ip, set, entry string
+		wantErr        bool
+	}{
+		{ip: "192.0.2.1", set: ipsetName, entry: "192.0.2.1"},
+		{ip: "192.0.2.1/32", set: ipsetName, entry: "192.0.2.1"},
+		{ip: "10.1.2.3/8", set: ipsetName, entry: "10.0.0.0/8"},
+		{ip: "2001:db8::1", set: ipsetName6, entry: "2001:db8::1"},
+		{ip: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		set, entry, err := ipsetEntry(tt.ip)
+		if (err != nil) != tt.wantErr || set != tt.set || entry != tt.entry {
+			t.Errorf("ipsetEntry(%q) = %q, %q, %v, want %q, %q", tt.ip, set, entry, err, tt.set, tt.entry)
+		}
+	}
+}
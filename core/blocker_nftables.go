@@ -0,0 +1,219 @@
+package suricata
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const (
+	nftTable = "firefighter"
+	nftSet4  = "blocked4"
+	nftSet6  = "blocked6"
+	nftChain = "input"
+)
+
+// NftablesEnforcer keeps blocked addresses and prefixes in native nftables sets, one per family
+type NftablesEnforcer struct{}
+
+// Creating NftablesEnforcer and ensuring table, sets and drop rules exist.
+// Sets left by earlier releases without "flags interval" cannot take
+// prefixes and `add set` refuses to redefine them, so they are recreated
+// with their elements in the same transaction.
+func NewNftablesEnforcer() (*NftablesEnforcer, error) {
+	var deletes, adds strings.Builder
+	for _, set := range []string{nftSet4, nftSet6} {
+		elems, found, err := nftLegacySet(set)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		fmt.Fprintf(&deletes, "delete set inet %s %s\n", nftTable, set)
+		if len(elems) > 0 {
+			fmt.Fprintf(&adds, "add element inet %s %s { %s }\n", nftTable, set, strings.Join(elems, ", "))
+		}
+		slog.Info("Recreating nftables set with interval flag", "set", set, "elements", len(elems))
+	}
+
+	// Rules go first so a set they referenced can be deleted
+	ruleset := fmt.Sprintf(`add table inet %[1]s
+add chain inet %[1]s %[4]s { type filter hook input priority -10; policy accept; }
+flush chain inet %[1]s %[4]s
+%[5]sadd set inet %[1]s %[2]s { type ipv4_addr; flags interval; }
+add set inet %[1]s %[3]s { type ipv6_addr; flags interval; }
+%[6]sadd rule inet %[1]s %[4]s ip saddr @%[2]s drop
+add rule inet %[1]s %[4]s ip6 saddr @%[3]s drop
+`, nftTable, nftSet4, nftSet6, nftChain, deletes.String(), adds.String())
+
+	if out, err := runNft(ruleset); err != nil {
+		return nil, fmt.Errorf("error during nftables setup: %v, output: %s", err, string(out))
+	}
+
+	slog.Info("nftables sets ready", "table", nftTable, "sets", []string{nftSet4, nftSet6})
+	return &NftablesEnforcer{}, nil
+}
+
+// Elements of set when it exists without the interval flag; a missing
+// set (or table) is not an error
+func nftLegacySet(set string) ([]string, bool, error) {
+	output, err := runPrivileged("nft", "-j", "list", "set", "inet", nftTable, set)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	var doc struct {
+		Nftables []struct {
+			Set *struct {
+				Flags json.RawMessage `json:"flags"` // a list, or one string in some nft versions
+			} `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(output, &doc); err != nil {
+		return nil, false, fmt.Errorf("error during parsing nftables output: %w", err)
+	}
+	for _, item := range doc.Nftables {
+		if item.Set != nil && strings.Contains(string(item.Set.Flags), `"interval"`) {
+			return nil, false, nil
+		}
+	}
+
+	elems, err := parseNftSet(output)
+	if err != nil {
+		return nil, false, err
+	}
+	return elems, true, nil
+}
+
+// Set and element text for an address or prefix
+func nftElement(ip string) (string, string, error) {
+	target, err := parseTarget(ip)
+	if err != nil {
+		return "", "", err
+	}
+
+	if target.Addr().Is6() {
+		return nftSet6, formatTarget(target), nil
+	}
+	return nftSet4, formatTarget(target), nil
+}
+
+// Applying nft script passed on stdin
+func runNft(script string) ([]byte, error) {
+	return runPrivilegedInput(script, "nft", "-f", "-")
+}
+
+func (n *NftablesEnforcer) Block(ip string) error {
+	set, elem, err := nftElement(ip)
+	if err != nil {
+		return err
+	}
+
+	output, err := runPrivileged("nft", "add", "element", "inet", nftTable, set, "{", elem, "}")
+	if err != nil {
+		slog.Error("nftables block command failed", "ip", ip, "output", string(output), "error", err)
+		return fmt.Errorf("error during blocking IP %s: %v, output: %s", ip, err, string(output))
+	}
+
+	slog.Info("IP blocked in firewall", "ip", ip, "driver", DriverNftables)
+	return nil
+}
+
+func (n *NftablesEnforcer) Unblock(ip string) error {
+	set, elem, err := nftElement(ip)
+	if err != nil {
+		return err
+	}
+
+	// Like ipset -exist: an element already gone (set flushed or reloaded
+	// by hand) counts as unblocked, so expiry does not retry it forever
+	output, err := runPrivileged("nft", "delete", "element", "inet", nftTable, set, "{", elem, "}")
+	if err != nil && nftMissing(output) {
+		slog.Info("IP was not in nftables set", "ip", ip, "set", set)
+		return nil
+	}
+	if err != nil {
+		slog.Error("nftables unblock command failed", "ip", ip, "output", string(output), "error", err)
+		return fmt.Errorf("error during unblocking IP %s: %v, output: %s", ip, err, string(output))
+	}
+
+	slog.Info("IP unblocked in firewall", "ip", ip, "driver", DriverNftables)
+	return nil
+}
+
+func (n *NftablesEnforcer) List() ([]string, error) {
+	var ips []string
+	for _, set := range []string{nftSet4, nftSet6} {
+		output, err := runPrivileged("nft", "-j", "list", "set", "inet", nftTable, set)
+		if err != nil {
+			return nil, fmt.Errorf("error during listing nftables set %s: %v, output: %s", set, err, string(output))
+		}
+
+		elems, err := parseNftSet(output)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, elems...)
+	}
+	return ips, nil
+}
+
+func (n *NftablesEnforcer) Flush() error {
+	for _, set := range []string{nftSet4, nftSet6} {
+		output, err := runPrivileged("nft", "flush", "set", "inet", nftTable, set)
+		if err != nil {
+			return fmt.Errorf("error during flushing nftables set %s: %v, output: %s", set, err, string(output))
+		}
+	}
+
+	slog.Info("Firewall rules flushed", "driver", DriverNftables)
+	return nil
+}
+
+// nft reports a missing element, set or table as ENOENT
+func nftMissing(output []byte) bool {
+	return strings.Contains(string(output), "No such file or directory")
+}
+
+// Parsing `nft -j list set` output; elements are address strings or
+// {"prefix": {"addr": ..., "len": ...}} objects in interval sets
+func parseNftSet(output []byte) ([]string, error) {
+	var doc struct {
+		Nftables []struct {
+			Set *struct {
+				Elem []json.RawMessage `json:"elem"`
+			} `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(output, &doc); err != nil {
+		return nil, fmt.Errorf("error during parsing nftables output: %w", err)
+	}
+
+	var ips []string
+	for _, item := range doc.Nftables {
+		if item.Set == nil {
+			continue
+		}
+		for _, raw := range item.Set.Elem {
+			var ip string
+			if err := json.Unmarshal(raw, &ip); err == nil {
+				ips = append(ips, strings.TrimSpace(ip))
+				continue
+			}
+
+			var elem struct {
+				Prefix *struct {
+					Addr string `json:"addr"`
+					Len  int    `json:"len"`
+				} `json:"prefix"`
+			}
+			if err := json.Unmarshal(raw, &elem); err == nil && elem.Prefix != nil {
+				ips = append(ips, fmt.Sprintf("%s/%d", elem.Prefix.Addr, elem.Prefix.Len))
+			}
+		}
+	}
+	return ips, nil
+}
@@ -0,0 +1,84 @@
+package suricata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNftSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "addresses and prefixes",
+			output: `{"nftables": [{"metainfo": {"version": "1.0.9", "json_schema_version": 1}}, {"set": {"family": "inet", "name": "blocked4", "table": "firefighter", "type": "ipv4_addr", "flags": ["interval"], "elem": ["192.0.2.1", {"prefix": {"addr": "198.51.100.0", "len": 24}}, " 203.0.113.9 "]}}]}`,
+			want:   []string{"192.0.2.1", "198.51.100.0/24", "203.0.113.9"},
+		},
+		{
+			name:   "IPv6",
+			output: `{"nftables": [{"set": {"name": "blocked6", "elem": ["2001:db8::1", {"prefix": {"addr": "2001:db8:1::", "len": 64}}]}}]}`,
+			want:   []string{"2001:db8::1", "2001:db8:1::/64"},
+		},
+		{
+			name:   "empty set has no elem",
+			output: `{"nftables": [{"metainfo": {}}, {"set": {"name": "blocked4", "flags": "interval"}}]}`,
+		},
+		{
+			name:   "unknown element kinds are skipped",
+			output: `{"nftables": [{"set": {"elem": [{"range": ["192.0.2.1", "192.0.2.9"]}, "192.0.2.11"]}}]}`,
+			want:   []string{"192.0.2.11"},
+		},
+		{name: "not JSON", output: `table inet firefighter {`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNftSet([]byte(tt.output))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNftSet() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNftSet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNftElement(t *testing.T) {
+	tests := []struct {
+		ip, set, elem string
+		wantErr       bool
+	}{
+		{ip: "192.0.2.1", set: nftSet4, elem: "192.0.2.1"},
+		{ip: "::ffff:192.0.2.1", set: nftSet4, elem: "192.0.2.1"},
+		{ip: "198.51.100.7/24", set: nftSet4, elem: "198.51.100.0/24"},
+		{ip: "2001:db8::1", set: nftSet6, elem: "2001:db8::1"},
+		{ip: "2001:db8::/64", set: nftSet6, elem: "2001:db8::/64"},
+		{ip: "host.example", wantErr: true},
+	}
+	for _, tt := range tests {
+		set, elem, err := nftElement(tt.ip)
+		if (err != nil) != tt.wantErr || set != tt.set || elem != tt.elem {
+			t.Errorf("nftElement(%q) = %q, %q, %v, want %q, %q", tt.ip, set, elem, err, tt.set, tt.elem)
+		}
+	}
+}
+
+func TestNftMissing(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"Error: Could not process rule: No such file or directory\ndelete element inet firefighter blocked4 { 192.0.2.1 }\n", true},
+		{"Error: Could not process rule: Operation not permitted\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := nftMissing([]byte(tt.output)); got != tt.want {
+			t.Errorf("nftMissing(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
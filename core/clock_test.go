@@ -0,0 +1,36 @@
+package suricata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		step func(c *ManualClock)
+		want time.Time
+	}{
+		{"start", func(c *ManualClock) {}, start},
+		{"advance", func(c *ManualClock) { c.Advance(90 * time.Second) }, start.Add(90 * time.Second)},
+		{"set forward", func(c *ManualClock) { c.Set(start.Add(time.Hour)) }, start.Add(time.Hour)},
+		{"set backwards is ignored", func(c *ManualClock) { c.Set(start.Add(-time.Hour)) }, start},
+		{"set to now", func(c *ManualClock) { c.Set(start) }, start},
+		{"advance then set behind", func(c *ManualClock) {
+			c.Advance(time.Minute)
+			c.Set(start.Add(30 * time.Second))
+		}, start.Add(time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewManualClock(start)
+			tt.step(c)
+			if got := c.Now(); !got.Equal(tt.want) {
+				t.Errorf("Now() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package suricata
+
+import (
+	"encoding/json"
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Called for every EVE record of the type it was registered for
+type EventHandler func(EveEvent)
+
+// Metadata seen on a flow, attached to alerts raised later on the same flow
+type flowContext struct {
+	appProto string
+	http     *EveHTTP
+	tls      *EveTLS
+	dns      *EveDNS
+	seen     time.Time
+}
+
+// EventDispatcher parses EVE lines of every type. Alerts are returned to the
+// reader (and enriched with HTTP/TLS/DNS metadata seen earlier on their flow),
+// everything else goes to handlers registered for its event type. Non-alert
+// records are not stored or scored themselves: they reach analysis and the
+// database only as metadata of alerts on the same flow, and are counted.
+type EventDispatcher struct {
+	// Flow metadata is kept this long after it was last seen, for at most MaxFlows flows
+	FlowTTL  time.Duration
+	MaxFlows int
+
+	mu       sync.Mutex
+	handlers map[string][]EventHandler
+	flows    map[uint64]*flowContext
+	counts   map[string]uint64
+}
+
+func NewEventDispatcher() *EventDispatcher {
+	return &EventDispatcher{
+		FlowTTL:  5 * time.Minute,
+		MaxFlows: 100000,
+		handlers: make(map[string][]EventHandler),
+		flows:    make(map[uint64]*flowContext),
+		counts:   make(map[string]uint64),
+	}
+}
+
+// Registering a handler for an event type (EventFlow, EventStats, ...)
+func (d *EventDispatcher) Handle(eventType string, h EventHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[eventType] = append(d.handlers[eventType], h)
+}
+
+// Records received per event type
+func (d *EventDispatcher) Counts() map[string]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[string]uint64, len(d.counts))
+	for t, n := range d.counts {
+		out[t] = n
+	}
+	return out
+}
+
+// Parsing one EVE line and dispatching non-alert records to their handlers;
+// ok=true only for alerts the pipeline should score (LineDecoder)
+func (d *EventDispatcher) Decode(line []byte) (Alert, bool) {
+	var event EveEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		log.Printf("[Suricata] Błąd parsowania JSON: %v\nJSON: %s", err, line)
+		return Alert{}, false
+	}
+	event.ParsedTime = parseEveTime(event.Timestamp)
+	if event.EventType == "" && event.Alert != nil {
+		// Older socket outputs without event_type
+		event.EventType = EventAlert
+	}
+
+	d.mu.Lock()
+	d.counts[event.EventType]++
+	handlers := d.handlers[event.EventType]
+	if event.EventType != EventAlert {
+		d.remember(&event)
+	}
+	d.mu.Unlock()
+
+	for _, h := range handlers {
+		h(event)
+	}
+
+	if event.EventType != EventAlert || event.Alert == nil {
+		return Alert{}, false
+	}
+
+	alert := event.toAlert()
+
+	// filtering SID == 0
+	if alert.Alert.SignatureID == 0 {
+		return alert, false
+	}
+
+	if err := normalizeAlert(&alert); err != nil {
+		slog.Warn("Alert with invalid address skipped", "error", err)
+		return alert, false
+	}
+
+	d.enrich(&alert)
+	return alert, true
+}
+
+// Keeping app-layer metadata per flow, aged by event time (replays too); caller holds mu
+func (d *EventDispatcher) remember(e *EveEvent) {
+	if e.FlowID == 0 {
+		return
+	}
+
+	// Flow record = flow ended, no alert can follow
+	if e.EventType == EventFlow {
+		delete(d.flows, e.FlowID)
+		return
+	}
+	if e.HTTP == nil && e.TLS == nil && e.DNS == nil {
+		return
+	}
+
+	fc, ok := d.flows[e.FlowID]
+	if !ok {
+		if len(d.flows) >= d.MaxFlows {
+			d.pruneFlows(e.ParsedTime)
+		}
+		fc = &flowContext{}
+		d.flows[e.FlowID] = fc
+	}
+	if e.AppProto != "" {
+		fc.appProto = e.AppProto
+	}
+	if e.HTTP != nil {
+		fc.http = e.HTTP
+	}
+	if e.TLS != nil {
+		fc.tls = e.TLS
+	}
+	if e.DNS != nil {
+		fc.dns = e.DNS
+	}
+	fc.seen = e.ParsedTime
+}
+
+// Dropping stale flows, and half of the rest if that is not enough; caller holds mu
+func (d *EventDispatcher) pruneFlows(now time.Time) {
+	cutoff := now.Add(-d.FlowTTL)
+	for id, fc := range d.flows {
+		if fc.seen.Before(cutoff) {
+			delete(d.flows, id)
+		}
+	}
+
+	if len(d.flows) < d.MaxFlows {
+		return
+	}
+	excess := len(d.flows) - d.MaxFlows/2
+	for id := range d.flows {
+		if excess <= 0 {
+			break
+		}
+		delete(d.flows, id)
+		excess--
+	}
+}
+
+// Filling alert metadata Suricata did not include from earlier records of the flow
+func (d *EventDispatcher) enrich(a *Alert) {
+	if a.FlowID == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	fc, ok := d.flows[a.FlowID]
+	if !ok {
+		return
+	}
+	if a.AppProto == "" {
+		a.AppProto = fc.appProto
+	}
+	if a.HTTP == nil {
+		a.HTTP = fc.http
+	}
+	if a.TLS == nil {
+		a.TLS = fc.tls
+	}
+	if a.DNS == nil {
+		a.DNS = fc.dns
+	}
+}
@@ -0,0 +1,52 @@
+package suricata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventDispatcher(t *testing.T) {
+	d := NewEventDispatcher()
+	var got []string
+	record := func(e EveEvent) { got = append(got, e.EventType) }
+	d.Handle(EventFlow, record)
+	d.Handle(EventStats, record)
+	d.Handle(EventAlert, record)
+
+	lines := []string{
+		`{"timestamp":"2026-01-02T03:04:05.000000+0000","flow_id":7,"event_type":"tls","src_ip":"192.0.2.1","dest_ip":"198.51.100.1","proto":"TCP","app_proto":"tls","tls":{"sni":"example.org","version":"TLS 1.3"}}`,
+		`{"timestamp":"2026-01-02T03:04:05.000000+0000","flow_id":8,"event_type":"http","src_ip":"192.0.2.2","dest_ip":"198.51.100.1","proto":"TCP","http":{"hostname":"example.com","url":"/"}}`,
+		`{"timestamp":"2026-01-02T03:04:06.000000+0000","event_type":"stats","stats":{"uptime":60}}`,
+		`{"timestamp":"2026-01-02T03:04:06.000000+0000","event_type":"anomaly","anomaly":{"type":"decode","event":"ipv4.trunc_pkt"}}`,
+		// Flow 8 ended, its HTTP metadata is forgotten
+		`{"timestamp":"2026-01-02T03:04:07.000000+0000","flow_id":8,"event_type":"flow","src_ip":"192.0.2.2","dest_ip":"198.51.100.1","proto":"TCP","flow":{"bytes_toserver":100}}`,
+		`not json`,
+	}
+	for _, l := range lines {
+		if _, ok := d.Decode([]byte(l)); ok {
+			t.Errorf("non-alert record returned as alert: %s", l)
+		}
+	}
+
+	alert, ok := d.Decode([]byte(`{"timestamp":"2026-01-02T03:04:08.000000+0000","flow_id":7,"event_type":"alert","src_ip":"192.0.2.1","src_port":40000,"dest_ip":"198.51.100.1","dest_port":443,"proto":"TCP","alert":{"signature_id":2000001,"signature":"test","severity":2}}`))
+	if !ok {
+		t.Fatal("alert not returned")
+	}
+	if alert.TLS == nil || alert.TLS.SNI != "example.org" || alert.AppProto != "tls" {
+		t.Errorf("alert not enriched from its flow: app_proto %q, tls %+v", alert.AppProto, alert.TLS)
+	}
+	if alert, ok := d.Decode([]byte(`{"flow_id":8,"event_type":"alert","src_ip":"192.0.2.2","dest_ip":"198.51.100.1","proto":"TCP","alert":{"signature_id":2000002}}`)); !ok || alert.HTTP != nil {
+		t.Errorf("alert on an ended flow: ok %v, http %+v", ok, alert.HTTP)
+	}
+	if _, ok := d.Decode([]byte(`{"event_type":"alert","src_ip":"192.0.2.3","alert":{"signature_id":0}}`)); ok {
+		t.Error("alert with SID 0 returned")
+	}
+
+	if want := []string{EventStats, EventFlow, EventAlert, EventAlert, EventAlert}; !reflect.DeepEqual(got, want) {
+		t.Errorf("handlers called for %q, want %q", got, want)
+	}
+	want := map[string]uint64{EventTLS: 1, EventHTTP: 1, EventStats: 1, EventAnomaly: 1, EventFlow: 1, EventAlert: 3}
+	if counts := d.Counts(); !reflect.DeepEqual(counts, want) {
+		t.Errorf("Counts() = %v, want %v", counts, want)
+	}
+}
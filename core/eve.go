@@ -0,0 +1,167 @@
+package suricata
+
+import "time"
+
+// EVE event types handled by EventDispatcher
+const (
+	EventAlert    = "alert"
+	EventFlow     = "flow"
+	EventDNS      = "dns"
+	EventHTTP     = "http"
+	EventTLS      = "tls"
+	EventSSH      = "ssh"
+	EventAnomaly  = "anomaly"
+	EventFileInfo = "fileinfo"
+	EventStats    = "stats"
+)
+
+// One EVE record of any type; only the section matching EventType is set
+type EveEvent struct {
+	Timestamp string `json:"timestamp"`
+	FlowID    uint64 `json:"flow_id"`
+	EventType string `json:"event_type"`
+	SrcIP     string `json:"src_ip"`
+	SrcPort   int    `json:"src_port"`
+	DstIP     string `json:"dest_ip"`
+	DstPort   int    `json:"dest_port"`
+	Proto     string `json:"proto"`
+	AppProto  string `json:"app_proto,omitempty"`
+
+	Alert    *AlertInfo   `json:"alert,omitempty"`
+	Flow     *EveFlow     `json:"flow,omitempty"`
+	DNS      *EveDNS      `json:"dns,omitempty"`
+	HTTP     *EveHTTP     `json:"http,omitempty"`
+	TLS      *EveTLS      `json:"tls,omitempty"`
+	SSH      *EveSSH      `json:"ssh,omitempty"`
+	Anomaly  *EveAnomaly  `json:"anomaly,omitempty"`
+	FileInfo *EveFileInfo `json:"fileinfo,omitempty"`
+	Stats    *EveStats    `json:"stats,omitempty"`
+
+	ParsedTime time.Time `json:"-"`
+}
+
+type EveFlow struct {
+	PktsToServer  int64  `json:"pkts_toserver"`
+	PktsToClient  int64  `json:"pkts_toclient"`
+	BytesToServer int64  `json:"bytes_toserver"`
+	BytesToClient int64  `json:"bytes_toclient"`
+	Start         string `json:"start"`
+	End           string `json:"end,omitempty"`
+	Age           int64  `json:"age,omitempty"`
+	State         string `json:"state,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	Alerted       bool   `json:"alerted,omitempty"`
+}
+
+type EveDNS struct {
+	Type   string `json:"type"` // query / answer
+	ID     int    `json:"id"`
+	RRName string `json:"rrname"`
+	RRType string `json:"rrtype"`
+	RCode  string `json:"rcode,omitempty"`
+}
+
+type EveHTTP struct {
+	Hostname  string `json:"hostname"`
+	URL       string `json:"url"`
+	UserAgent string `json:"http_user_agent,omitempty"`
+	Method    string `json:"http_method,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	Length    int64  `json:"length,omitempty"`
+}
+
+type EveTLS struct {
+	SNI         string `json:"sni"`
+	Version     string `json:"version"`
+	Subject     string `json:"subject,omitempty"`
+	Issuer      string `json:"issuerdn,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	JA3         *struct {
+		Hash string `json:"hash"`
+	} `json:"ja3,omitempty"`
+}
+
+type EveSSH struct {
+	Client *EveSSHPeer `json:"client,omitempty"`
+	Server *EveSSHPeer `json:"server,omitempty"`
+}
+
+type EveSSHPeer struct {
+	ProtoVersion    string `json:"proto_version"`
+	SoftwareVersion string `json:"software_version"`
+}
+
+type EveAnomaly struct {
+	Type  string `json:"type"` // decode / stream / applayer
+	Event string `json:"event"`
+	Layer string `json:"layer,omitempty"`
+}
+
+type EveFileInfo struct {
+	Filename string `json:"filename"`
+	Magic    string `json:"magic,omitempty"`
+	Size     int64  `json:"size"`
+	State    string `json:"state,omitempty"`
+	Stored   bool   `json:"stored"`
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+// Subset of the periodic engine counters
+type EveStats struct {
+	Uptime  int64 `json:"uptime"`
+	Capture struct {
+		KernelPackets int64 `json:"kernel_packets"`
+		KernelDrops   int64 `json:"kernel_drops"`
+	} `json:"capture"`
+	Decoder struct {
+		Pkts  int64 `json:"pkts"`
+		Bytes int64 `json:"bytes"`
+	} `json:"decoder"`
+}
+
+// Turning an alert record into the Alert the pipeline works with
+func (e *EveEvent) toAlert() Alert {
+	a := Alert{
+		Timestamp:  e.Timestamp,
+		FlowID:     e.FlowID,
+		EventType:  e.EventType,
+		SrcIP:      e.SrcIP,
+		SrcPort:    e.SrcPort,
+		DstIP:      e.DstIP,
+		DstPort:    e.DstPort,
+		Proto:      e.Proto,
+		AppProto:   e.AppProto,
+		Flow:       e.Flow,
+		HTTP:       e.HTTP,
+		TLS:        e.TLS,
+		DNS:        e.DNS,
+		Source:     SourceSuricata,
+		ParsedTime: e.ParsedTime,
+	}
+	if e.Alert != nil {
+		a.Alert = *e.Alert
+	}
+	return a
+}
+
+// Suricata writes "+0000" offsets, RFC3339 wants "+00:00"
+const eveTimeLayout = "2006-01-02T15:04:05.999999999-0700"
+
+func parseEveTime(ts string) time.Time {
+	if t, err := parseEveTimestamp(ts); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+func parseEveTimestamp(ts string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{eveTimeLayout, time.RFC3339Nano} {
+		var t time.Time
+		if t, err = time.Parse(layout, ts); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
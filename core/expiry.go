@@ -0,0 +1,178 @@
+package suricata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"firefighter/data"
+)
+
+// EscalationPolicy lengthens each subsequent block of the same IP
+type EscalationPolicy struct {
+	// Block duration per offence, 0 means permanent; the last step repeats
+	Steps []time.Duration
+	// Only blocks newer than this count as previous offences, 0 = whole history
+	Lookback time.Duration
+}
+
+// 1h, 24h, 7d, permanent
+var DefaultEscalation = EscalationPolicy{
+	Steps: []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour, 0},
+}
+
+// Duration of the next block after the given number of previous blocks
+func (p EscalationPolicy) Duration(previous int) time.Duration {
+	if len(p.Steps) == 0 {
+		return 0
+	}
+	if previous >= len(p.Steps) {
+		previous = len(p.Steps) - 1
+	}
+	return p.Steps[previous]
+}
+
+// Computing unblock_time for a new block of ip using its block history, 0 = permanent
+func (p EscalationPolicy) UnblockTime(ctx context.Context, db data.Repository, ip string, now time.Time) (int64, time.Duration, error) {
+	history, err := db.GetBlockedByIP(ctx, ip)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	previous := 0
+	for _, b := range history {
+		if p.Lookback > 0 && time.Unix(b.Timestamp, 0).Before(now.Add(-p.Lookback)) {
+			continue
+		}
+		previous++
+	}
+
+	unblockTime, duration := p.After(previous, now)
+	return unblockTime, duration, nil
+}
+
+// unblock_time and duration of a block starting now after the given number
+// of previous blocks, 0 = permanent
+func (p EscalationPolicy) After(previous int, now time.Time) (int64, time.Duration) {
+	duration := p.Duration(previous)
+	if duration <= 0 {
+		return 0, 0
+	}
+	return now.Add(duration).Unix(), duration
+}
+
+// ExpiryScheduler periodically lifts blocks whose unblock_time has passed
+// and whitelist entries whose expires_at has passed
+type ExpiryScheduler struct {
+	DB        data.Repository
+	Enforcer  Enforcer
+	Windows   *WindowManager
+	Whitelist *Whitelist
+	Interval  time.Duration
+
+	// Called after each lifted block (e.g. WebSocket broadcast)
+	OnUnblock func(ip string)
+}
+
+func NewExpiryScheduler(db data.Repository, enforcer Enforcer, wm *WindowManager, interval time.Duration) *ExpiryScheduler {
+	return &ExpiryScheduler{
+		DB:       db,
+		Enforcer: enforcer,
+		Windows:  wm,
+		Interval: interval,
+	}
+}
+
+// Running until stop is closed; blocks that expired while the service was down are lifted on the first pass
+func (s *ExpiryScheduler) Run(stop <-chan struct{}) {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if _, err := s.LiftExpired(ctx, now); err != nil {
+			slog.Error("Block expiry pass failed", "error", err)
+		}
+		if err := s.ExpireWhitelist(ctx, now); err != nil {
+			slog.Error("Whitelist expiry pass failed", "error", err)
+		}
+		// Windows of sources that went quiet are only evicted here
+		if s.Windows != nil {
+			s.Windows.Sweep()
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Context cancelled when stop is closed, so shutdown interrupts queries in flight
+func stopContext(stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Lifting every expired block, returns how many were lifted
+func (s *ExpiryScheduler) LiftExpired(ctx context.Context, now time.Time) (int, error) {
+	expired, err := s.DB.GetExpiredBlocks(ctx, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	lifted := 0
+	for _, b := range expired {
+		if err := s.Enforcer.Unblock(b.IP); err != nil {
+			slog.Error("Expired block firewall removal failed", "ip", b.IP, "error", err)
+			continue
+		}
+
+		if err := s.DB.UnblockIP(ctx, b.IP); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Expired block database update failed", "ip", b.IP, "error", err)
+			continue
+		}
+
+		if s.Windows != nil {
+			s.Windows.RemoveIP(b.IP)
+		}
+
+		slog.Info("Block expired, IP unblocked", "ip", b.IP, "blocked_at", b.Timestamp)
+		if s.OnUnblock != nil {
+			s.OnUnblock(b.IP)
+		}
+		lifted++
+	}
+
+	return lifted, nil
+}
+
+// Soft-deleting expired whitelist entries and refreshing the in-memory copy
+func (s *ExpiryScheduler) ExpireWhitelist(ctx context.Context, now time.Time) error {
+	expired, err := s.DB.ExpireWhitelist(ctx, now.Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range expired {
+		slog.Info("Whitelist entry expired", "entry", entry)
+	}
+
+	if len(expired) > 0 && s.Whitelist != nil {
+		return s.Whitelist.Load(ctx, s.DB)
+	}
+	return nil
+}
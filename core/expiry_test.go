@@ -0,0 +1,177 @@
+package suricata
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"firefighter/data"
+)
+
+func TestEscalationDuration(t *testing.T) {
+	steps := []time.Duration{time.Hour, 24 * time.Hour, 0}
+
+	tests := []struct {
+		name     string
+		steps    []time.Duration
+		previous int
+		want     time.Duration
+	}{
+		{"first offence", steps, 0, time.Hour},
+		{"second offence", steps, 1, 24 * time.Hour},
+		{"permanent step", steps, 2, 0},
+		{"past the last step", steps, 10, 0},
+		{"last step repeats", []time.Duration{time.Hour, 2 * time.Hour}, 5, 2 * time.Hour},
+		{"no steps is permanent", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := EscalationPolicy{Steps: tt.steps}
+			if got := p.Duration(tt.previous); got != tt.want {
+				t.Errorf("Duration(%d) = %s, want %s", tt.previous, got, tt.want)
+			}
+		})
+	}
+}
+
+// Block history and expired blocks kept in memory
+type blockRepo struct {
+	data.Repository
+	history   map[string][]data.BlockedIPDetails
+	expired   []data.BlockedIPDetails
+	unblocked []string
+	err       error
+}
+
+func (r *blockRepo) GetBlockedByIP(_ context.Context, ip string) ([]data.BlockedIPDetails, error) {
+	return r.history[ip], r.err
+}
+
+func (r *blockRepo) GetExpiredBlocks(_ context.Context, now int64) ([]data.BlockedIPDetails, error) {
+	var out []data.BlockedIPDetails
+	for _, b := range r.expired {
+		if b.UnblockTime > 0 && b.UnblockTime <= now {
+			out = append(out, b)
+		}
+	}
+	return out, r.err
+}
+
+func (r *blockRepo) UnblockIP(_ context.Context, ip string) error {
+	r.unblocked = append(r.unblocked, ip)
+	return nil
+}
+
+func TestUnblockTime(t *testing.T) {
+	now := time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) data.BlockedIPDetails {
+		return data.BlockedIPDetails{Timestamp: now.Add(-d).Unix()}
+	}
+	policy := EscalationPolicy{Steps: []time.Duration{time.Hour, 24 * time.Hour, 0}}
+
+	tests := []struct {
+		name     string
+		lookback time.Duration
+		history  []data.BlockedIPDetails
+		duration time.Duration
+	}{
+		{"no history", 0, nil, time.Hour},
+		{"one previous block", 0, []data.BlockedIPDetails{ago(time.Hour)}, 24 * time.Hour},
+		{"two previous blocks", 0, []data.BlockedIPDetails{ago(time.Hour), ago(48 * time.Hour)}, 0},
+		{"old blocks outside lookback", 7 * 24 * time.Hour,
+			[]data.BlockedIPDetails{ago(8 * 24 * time.Hour), ago(30 * 24 * time.Hour)}, time.Hour},
+		{"lookback keeps recent blocks", 7 * 24 * time.Hour,
+			[]data.BlockedIPDetails{ago(time.Hour), ago(8 * 24 * time.Hour)}, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy
+			p.Lookback = tt.lookback
+			db := &blockRepo{history: map[string][]data.BlockedIPDetails{"192.0.2.1": tt.history}}
+
+			unblockTime, duration, err := p.UnblockTime(context.Background(), db, "192.0.2.1", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if duration != tt.duration {
+				t.Errorf("duration %s, want %s", duration, tt.duration)
+			}
+			want := int64(0)
+			if tt.duration > 0 {
+				want = now.Add(tt.duration).Unix()
+			}
+			if unblockTime != want {
+				t.Errorf("unblock time %d, want %d", unblockTime, want)
+			}
+		})
+	}
+
+	if _, _, err := policy.UnblockTime(context.Background(), &blockRepo{err: errors.New("timeout")}, "192.0.2.1", now); err == nil {
+		t.Error("history lookup error not returned")
+	}
+}
+
+// Fails to unblock the listed addresses
+type stuckEnforcer struct {
+	*DryRunEnforcer
+	stuck map[string]bool
+}
+
+func (e stuckEnforcer) Unblock(ip string) error {
+	if e.stuck[ip] {
+		return errors.New("firewall busy")
+	}
+	return e.DryRunEnforcer.Unblock(ip)
+}
+
+func TestLiftExpired(t *testing.T) {
+	now := time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+	db := &blockRepo{expired: []data.BlockedIPDetails{
+		{IP: "192.0.2.1", UnblockTime: now.Add(-time.Minute).Unix()},
+		{IP: "192.0.2.2", UnblockTime: now.Unix()},
+		{IP: "192.0.2.3", UnblockTime: now.Add(time.Minute).Unix()}, // not yet
+		{IP: "192.0.2.4"}, // permanent
+		{IP: "2001:db8::5", UnblockTime: now.Add(-time.Hour).Unix()},
+	}}
+
+	dry := NewDryRunEnforcer()
+	for _, b := range db.expired {
+		dry.Block(b.IP)
+	}
+	enforcer := stuckEnforcer{DryRunEnforcer: dry, stuck: map[string]bool{"2001:db8::5": true}}
+
+	wm, _ := testManager(time.Minute)
+	wm.Add(testAlert("192.0.2.1", 0, testStart))
+	wm.Add(testAlert("192.0.2.3", 0, testStart))
+
+	s := NewExpiryScheduler(db, enforcer, wm, time.Minute)
+	var notified []string
+	s.OnUnblock = func(ip string) { notified = append(notified, ip) }
+
+	lifted, err := s.LiftExpired(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lifted != 2 {
+		t.Errorf("lifted %d blocks, want 2", lifted)
+	}
+
+	want := []string{"192.0.2.1", "192.0.2.2"}
+	if !reflect.DeepEqual(db.unblocked, want) {
+		t.Errorf("unblocked in database %q, want %q", db.unblocked, want)
+	}
+	if !reflect.DeepEqual(notified, want) {
+		t.Errorf("OnUnblock called for %q, want %q", notified, want)
+	}
+	// The stuck address stays blocked in the database and is retried next pass
+	list, _ := dry.List()
+	if want := []string{"192.0.2.3", "192.0.2.4", "2001:db8::5"}; !reflect.DeepEqual(list, want) {
+		t.Errorf("still blocked in firewall %q, want %q", list, want)
+	}
+	if n := wm.Len(); n != 1 {
+		t.Errorf("%d windows left, want only the one of 192.0.2.3", n)
+	}
+}
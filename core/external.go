@@ -0,0 +1,66 @@
+package suricata
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Accepted timestamps of submitted alerts, relative to the time of receipt.
+// Future ones would move event time ahead, old ones only fill the database.
+const (
+	externalMaxAhead = 5 * time.Minute
+	externalMaxAge   = 24 * time.Hour
+)
+
+// Validating an alert submitted by another tool (honeypot, WAF, script) and
+// filling in what the readers normally set; all problems are reported at once.
+// Alerts without a timestamp get now.
+func PrepareExternalAlert(a *Alert, source string, now time.Time) error {
+	var errs []error
+
+	switch {
+	case a.Alert.SignatureID <= 0:
+		errs = append(errs, fmt.Errorf("alert.signature_id must be positive"))
+	case IsFirefighterSID(a.Alert.SignatureID):
+		// Scoring rules for these SIDs assume Firefighter's own decoders raised them
+		errs = append(errs, fmt.Errorf("alert.signature_id %d is reserved (%d-%d are Firefighter's own alerts)",
+			a.Alert.SignatureID, FirefighterSIDMin, FirefighterSIDMax))
+	}
+	// Only severities with severity_points make sense
+	switch {
+	case a.Alert.Severity == 0:
+		a.Alert.Severity = 3
+	case a.Alert.Severity < 0 || a.Alert.Severity > 3:
+		errs = append(errs, fmt.Errorf("alert.severity must be between 1 and 3, got %d", a.Alert.Severity))
+	}
+	if a.SrcPort < 0 || a.SrcPort > 65535 {
+		errs = append(errs, fmt.Errorf("src_port out of range: %d", a.SrcPort))
+	}
+	if a.DstPort < 0 || a.DstPort > 65535 {
+		errs = append(errs, fmt.Errorf("dest_port out of range: %d", a.DstPort))
+	}
+
+	if a.Timestamp == "" {
+		a.ParsedTime = now
+	} else if t, err := parseEveTimestamp(a.Timestamp); err != nil {
+		errs = append(errs, fmt.Errorf("invalid timestamp %q", a.Timestamp))
+	} else if t.After(now.Add(externalMaxAhead)) || t.Before(now.Add(-externalMaxAge)) {
+		errs = append(errs, fmt.Errorf("timestamp %q outside %s before to %s after the time of receipt",
+			a.Timestamp, externalMaxAge, externalMaxAhead))
+	} else {
+		a.ParsedTime = t
+	}
+
+	if err := normalizeAlert(a); err != nil {
+		errs = append(errs, err)
+	}
+
+	if a.Alert.Signature == "" {
+		a.Alert.Signature = fmt.Sprintf("%s alert %d", source, a.Alert.SignatureID)
+	}
+	a.EventType = EventAlert
+	a.Source = source
+
+	return errors.Join(errs...)
+}
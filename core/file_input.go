@@ -0,0 +1,267 @@
+package suricata
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"firefighter/data"
+)
+
+// How often the tailer saves its position while alerts keep coming
+const checkpointInterval = time.Second
+
+// FileTailer follows a line-oriented IDS log (eve.json, Snort alert_json)
+// the way `tail -F` does and feeds decoded alerts into the same channel as
+// the socket input. Its position (inode + byte offset of the last complete
+// line handed out) is saved in the database under CheckpointKey, so a
+// restart resumes exactly there, including the rest of a file logrotate
+// renamed while Firefighter was down.
+type FileTailer struct {
+	Path string
+	DB   data.Repository
+	// Poll interval at end of file
+	Poll time.Duration
+	// Without a checkpoint read the existing file from the start instead of its end
+	FromStart bool
+	// Turns each line into an alert
+	Decoder LineDecoder
+	// Checkpoint row name, "<kind>:<path>"
+	CheckpointKey string
+
+	file    *os.File
+	reader  *bufio.Reader
+	inode   uint64
+	offset  int64
+	pending []byte
+
+	savedOffset int64
+	savedInode  uint64
+	savedAt     time.Time
+}
+
+func NewFileTailer(path string, db data.Repository, decoder LineDecoder) *FileTailer {
+	return &FileTailer{
+		Path:          path,
+		DB:            db,
+		Poll:          250 * time.Millisecond,
+		Decoder:       decoder,
+		CheckpointKey: "file:" + path,
+	}
+}
+
+func (t *FileTailer) Name() string {
+	return t.CheckpointKey
+}
+
+// Following the file until stop is closed; the position is saved on the way out
+func (t *FileTailer) Run(out chan<- Alert, stop <-chan struct{}) error {
+	if err := t.resume(out, stop); err != nil {
+		return err
+	}
+	defer func() {
+		t.saveCheckpoint(true)
+		if t.file != nil {
+			t.file.Close()
+		}
+	}()
+
+	for {
+		n, err := t.readLines(out, stop)
+		if err != nil {
+			return err
+		}
+		t.saveCheckpoint(false)
+		if n > 0 {
+			continue
+		}
+
+		// End of file: wait, then look for rotation or truncation
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(t.Poll):
+		}
+		if err := t.checkRotation(out, stop); err != nil {
+			return err
+		}
+	}
+}
+
+// Opening the file at the checkpoint; a file rotated away since then is finished first
+func (t *FileTailer) resume(out chan<- Alert, stop <-chan struct{}) error {
+	cp, err := t.DB.GetCheckpoint(context.Background(), t.CheckpointKey)
+	if err != nil {
+		return fmt.Errorf("error during checkpoint load: %v", err)
+	}
+	t.savedInode, t.savedOffset = cp.Inode, cp.Offset
+
+	if cp.Inode != 0 {
+		if rotated := t.findRotated(cp.Inode); rotated != "" {
+			slog.Info("Finishing rotated input file", "path", rotated, "offset", cp.Offset)
+			if err := t.open(rotated, cp.Offset); err != nil {
+				return err
+			}
+			if _, err := t.readLines(out, stop); err != nil {
+				return err
+			}
+			t.file.Close()
+			t.file = nil
+			cp.Offset = 0
+		}
+	}
+
+	for {
+		fi, err := os.Stat(t.Path)
+		if err == nil {
+			offset := int64(0)
+			switch {
+			case fileInode(fi) == cp.Inode && fi.Size() >= cp.Offset:
+				offset = cp.Offset
+			case fileInode(fi) == cp.Inode:
+				slog.Warn("Input file truncated since last run, reading from start", "path", t.Path)
+			case cp.Inode == 0 && !t.FromStart:
+				offset = fi.Size()
+			}
+			return t.open(t.Path, offset)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error during input file stat: %v", err)
+		}
+
+		// Suricata has not created the file yet
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(t.Poll):
+		}
+	}
+}
+
+// Looking for the renamed file (eve.json.1 and the like) that still has the given inode
+func (t *FileTailer) findRotated(inode uint64) string {
+	if fi, err := os.Stat(t.Path); err == nil && fileInode(fi) == inode {
+		return ""
+	}
+	matches, _ := filepath.Glob(t.Path + ".*")
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fileInode(fi) == inode {
+			return m
+		}
+	}
+	return ""
+}
+
+func (t *FileTailer) open(path string, offset int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error during input file open: %v", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error during input file stat: %v", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return fmt.Errorf("error during input file seek: %v", err)
+	}
+
+	t.file = f
+	t.reader = bufio.NewReaderSize(f, 64*1024)
+	t.inode = fileInode(fi)
+	t.offset = offset
+	t.pending = t.pending[:0]
+	slog.Info("Tailing input file", "path", path, "offset", offset)
+	return nil
+}
+
+// Reading complete lines up to EOF, returns how many lines were consumed
+func (t *FileTailer) readLines(out chan<- Alert, stop <-chan struct{}) (int, error) {
+	if t.file == nil {
+		return 0, nil
+	}
+
+	n := 0
+	for {
+		chunk, err := t.reader.ReadBytes('\n')
+		t.pending = append(t.pending, chunk...)
+		if errors.Is(err, io.EOF) {
+			// Partial line stays pending until Suricata finishes writing it
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("error during input file read: %v", err)
+		}
+
+		line := bytes.TrimSpace(t.pending)
+		next := t.offset + int64(len(t.pending))
+		t.pending = t.pending[:0]
+		n++
+
+		if len(line) > 0 {
+			if alert, ok := t.Decoder.Decode(line); ok {
+				select {
+				case out <- alert:
+				case <-stop:
+					return n, nil
+				}
+			}
+		}
+		t.offset = next
+
+		if time.Since(t.savedAt) >= checkpointInterval {
+			t.saveCheckpoint(false)
+		}
+	}
+}
+
+// Handling logrotate: rename (new inode at Path) and copytruncate (file shrank)
+func (t *FileTailer) checkRotation(out chan<- Alert, stop <-chan struct{}) error {
+	fi, err := os.Stat(t.Path)
+	if err != nil {
+		// Renamed and not recreated yet
+		return nil
+	}
+
+	if fileInode(fi) != t.inode {
+		// Lines written to the old file after our last read
+		if _, err := t.readLines(out, stop); err != nil {
+			return err
+		}
+		slog.Info("Input file rotated", "path", t.Path)
+		t.file.Close()
+		return t.open(t.Path, 0)
+	}
+
+	if current, err := t.file.Stat(); err == nil && current.Size() < t.offset {
+		slog.Warn("Input file truncated, reading from start", "path", t.Path)
+		t.file.Close()
+		return t.open(t.Path, 0)
+	}
+	return nil
+}
+
+func (t *FileTailer) saveCheckpoint(force bool) {
+	if t.file == nil {
+		return
+	}
+	if !force && t.offset == t.savedOffset && t.inode == t.savedInode {
+		return
+	}
+
+	// Also saved on the way out after stop, so not tied to it
+	cp := data.Checkpoint{Name: t.CheckpointKey, Inode: t.inode, Offset: t.offset}
+	if err := t.DB.SaveCheckpoint(context.Background(), cp); err != nil {
+		slog.Error("Failed to save input file checkpoint", "path", t.Path, "error", err)
+		return
+	}
+	t.savedOffset, t.savedInode, t.savedAt = t.offset, t.inode, time.Now()
+}
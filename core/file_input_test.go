@@ -0,0 +1,242 @@
+//go:build unix
+
+package suricata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"firefighter/data"
+)
+
+// Checkpoints kept in memory
+type checkpointRepo struct {
+	data.Repository
+	mu  sync.Mutex
+	cps map[string]data.Checkpoint
+}
+
+func (r *checkpointRepo) GetCheckpoint(_ context.Context, name string) (data.Checkpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp, ok := r.cps[name]
+	if !ok {
+		cp = data.Checkpoint{Name: name}
+	}
+	return cp, nil
+}
+
+func (r *checkpointRepo) SaveCheckpoint(_ context.Context, cp data.Checkpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cps == nil {
+		r.cps = make(map[string]data.Checkpoint)
+	}
+	r.cps[cp.Name] = cp
+	return nil
+}
+
+// Every line becomes an alert carrying the line as its signature
+type lineDecoder struct{}
+
+func (lineDecoder) Decode(line []byte) (Alert, bool) {
+	var a Alert
+	a.Alert.Signature = string(line)
+	return a, true
+}
+
+// A running tailer and the alerts it produced
+type tailRun struct {
+	t    *testing.T
+	out  chan Alert
+	stop chan struct{}
+	done chan error
+}
+
+func startTailer(t *testing.T, path string, db data.Repository, fromStart bool) *tailRun {
+	t.Helper()
+	ft := NewFileTailer(path, db, lineDecoder{})
+	ft.Poll = 5 * time.Millisecond
+	ft.FromStart = fromStart
+
+	r := &tailRun{t: t, out: make(chan Alert), stop: make(chan struct{}), done: make(chan error, 1)}
+	go func() { r.done <- ft.Run(r.out, r.stop) }()
+	return r
+}
+
+// Waiting for exactly the given lines, in order
+func (r *tailRun) expect(lines ...string) {
+	r.t.Helper()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(lines) {
+		select {
+		case a := <-r.out:
+			got = append(got, a.Alert.Signature)
+		case <-timeout:
+			r.t.Fatalf("got lines %q, want %q", got, lines)
+		}
+	}
+	if !reflect.DeepEqual(got, lines) {
+		r.t.Fatalf("got lines %q, want %q", got, lines)
+	}
+}
+
+// Nothing else arrives, then the tailer is stopped
+func (r *tailRun) finish() {
+	r.t.Helper()
+	select {
+	case a := <-r.out:
+		r.t.Fatalf("unexpected line %q", a.Alert.Signature)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(r.stop)
+	if err := <-r.done; err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path, text string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileTailer(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, path string, db *checkpointRepo)
+	}{
+		{"starts at end without checkpoint", func(t *testing.T, path string, db *checkpointRepo) {
+			appendFile(t, path, "old\n")
+			r := startTailer(t, path, db, false)
+			time.Sleep(20 * time.Millisecond)
+			appendFile(t, path, "new\n")
+			r.expect("new")
+			r.finish()
+		}},
+		{"partial line waits for its end", func(t *testing.T, path string, db *checkpointRepo) {
+			appendFile(t, path, "a\nb")
+			r := startTailer(t, path, db, true)
+			r.expect("a")
+			appendFile(t, path, "c\n")
+			r.expect("bc")
+			r.finish()
+		}},
+		{"rename rotation", func(t *testing.T, path string, db *checkpointRepo) {
+			appendFile(t, path, "a\n")
+			r := startTailer(t, path, db, true)
+			r.expect("a")
+
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			// Written by Suricata before it reopened its log
+			appendFile(t, path+".1", "b\n")
+			appendFile(t, path, "c\n")
+			r.expect("b", "c")
+			r.finish()
+		}},
+		{"copytruncate", func(t *testing.T, path string, db *checkpointRepo) {
+			appendFile(t, path, "first line\nsecond line\n")
+			r := startTailer(t, path, db, true)
+			r.expect("first line", "second line")
+
+			if err := os.Truncate(path, 0); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			appendFile(t, path, "x\n")
+			r.expect("x")
+			r.finish()
+		}},
+		{"resume from checkpoint", func(t *testing.T, path string, db *checkpointRepo) {
+			appendFile(t, path, "a\nb\n")
+			r := startTailer(t, path, db, true)
+			r.expect("a", "b")
+			r.finish()
+
+			appendFile(t, path, "c\n")
+			r = startTailer(t, path, db, true)
+			r.expect("c")
+			r.finish()
+		}},
+		{"resume finishes file rotated while down", func(t *testing.T, path string, db *checkpointRepo) {
+			appendFile(t, path, "a\n")
+			r := startTailer(t, path, db, false)
+			time.Sleep(20 * time.Millisecond)
+			appendFile(t, path, "b\n")
+			r.expect("b")
+			r.finish()
+
+			appendFile(t, path, "c\n")
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			appendFile(t, path, "d\n")
+			r = startTailer(t, path, db, false)
+			r.expect("c", "d")
+			r.finish()
+		}},
+		{"resume after truncation while down", func(t *testing.T, path string, db *checkpointRepo) {
+			appendFile(t, path, "long line one\nlong line two\n")
+			r := startTailer(t, path, db, true)
+			r.expect("long line one", "long line two")
+			r.finish()
+
+			if err := os.Truncate(path, 0); err != nil {
+				t.Fatal(err)
+			}
+			appendFile(t, path, "y\n")
+			r = startTailer(t, path, db, true)
+			r.expect("y")
+			r.finish()
+		}},
+		{"waits for the file to appear", func(t *testing.T, path string, db *checkpointRepo) {
+			r := startTailer(t, path, db, true)
+			time.Sleep(20 * time.Millisecond)
+			appendFile(t, path, "a\n")
+			r.expect("a")
+			r.finish()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "eve.json")
+			tt.run(t, path, &checkpointRepo{})
+		})
+	}
+}
+
+func TestFileTailerCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eve.json")
+	db := &checkpointRepo{}
+	appendFile(t, path, "a\nbb\nccc")
+
+	r := startTailer(t, path, db, true)
+	r.expect("a", "bb")
+	r.finish()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, _ := db.GetCheckpoint(context.Background(), "file:"+path)
+	// The unfinished "ccc" is not covered by the checkpoint
+	want := data.Checkpoint{Name: "file:" + path, Inode: fileInode(fi), Offset: 5}
+	if cp != want {
+		t.Errorf("checkpoint %+v, want %+v", cp, want)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package suricata
+
+import "os"
+
+// No inodes here: rotation is only detected through truncation
+func fileInode(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package suricata
+
+import (
+	"os"
+	"syscall"
+)
+
+// Inode of a file, used to tell a rotated eve.json from the current one
+func fileInode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
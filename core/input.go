@@ -0,0 +1,115 @@
+package suricata
+
+import (
+	"fmt"
+	"sort"
+
+	"firefighter/data"
+)
+
+// Input produces normalized alerts until stop is closed
+type Input interface {
+	Name() string
+	Run(out chan<- Alert, stop <-chan struct{}) error
+}
+
+// Turns one line of an IDS log into an Alert, ok=false for lines to skip
+type LineDecoder interface {
+	Decode(line []byte) (Alert, bool)
+}
+
+// One entry of the `inputs` config section
+type InputConfig struct {
+	Type string `yaml:"type" json:"type"`
+	Path string `yaml:"path" json:"path"`
+	// File inputs: first start without a checkpoint reads the whole file
+	FromStart bool `yaml:"from_start" json:"from_start"`
+	// auth_log / nginx_access: port the service listens on (default 22 / 80)
+	Port int `yaml:"port" json:"port,omitempty"`
+}
+
+// Shared services and settings an adapter may need
+type InputEnv struct {
+	DB        data.Repository
+	Events    *EventDispatcher
+	AccessLog AccessLogRules
+}
+
+type InputFactory func(cfg InputConfig, env InputEnv) (Input, error)
+
+// Registered adapter types
+const (
+	InputSuricataSocket = "suricata_socket"
+	InputSuricataFile   = "suricata_file"
+	InputSnortFile      = "snort_file"
+	InputAuthLog        = "auth_log"
+	InputNginxAccess    = "nginx_access"
+)
+
+var inputFactories = map[string]InputFactory{}
+
+// Making an adapter selectable as `type` in the inputs config
+func RegisterInput(kind string, factory InputFactory) {
+	if _, exists := inputFactories[kind]; exists {
+		panic("input type registered twice: " + kind)
+	}
+	inputFactories[kind] = factory
+}
+
+func InputTypes() []string {
+	kinds := make([]string, 0, len(inputFactories))
+	for kind := range inputFactories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func NewInput(cfg InputConfig, env InputEnv) (Input, error) {
+	factory, ok := inputFactories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown input type %q, expected one of %v", cfg.Type, InputTypes())
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("input %s: path must not be empty", cfg.Type)
+	}
+	return factory(cfg, env)
+}
+
+func init() {
+	RegisterInput(InputSuricataSocket, func(cfg InputConfig, env InputEnv) (Input, error) {
+		return &SocketInput{Path: cfg.Path, Decoder: env.Events}, nil
+	})
+
+	RegisterInput(InputSuricataFile, func(cfg InputConfig, env InputEnv) (Input, error) {
+		t := NewFileTailer(cfg.Path, env.DB, env.Events)
+		t.FromStart = cfg.FromStart
+		t.CheckpointKey = "eve_file:" + cfg.Path
+		return t, nil
+	})
+
+	RegisterInput(InputSnortFile, func(cfg InputConfig, env InputEnv) (Input, error) {
+		t := NewFileTailer(cfg.Path, env.DB, SnortDecoder{})
+		t.FromStart = cfg.FromStart
+		t.CheckpointKey = "snort_file:" + cfg.Path
+		return t, nil
+	})
+
+	RegisterInput(InputAuthLog, func(cfg InputConfig, env InputEnv) (Input, error) {
+		t := NewFileTailer(cfg.Path, env.DB, AuthLogDecoder{Port: cfg.Port})
+		t.FromStart = cfg.FromStart
+		t.CheckpointKey = "auth_log:" + cfg.Path
+		return t, nil
+	})
+
+	RegisterInput(InputNginxAccess, func(cfg InputConfig, env InputEnv) (Input, error) {
+		decoder, err := NewAccessLogDecoder(env.AccessLog, cfg.Port)
+		if err != nil {
+			return nil, fmt.Errorf("access_log rules: %w", err)
+		}
+		t := NewFileTailer(cfg.Path, env.DB, decoder)
+		t.FromStart = cfg.FromStart
+		t.CheckpointKey = "nginx_access:" + cfg.Path
+		return t, nil
+	})
+}
@@ -0,0 +1,35 @@
+package suricata
+
+import "sync/atomic"
+
+const (
+	ModeEnforce = "enforce"
+	ModeMonitor = "monitor"
+)
+
+// EnforcementMode is the global switch between blocking and only reporting
+// what would be blocked; it can be flipped at runtime from the API
+type EnforcementMode struct {
+	monitor atomic.Bool
+}
+
+func NewEnforcementMode(mode string) *EnforcementMode {
+	m := &EnforcementMode{}
+	m.monitor.Store(mode == ModeMonitor)
+	return m
+}
+
+func (m *EnforcementMode) Monitor() bool {
+	return m.monitor.Load()
+}
+
+func (m *EnforcementMode) Set(mode string) {
+	m.monitor.Store(mode == ModeMonitor)
+}
+
+func (m *EnforcementMode) String() string {
+	if m.Monitor() {
+		return ModeMonitor
+	}
+	return ModeEnforce
+}
@@ -0,0 +1,89 @@
+package suricata
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Policy is everything detection can change at runtime without a restart.
+// A loaded Policy is never mutated; reloads build a new one and swap the pointer.
+type Policy struct {
+	Scoring  ScoringModel   `json:"scoring"`
+	Suppress []SuppressRule `json:"suppress"`
+	// Decisions are only reported (would_block), never enforced
+	Monitor  bool      `json:"monitor"`
+	LoadedAt time.Time `json:"loaded_at"`
+
+	suppressPrefixes []netip.Prefix
+}
+
+// SuppressRule drops matching alerts before scoring; empty fields match anything
+type SuppressRule struct {
+	SID      int    `json:"sid,omitempty" yaml:"sid"`
+	Source   string `json:"source,omitempty" yaml:"source"` // address or CIDR
+	Category string `json:"category,omitempty" yaml:"category"`
+}
+
+// Validating the parts and precomputing lookups; all problems are reported at once
+func NewPolicy(scoring ScoringModel, suppress []SuppressRule, monitor bool) (*Policy, error) {
+	var errs []error
+	if err := scoring.Validate(); err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				errs = append(errs, fmt.Errorf("scoring.%w", e))
+			}
+		} else {
+			errs = append(errs, fmt.Errorf("scoring.%w", err))
+		}
+	}
+
+	p := &Policy{
+		Scoring:          scoring,
+		Suppress:         suppress,
+		Monitor:          monitor,
+		LoadedAt:         time.Now(),
+		suppressPrefixes: make([]netip.Prefix, len(suppress)),
+	}
+
+	for i, rule := range suppress {
+		if rule.SID == 0 && rule.Source == "" && rule.Category == "" {
+			errs = append(errs, fmt.Errorf("suppress[%d]: rule matches every alert", i))
+		}
+		if rule.Source != "" {
+			prefix, err := parseTarget(rule.Source)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("suppress[%d]: %w", i, err))
+				continue
+			}
+			p.suppressPrefixes[i] = prefix
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return p, nil
+}
+
+func DefaultPolicy() *Policy {
+	p, _ := NewPolicy(DefaultScoring(), nil, false)
+	return p
+}
+
+func (p *Policy) suppressed(a Alert) bool {
+	for i, rule := range p.Suppress {
+		if rule.SID != 0 && rule.SID != a.Alert.SignatureID {
+			continue
+		}
+		if rule.Category != "" && rule.Category != a.Alert.Category {
+			continue
+		}
+		if rule.Source != "" && !p.suppressPrefixes[i].Contains(alertAddr(a)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
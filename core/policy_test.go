@@ -0,0 +1,136 @@
+package suricata
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewPolicy(t *testing.T) {
+	bad := DefaultScoring()
+	bad.Threshold = 0
+	bad.PortWeight = -1
+
+	tests := []struct {
+		name     string
+		scoring  ScoringModel
+		suppress []SuppressRule
+		errs     []string
+	}{
+		{name: "defaults", scoring: DefaultScoring()},
+		{name: "valid rules", scoring: DefaultScoring(), suppress: []SuppressRule{
+			{SID: 2010935}, {Source: "10.0.0.0/8"}, {Source: "2001:db8::1", Category: "Misc activity"},
+		}},
+		{name: "scoring errors are prefixed", scoring: bad,
+			errs: []string{"scoring.threshold must be positive", "scoring.port_weight must not be negative"}},
+		{name: "rule matching everything", scoring: DefaultScoring(), suppress: []SuppressRule{{}},
+			errs: []string{"suppress[0]: rule matches every alert"}},
+		{name: "bad source", scoring: DefaultScoring(), suppress: []SuppressRule{{SID: 1}, {Source: "10.0.0.0/33"}},
+			errs: []string{"suppress[1]: invalid address or prefix"}},
+		{name: "everything at once", scoring: bad, suppress: []SuppressRule{{}, {Source: "lan"}},
+			errs: []string{"scoring.threshold", "scoring.port_weight", "suppress[0]", "suppress[1]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(tt.scoring, tt.suppress, false)
+			if len(tt.errs) == 0 {
+				if err != nil || p == nil {
+					t.Fatalf("NewPolicy() = %v, want a policy", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("NewPolicy() succeeded, want %q", tt.errs)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("NewPolicy() = %v, missing %q", err, want)
+				}
+			}
+			if n := strings.Count(err.Error(), "\n") + 1; n != len(tt.errs) {
+				t.Errorf("%d errors reported, want %d: %v", n, len(tt.errs), err)
+			}
+		})
+	}
+}
+
+func TestSuppressed(t *testing.T) {
+	p, err := NewPolicy(DefaultScoring(), []SuppressRule{
+		{SID: 2010935},
+		{Category: "Not Suspicious Traffic"},
+		{Source: "10.0.0.0/8"},
+		{Source: "2001:db8::/32", SID: 2200000},
+		{Source: "192.0.2.7", Category: "Misc activity"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert := func(src string, sid int, category string) Alert {
+		a := Alert{SrcIP: src, SrcAddr: netip.MustParseAddr(src)}
+		a.Alert.SignatureID, a.Alert.Category = sid, category
+		return a
+	}
+
+	tests := []struct {
+		name  string
+		alert Alert
+		want  bool
+	}{
+		{"SID rule", alert("198.51.100.1", 2010935, "Misc Attack"), true},
+		{"category rule", alert("198.51.100.1", 1, "Not Suspicious Traffic"), true},
+		{"source prefix", alert("10.20.30.40", 1, "Misc Attack"), true},
+		{"source and SID", alert("2001:db8::9", 2200000, "Misc Attack"), true},
+		{"source without the SID", alert("2001:db8::9", 2200001, "Misc Attack"), false},
+		{"SID from another source", alert("2001:db9::9", 2200000, "Misc Attack"), false},
+		{"source and category", alert("192.0.2.7", 5, "Misc activity"), true},
+		{"same category, other source", alert("192.0.2.8", 5, "Misc activity"), false},
+		{"no rule", alert("198.51.100.1", 1, "Misc Attack"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.suppressed(tt.alert); got != tt.want {
+				t.Errorf("suppressed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A reload that adds a suppress rule takes the alerts out of the scored aggregates
+func TestPolicySwapSuppresses(t *testing.T) {
+	wm, _ := testManager(10 * time.Minute)
+	var key string
+	for i := 0; i < 3; i++ {
+		a := testAlert("198.51.100.50", i, testStart)
+		a.Alert.SignatureID = 2010935
+		key = wm.Add(a)
+	}
+
+	count := func() int {
+		s := wm.shard(key)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w := s.windows[key]
+		w.usePolicy(wm.Policy())
+		return w.agg.count
+	}
+	if n := count(); n != 3 {
+		t.Fatalf("%d alerts scored before reload, want 3", n)
+	}
+
+	p, err := NewPolicy(DefaultScoring(), []SuppressRule{{SID: 2010935}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wm.SetPolicy(p)
+	if n := count(); n != 0 {
+		t.Errorf("%d alerts scored after reload, want 0", n)
+	}
+
+	wm.SetPolicy(DefaultPolicy())
+	if n := count(); n != 3 {
+		t.Errorf("%d alerts scored after the rule was removed, want 3", n)
+	}
+}
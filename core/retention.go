@@ -0,0 +1,92 @@
+package suricata
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"firefighter/data"
+)
+
+// Pause between purge batches so alert inserts get the write lock in between
+const purgeBatchPause = 50 * time.Millisecond
+
+// RetentionScheduler periodically rolls new alerts and blocks up into the
+// hourly/daily counts and purges raw rows past their retention in batches
+type RetentionScheduler struct {
+	DB data.Repository
+	// Purge target (see data.DbManager.Purge) → how long rows are kept, 0 = forever
+	Tables map[string]time.Duration
+	// Hourly rollups older than this are folded into daily ones
+	HourlyRollups time.Duration
+	BatchSize     int
+	Interval      time.Duration
+}
+
+// Running until stop is closed, first pass right away
+func (s *RetentionScheduler) Run(stop <-chan struct{}) {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.Pass(ctx, time.Now())
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// One rollup and purge pass; cancelling ctx ends it
+func (s *RetentionScheduler) Pass(ctx context.Context, now time.Time) {
+	var hourlyBefore int64
+	if s.HourlyRollups > 0 {
+		hourlyBefore = now.Add(-s.HourlyRollups).Unix()
+	}
+	// Purge only takes rows already counted, so rollups go first
+	if err := s.DB.Rollup(ctx, hourlyBefore); err != nil {
+		slog.Error("Rollup pass failed", "error", err)
+		return
+	}
+
+	for _, table := range slices.Sorted(maps.Keys(s.Tables)) {
+		if ctx.Err() != nil {
+			return
+		}
+		keep := s.Tables[table]
+		if keep <= 0 {
+			continue
+		}
+		before := now.Add(-keep).Unix()
+
+		var purged int64
+		for {
+			n, err := s.DB.Purge(ctx, table, before, s.BatchSize)
+			if err != nil {
+				slog.Error("Retention purge failed", "table", table, "error", err)
+				break
+			}
+			purged += n
+			if n < int64(s.BatchSize) {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				slog.Info("Retention purge interrupted", "table", table, "rows", purged)
+				return
+			case <-time.After(purgeBatchPause):
+			}
+		}
+		if purged > 0 {
+			slog.Info("Retention purge done", "table", table, "rows", purged, "before", time.Unix(before, 0))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package suricata
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ScoringModel holds every weight AnalyzeAlerts uses to turn a window of alerts into a score
+type ScoringModel struct {
+	Threshold int `json:"threshold" yaml:"threshold"`
+
+	// Points per alert by Suricata severity (1 = high)
+	SeverityPoints map[int]int `json:"severity_points" yaml:"severity_points"`
+
+	// Points per unique category, per unique dest port, per unique protocol and per unique SID
+	CategoryWeight int `json:"category_weight" yaml:"category_weight"`
+	PortWeight     int `json:"port_weight" yaml:"port_weight"`
+	ProtoWeight    int `json:"proto_weight" yaml:"proto_weight"`
+	SIDWeight      int `json:"sid_weight" yaml:"sid_weight"`
+
+	// Points per unique server name (HTTP Host or TLS SNI) the source alerted on,
+	// e.g. a scanner walking virtual hosts
+	HostWeight int `json:"host_weight" yaml:"host_weight"`
+
+	// Category-specific replacement for CategoryWeight
+	CategoryWeights map[string]int `json:"category_weights,omitempty" yaml:"category_weights"`
+
+	// Many flows from one source look like scanning: FlowWeight per flow once FlowMinimum is reached
+	FlowMinimum int `json:"flow_minimum" yaml:"flow_minimum"`
+	FlowWeight  int `json:"flow_weight" yaml:"flow_weight"`
+
+	// Per-SID overrides
+	SIDs map[int]SIDRule `json:"sids,omitempty" yaml:"sids"`
+}
+
+type SIDRule struct {
+	// Alert is dropped before scoring ("never count")
+	Ignore bool `json:"ignore,omitempty" yaml:"ignore"`
+	// Any alert with this SID blocks the source regardless of score
+	InstantBlock bool `json:"instant_block,omitempty" yaml:"instant_block"`
+	// Replaces severity points for this SID
+	Points *int `json:"points,omitempty" yaml:"points"`
+}
+
+// Weights used before the model became configurable
+func DefaultScoring() ScoringModel {
+	return ScoringModel{
+		Threshold:      30,
+		SeverityPoints: map[int]int{1: 10, 2: 5, 3: 2},
+		CategoryWeight: 5,
+		PortWeight:     3,
+		ProtoWeight:    4,
+		SIDWeight:      1,
+		FlowMinimum:    5,
+		FlowWeight:     4,
+	}
+}
+
+func (m ScoringModel) Validate() error {
+	var errs []error
+	if m.Threshold <= 0 {
+		errs = append(errs, fmt.Errorf("threshold must be positive, got %d", m.Threshold))
+	}
+	for sev, pts := range m.SeverityPoints {
+		if pts < 0 {
+			errs = append(errs, fmt.Errorf("severity_points[%d] must not be negative", sev))
+		}
+	}
+	for name, w := range map[string]int{
+		"category_weight": m.CategoryWeight,
+		"port_weight":     m.PortWeight,
+		"proto_weight":    m.ProtoWeight,
+		"sid_weight":      m.SIDWeight,
+		"host_weight":     m.HostWeight,
+		"flow_minimum":    m.FlowMinimum,
+		"flow_weight":     m.FlowWeight,
+	} {
+		if w < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+	for cat, w := range m.CategoryWeights {
+		if w < 0 {
+			errs = append(errs, fmt.Errorf("category_weights[%q] must not be negative", cat))
+		}
+	}
+	for sid, rule := range m.SIDs {
+		if rule.Ignore && rule.InstantBlock {
+			errs = append(errs, fmt.Errorf("sids[%d]: ignore and instant_block are exclusive", sid))
+		}
+		if rule.Points != nil && *rule.Points < 0 {
+			errs = append(errs, fmt.Errorf("sids[%d].points must not be negative", sid))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Points a single alert contributes, ok=false when its SID is ignored
+func (m ScoringModel) alertPoints(a Alert) (int, bool) {
+	if rule, ok := m.SIDs[a.Alert.SignatureID]; ok {
+		if rule.Ignore {
+			return 0, false
+		}
+		if rule.Points != nil {
+			return *rule.Points, true
+		}
+	}
+	return m.SeverityPoints[a.Alert.Severity], true
+}
+
+func (m ScoringModel) instantBlock(sid int) bool {
+	return m.SIDs[sid].InstantBlock
+}
+
+func (m ScoringModel) categoryWeight(category string) int {
+	if w, ok := m.CategoryWeights[category]; ok {
+		return w
+	}
+	return m.CategoryWeight
+}
@@ -0,0 +1,116 @@
+package suricata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScoringValidate(t *testing.T) {
+	points := func(n int) *int { return &n }
+
+	tests := []struct {
+		name   string
+		modify func(m *ScoringModel)
+		errs   []string // every one must be reported
+	}{
+		{name: "defaults", modify: func(m *ScoringModel) {}},
+		{name: "zero weights are allowed", modify: func(m *ScoringModel) {
+			m.CategoryWeight, m.PortWeight, m.FlowMinimum = 0, 0, 0
+			m.SIDs = map[int]SIDRule{1: {Points: points(0)}}
+		}},
+		{name: "threshold", modify: func(m *ScoringModel) { m.Threshold = 0 },
+			errs: []string{"threshold must be positive, got 0"}},
+		{name: "negative severity points", modify: func(m *ScoringModel) { m.SeverityPoints[2] = -1 },
+			errs: []string{"severity_points[2] must not be negative"}},
+		{name: "negative weights", modify: func(m *ScoringModel) {
+			m.PortWeight, m.HostWeight, m.FlowWeight = -1, -2, -3
+		}, errs: []string{"port_weight must not be negative", "host_weight must not be negative", "flow_weight must not be negative"}},
+		{name: "negative category weight", modify: func(m *ScoringModel) {
+			m.CategoryWeights = map[string]int{"Misc Attack": -5}
+		}, errs: []string{`category_weights["Misc Attack"] must not be negative`}},
+		{name: "bad SID rules", modify: func(m *ScoringModel) {
+			m.SIDs = map[int]SIDRule{
+				2010935: {Ignore: true, InstantBlock: true},
+				2010936: {Points: points(-1)},
+			}
+		}, errs: []string{"sids[2010935]: ignore and instant_block are exclusive", "sids[2010936].points must not be negative"}},
+		{name: "several at once", modify: func(m *ScoringModel) {
+			m.Threshold = -1
+			m.SIDWeight = -1
+		}, errs: []string{"threshold must be positive", "sid_weight must not be negative"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := DefaultScoring()
+			tt.modify(&m)
+			err := m.Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.errs)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, missing %q", err, want)
+				}
+			}
+			if n := strings.Count(err.Error(), "\n") + 1; n != len(tt.errs) {
+				t.Errorf("%d errors reported, want %d: %v", n, len(tt.errs), err)
+			}
+		})
+	}
+}
+
+func TestAlertPoints(t *testing.T) {
+	seven := 7
+	m := DefaultScoring()
+	m.SIDs = map[int]SIDRule{
+		100: {Ignore: true},
+		200: {Points: &seven},
+		300: {InstantBlock: true},
+	}
+	m.CategoryWeights = map[string]int{"Not Suspicious Traffic": 0}
+
+	alert := func(sid, severity int) Alert {
+		var a Alert
+		a.Alert.SignatureID, a.Alert.Severity = sid, severity
+		return a
+	}
+
+	tests := []struct {
+		name    string
+		alert   Alert
+		points  int
+		counted bool
+	}{
+		{"severity 1", alert(1, 1), 10, true},
+		{"severity 3", alert(1, 3), 2, true},
+		{"unknown severity", alert(1, 4), 0, true},
+		{"ignored SID", alert(100, 1), 0, false},
+		{"SID points replace severity", alert(200, 1), 7, true},
+		{"instant block SID keeps severity points", alert(300, 2), 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, counted := m.alertPoints(tt.alert)
+			if points != tt.points || counted != tt.counted {
+				t.Errorf("alertPoints() = %d, %v, want %d, %v", points, counted, tt.points, tt.counted)
+			}
+		})
+	}
+
+	if !m.instantBlock(300) || m.instantBlock(200) {
+		t.Error("instantBlock does not follow the SID rules")
+	}
+	if got := m.categoryWeight("Not Suspicious Traffic"); got != 0 {
+		t.Errorf("category override weight %d, want 0", got)
+	}
+	if got := m.categoryWeight("Misc Attack"); got != m.CategoryWeight {
+		t.Errorf("default category weight %d, want %d", got, m.CategoryWeight)
+	}
+}
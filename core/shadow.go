@@ -0,0 +1,92 @@
+package suricata
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"firefighter/data"
+)
+
+// ShadowPolicy is an alternative policy scored on the live alert stream with
+// its own windows. Its decisions are recorded in shadow_decisions, never
+// enforced; blocks it would have made are simulated so a blocked IP is not
+// decided again until the block would have ended.
+type ShadowPolicy struct {
+	Name    string
+	Windows *WindowManager
+	blocks  *SimulatedBlocks
+}
+
+// Windows get the live IPv6 grouping, lateness, skew and whitelist from live
+func NewShadowPolicy(name string, policy *Policy, window time.Duration, live *WindowManager, db data.Repository, escalation EscalationPolicy) *ShadowPolicy {
+	wm := NewWindowManager(window)
+	wm.SetPolicy(policy)
+	wm.IPv6Prefix = live.IPv6Prefix
+	wm.Lateness = live.Lateness
+	wm.MaxSkew = live.MaxSkew
+	wm.Whitelist = live.Whitelist
+	wm.Clock = live.Clock
+
+	return &ShadowPolicy{
+		Name:    name,
+		Windows: wm,
+		blocks:  NewSimulatedBlocks(db, wm.Clock, escalation),
+	}
+}
+
+// Shadows runs every shadow policy on each alert of the main loop
+type Shadows struct {
+	DB       data.Repository
+	Policies []*ShadowPolicy
+
+	lastSweep time.Time
+}
+
+func (s *Shadows) Names() []string {
+	names := make([]string, len(s.Policies))
+	for i, p := range s.Policies {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Scoring the alert under every shadow policy and recording their decisions
+func (s *Shadows) Evaluate(ctx context.Context, alert Alert) {
+	if len(s.Policies) == 0 {
+		return
+	}
+
+	// Shadow windows are not swept by the expiry scheduler
+	sweep := time.Since(s.lastSweep) >= time.Minute
+	if sweep {
+		s.lastSweep = time.Now()
+	}
+
+	for _, sp := range s.Policies {
+		if sweep {
+			sp.Windows.Sweep()
+			sp.blocks.Prune(sp.Windows.Clock.Now())
+		}
+
+		key := sp.Windows.Add(alert)
+		decision, ok := sp.Windows.AnalyzeIP(ctx, sp.blocks, key)
+		if !ok {
+			continue
+		}
+		sp.blocks.Block(decision.IP)
+
+		slog.Info("Shadow policy would block", "policy", sp.Name, "ip", decision.IP, "score", decision.Score, "reason", decision.Reason)
+		err := s.DB.AddShadowDecision(ctx, data.ShadowDecision{
+			Policy:    sp.Name,
+			IP:        decision.IP,
+			Score:     decision.Score,
+			Reason:    decision.Reason,
+			Details:   decision.Details,
+			Timestamp: unixOrZero(alert.ParsedTime),
+		})
+		if err != nil {
+			slog.Error("Failed to save shadow decision", "policy", sp.Name, "ip", decision.IP, "error", err)
+		}
+	}
+}
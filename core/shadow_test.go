@@ -0,0 +1,65 @@
+package suricata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"firefighter/data"
+)
+
+// Keeps shadow decisions; nothing else may be written
+type shadowRepo struct {
+	data.Repository
+	decisions []data.ShadowDecision
+}
+
+func (r *shadowRepo) AddShadowDecision(_ context.Context, d data.ShadowDecision) error {
+	r.decisions = append(r.decisions, d)
+	return nil
+}
+
+func TestShadowEvaluate(t *testing.T) {
+	live, clock := testManager(10 * time.Minute)
+	db := &shadowRepo{}
+	escalation := EscalationPolicy{Steps: []time.Duration{time.Hour}}
+
+	strict, err := NewPolicy(DefaultScoring(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lenientScoring := DefaultScoring()
+	lenientScoring.Threshold = 1000
+	lenient, err := NewPolicy(lenientScoring, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Shadows{DB: db, Policies: []*ShadowPolicy{
+		NewShadowPolicy("strict", strict, 10*time.Minute, live, db, escalation),
+		NewShadowPolicy("lenient", lenient, 10*time.Minute, live, db, escalation),
+	}}
+
+	// Sensor clock an hour behind the host: decisions carry event time
+	eventTime := testStart.Add(-time.Hour)
+	for i := range 6 {
+		clock.Advance(time.Second)
+		a := testAlert("203.0.113.9", i, eventTime.Add(time.Duration(i)*time.Second))
+		a.Alert.Severity = 1
+		s.Evaluate(context.Background(), a)
+	}
+
+	if len(db.decisions) != 1 {
+		t.Fatalf("%d shadow decisions, want one (later alerts hit the simulated block): %+v", len(db.decisions), db.decisions)
+	}
+	d := db.decisions[0]
+	if d.Policy != "strict" || d.IP != "203.0.113.9" || d.Score < 30 {
+		t.Errorf("decision %+v", d)
+	}
+	// Second alert crosses the threshold, the host clock is an hour later
+	if at, want := time.Unix(d.Timestamp, 0), eventTime.Add(time.Second); !at.Equal(want) {
+		t.Errorf("decision stamped %s, want event time %s", at.UTC(), want)
+	}
+	if n := live.Len(); n != 0 {
+		t.Errorf("live windows touched: %d", n)
+	}
+}
@@ -0,0 +1,38 @@
+package suricata
+
+// SIDs 9 900 000 - 9 999 999 are reserved for synthetic alerts Firefighter
+// raises itself from non-IDS sources, so they can be tuned in scoring.sids
+// like any Suricata rule without colliding with ET (2xxxxxx) or local rules.
+const (
+	FirefighterSIDMin = 9_900_000
+	FirefighterSIDMax = 9_999_999
+)
+
+// Auth log (sshd)
+const (
+	SIDSSHFailedPassword  = 9_900_001
+	SIDSSHInvalidUser     = 9_900_002
+	SIDSSHFailedPublickey = 9_900_003
+	SIDSSHMaxAuthTries    = 9_900_004
+	SIDSSHPreauthClose    = 9_900_005
+)
+
+// Web server access log
+const (
+	SIDHTTPScannerPath  = 9_900_101
+	SIDHTTPScannerAgent = 9_900_102
+	SIDHTTPAuthFailure  = 9_900_103
+	SIDHTTPNotFound     = 9_900_104
+)
+
+// Categories of synthetic alerts
+const (
+	CategorySSHBruteForce = "Firefighter SSH Brute Force"
+	CategoryHTTPAttack    = "Firefighter HTTP Attack"
+	CategoryHTTPErrors    = "Firefighter HTTP Error Burst"
+)
+
+// Whether the SID belongs to a Firefighter synthetic alert
+func IsFirefighterSID(sid int) bool {
+	return sid >= FirefighterSIDMin && sid <= FirefighterSIDMax
+}
@@ -5,31 +5,140 @@ import (
 	"time"
 )
 
+// Max alerts kept per window
+const windowCapacity = 200
+
 type SlidingWindow struct {
 	Duration time.Duration
 	Events   *list.List
+
+	// Running aggregates of Events under policy, kept in step by Add/eviction
+	policy *Policy
+	agg    windowAgg
+}
+
+// Reference-counted aggregates, so an evicted alert can be subtracted again
+type windowAgg struct {
+	count         int
+	severityScore int
+	categories    map[string]int
+	ports         map[int]int
+	protos        map[string]int
+	sids          map[int]int
+	flows         map[uint64]int
+	hosts         map[string]int
+	instant       map[int]int
+}
+
+func newWindowAgg() windowAgg {
+	return windowAgg{
+		categories: make(map[string]int),
+		ports:      make(map[int]int),
+		protos:     make(map[string]int),
+		sids:       make(map[int]int),
+		flows:      make(map[uint64]int),
+		hosts:      make(map[string]int),
+		instant:    make(map[int]int),
+	}
 }
 
 func NewSlidingWindow(duration time.Duration) *SlidingWindow {
 	return &SlidingWindow{
 		Duration: duration,
 		Events:   list.New(),
+		agg:      newWindowAgg(),
 	}
 }
 
-func (w *SlidingWindow) Add(alert Alert) {
-	w.Events.PushBack(alert)
+// Inserting alert in event-time order; now is the stream's event time, not
+// the wall clock, so replayed and delayed alerts are kept or evicted by ParsedTime
+func (w *SlidingWindow) Add(alert Alert, now time.Time) {
+	// Alerts arrive mostly in order, search for the slot from the back
+	e := w.Events.Back()
+	for e != nil && e.Value.(Alert).ParsedTime.After(alert.ParsedTime) {
+		e = e.Prev()
+	}
+	if e == nil {
+		w.Events.PushFront(alert)
+	} else {
+		w.Events.InsertAfter(alert, e)
+	}
+	w.account(alert, 1)
 
-	if w.Events.Len() > 200 {
-		w.Events.Remove(w.Events.Front())
+	if w.Events.Len() > windowCapacity {
+		w.removeFront()
 	}
-	cutoff := time.Now().Add(-w.Duration)
+	w.evict(now)
+}
+
+// Dropping events older than Duration relative to now (event time)
+func (w *SlidingWindow) evict(now time.Time) {
+	cutoff := now.Add(-w.Duration)
 
 	for w.Events.Len() > 0 {
 		front := w.Events.Front().Value.(Alert)
 		if front.ParsedTime.After(cutoff) {
 			break
 		}
-		w.Events.Remove(w.Events.Front())
+		w.removeFront()
+	}
+}
+
+func (w *SlidingWindow) removeFront() {
+	alert := w.Events.Remove(w.Events.Front()).(Alert)
+	w.account(alert, -1)
+}
+
+// Emptying the window (after a block decision)
+func (w *SlidingWindow) Reset() {
+	w.Events.Init()
+	w.agg = newWindowAgg()
+}
+
+// Adding (delta=1) or removing (delta=-1) one alert's contribution
+func (w *SlidingWindow) account(a Alert, delta int) {
+	if w.policy == nil || w.policy.suppressed(a) {
+		return
+	}
+	points, counted := w.policy.Scoring.alertPoints(a)
+	if !counted {
+		return
+	}
+
+	agg := &w.agg
+	agg.count += delta
+	agg.severityScore += points * delta
+	addCount(agg.categories, a.Alert.Category, delta)
+	addCount(agg.ports, a.DstPort, delta)
+	addCount(agg.protos, a.Proto, delta)
+	addCount(agg.sids, a.Alert.SignatureID, delta)
+	if a.FlowID != 0 {
+		addCount(agg.flows, a.FlowID, delta)
+	}
+	if host := a.ServerName(); host != "" {
+		addCount(agg.hosts, host, delta)
+	}
+	if w.policy.Scoring.instantBlock(a.Alert.SignatureID) {
+		addCount(agg.instant, a.Alert.SignatureID, delta)
+	}
+}
+
+func addCount[K comparable](m map[K]int, key K, delta int) {
+	if m[key]+delta <= 0 {
+		delete(m, key)
+		return
+	}
+	m[key] += delta
+}
+
+// Switching the policy aggregates are kept for, rebuilding them from Events on change
+func (w *SlidingWindow) usePolicy(p *Policy) {
+	if w.policy == p {
+		return
+	}
+	w.policy = p
+	w.agg = newWindowAgg()
+	for e := w.Events.Front(); e != nil; e = e.Next() {
+		w.account(e.Value.(Alert), 1)
 	}
 }
@@ -0,0 +1,211 @@
+package suricata
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snort 3 alert_json record. Default fields are timestamp, pkt_num, proto,
+// pkt_gen, pkt_len, dir, src_ap, dst_ap, rule and action; the rest only
+// appear when listed in alert_json.fields (recommended: add msg, class,
+// priority, gid, sid, rev, seconds, src_port and dst_port).
+type snortAlert struct {
+	Timestamp string `json:"timestamp"`
+	Seconds   int64  `json:"seconds"`
+	Proto     string `json:"proto"`
+	SrcAP     string `json:"src_ap"`
+	DstAP     string `json:"dst_ap"`
+	SrcAddr   string `json:"src_addr"`
+	SrcPort   *int   `json:"src_port"`
+	DstAddr   string `json:"dst_addr"`
+	DstPort   *int   `json:"dst_port"`
+	Rule      string `json:"rule"` // gid:sid:rev
+	GID       int    `json:"gid"`
+	SID       int    `json:"sid"`
+	Rev       int    `json:"rev"`
+	Msg       string `json:"msg"`
+	Class     string `json:"class"`
+	Priority  int    `json:"priority"`
+	Action    string `json:"action"`
+	Service   string `json:"service"`
+}
+
+// Builtin/preprocessor rules (gid != 1) get SignatureID gid*snortGIDBase + sid,
+// so they neither collide with text rules nor with each other
+const snortGIDBase = 10_000_000
+
+// SnortDecoder maps Snort 3 alert_json lines onto Alert: gid/sid/rev become
+// SignatureID, priority becomes Severity and classtype becomes Category
+// (using the classification.config descriptions Suricata reports too)
+type SnortDecoder struct{}
+
+func (SnortDecoder) Decode(line []byte) (Alert, bool) {
+	var s snortAlert
+	if err := json.Unmarshal(line, &s); err != nil {
+		log.Printf("[Snort] Błąd parsowania JSON: %v\nJSON: %s", err, line)
+		return Alert{}, false
+	}
+
+	gid, sid, rev := s.GID, s.SID, s.Rev
+	if s.Rule != "" {
+		parts := strings.Split(s.Rule, ":")
+		if len(parts) == 3 {
+			gid, _ = strconv.Atoi(parts[0])
+			sid, _ = strconv.Atoi(parts[1])
+			rev, _ = strconv.Atoi(parts[2])
+		}
+	}
+	if gid == 0 {
+		gid = 1
+	}
+	if sid == 0 {
+		return Alert{}, false
+	}
+
+	alert := Alert{
+		Timestamp: s.Timestamp,
+		EventType: EventAlert,
+		Proto:     strings.ToUpper(s.Proto),
+		AppProto:  s.Service,
+		Source:    SourceSnort,
+	}
+	alert.Alert.SignatureID = sid
+	if gid != 1 {
+		alert.Alert.SignatureID = gid*snortGIDBase + sid
+	}
+	alert.Alert.Signature = s.Msg
+	if alert.Alert.Signature == "" {
+		alert.Alert.Signature = fmt.Sprintf("Snort rule %d:%d:%d", gid, sid, rev)
+	}
+	alert.Alert.Category = snortCategory(s.Class)
+	alert.Alert.Severity = snortSeverity(s.Priority)
+
+	alert.SrcIP, alert.SrcPort = splitAddrPort(s.SrcAP)
+	alert.DstIP, alert.DstPort = splitAddrPort(s.DstAP)
+	if s.SrcAddr != "" {
+		alert.SrcIP = s.SrcAddr
+	}
+	if s.SrcPort != nil {
+		alert.SrcPort = *s.SrcPort
+	}
+	if s.DstAddr != "" {
+		alert.DstIP = s.DstAddr
+	}
+	if s.DstPort != nil {
+		alert.DstPort = *s.DstPort
+	}
+
+	if s.Seconds > 0 {
+		alert.ParsedTime = time.Unix(s.Seconds, 0)
+	} else {
+		alert.ParsedTime = parseSnortTime(s.Timestamp, time.Now())
+	}
+
+	if err := normalizeAlert(&alert); err != nil {
+		slog.Warn("Snort alert with invalid address skipped", "error", err)
+		return alert, false
+	}
+	return alert, true
+}
+
+// "1.2.3.4:80", "[2001:db8::1]:80" or a bare address for ICMP. Snort does not
+// bracket IPv6, so "2001:db8::1:2" is read as an address; only what does not
+// parse as one alone (e.g. all eight groups plus port) has its port split off.
+// Add src_port and dst_port to alert_json.fields for reliable IPv6 ports.
+func splitAddrPort(ap string) (string, int) {
+	ap = strings.TrimSpace(ap)
+	if _, err := netip.ParseAddr(ap); err == nil {
+		return ap, 0
+	}
+	i := strings.LastIndex(ap, ":")
+	if i < 0 {
+		return ap, 0
+	}
+	port, err := strconv.Atoi(ap[i+1:])
+	if err != nil {
+		return ap, 0
+	}
+	host := strings.Trim(ap[:i], "[]")
+	if _, err := netip.ParseAddr(host); err != nil {
+		return ap, 0
+	}
+	return host, port
+}
+
+// Snort prints "yy/mm/dd-HH:MM:SS.ffffff" with -y and "mm/dd-HH:MM:SS.ffffff" without
+func parseSnortTime(ts string, now time.Time) time.Time {
+	if t, err := time.ParseInLocation("06/01/02-15:04:05.999999", ts, time.Local); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation("01/02-15:04:05.999999", ts, time.Local); err == nil {
+		return withYear(t, now)
+	}
+	return now
+}
+
+// Priority 1 (high) .. 4 (very low) onto Suricata's 1..3
+func snortSeverity(priority int) int {
+	switch {
+	case priority <= 0:
+		return 3
+	case priority > 3:
+		return 3
+	default:
+		return priority
+	}
+}
+
+// classification.config shortnames → descriptions, as Suricata reports categories
+var snortClasses = map[string]string{
+	"not-suspicious":                 "Not Suspicious Traffic",
+	"unknown":                        "Unknown Traffic",
+	"bad-unknown":                    "Potentially Bad Traffic",
+	"attempted-recon":                "Attempted Information Leak",
+	"successful-recon-limited":       "Information Leak",
+	"successful-recon-largescale":    "Large Scale Information Leak",
+	"attempted-dos":                  "Attempted Denial of Service",
+	"successful-dos":                 "Denial of Service",
+	"attempted-user":                 "Attempted User Privilege Gain",
+	"unsuccessful-user":              "Unsuccessful User Privilege Gain",
+	"successful-user":                "Successful User Privilege Gain",
+	"attempted-admin":                "Attempted Administrator Privilege Gain",
+	"successful-admin":               "Successful Administrator Privilege Gain",
+	"rpc-portmap-decode":             "Decode of an RPC Query",
+	"shellcode-detect":               "Executable code was detected",
+	"string-detect":                  "A suspicious string was detected",
+	"suspicious-filename-detect":     "A suspicious filename was detected",
+	"suspicious-login":               "An attempted login using a suspicious username was detected",
+	"system-call-detect":             "A system call was detected",
+	"tcp-connection":                 "A TCP connection was detected",
+	"trojan-activity":                "A Network Trojan was detected",
+	"unusual-client-port-connection": "A client was using an unusual port",
+	"network-scan":                   "Detection of a Network Scan",
+	"denial-of-service":              "Detection of a Denial of Service Attack",
+	"non-standard-protocol":          "Detection of a non-standard protocol or event",
+	"protocol-command-decode":        "Generic Protocol Command Decode",
+	"web-application-activity":       "access to a potentially vulnerable web application",
+	"web-application-attack":         "Web Application Attack",
+	"misc-activity":                  "Misc activity",
+	"misc-attack":                    "Misc Attack",
+	"icmp-event":                     "Generic ICMP event",
+	"inappropriate-content":          "Inappropriate Content was Detected",
+	"policy-violation":               "Potential Corporate Privacy Violation",
+	"default-login-attempt":          "Attempt to login by a default username and password",
+	"sdf":                            "Sensitive Data was Transmitted Across the Network",
+	"file-format":                    "Known malicious file or file based exploit",
+	"malware-cnc":                    "Known malware command and control traffic",
+	"client-side-exploit":            "Known client side exploit attempt",
+}
+
+func snortCategory(class string) string {
+	if desc, ok := snortClasses[class]; ok {
+		return desc
+	}
+	return class
+}
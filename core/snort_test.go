@@ -0,0 +1,128 @@
+package suricata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnortDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		ok        bool
+		sid       int
+		severity  int
+		category  string
+		signature string
+		src       string
+		srcPort   int
+		dst       string
+		dstPort   int
+		proto     string
+		at        time.Time
+	}{
+		{
+			name: "text rule with all fields",
+			line: `{"seconds":1767323045,"proto":"TCP","src_ap":"198.51.100.7:51234","dst_ap":"192.0.2.1:80","rule":"1:2010935:3","msg":"ET SCAN Suspicious inbound","class":"attempted-recon","priority":2,"service":"http"}`,
+			ok:   true, sid: 2010935, severity: 2, category: "Attempted Information Leak",
+			signature: "ET SCAN Suspicious inbound",
+			src:       "198.51.100.7", srcPort: 51234, dst: "192.0.2.1", dstPort: 80, proto: "TCP",
+			at: time.Unix(1767323045, 0),
+		},
+		{
+			name: "builtin rule gets gid offset",
+			line: `{"seconds":1767323045,"proto":"udp","src_ap":"198.51.100.7:53","dst_ap":"192.0.2.1:5353","rule":"116:408:1"}`,
+			ok:   true, sid: 116*snortGIDBase + 408, severity: 3,
+			signature: "Snort rule 116:408:1",
+			src:       "198.51.100.7", srcPort: 53, dst: "192.0.2.1", dstPort: 5353, proto: "UDP",
+			at: time.Unix(1767323045, 0),
+		},
+		{
+			name: "unbracketed IPv6 and unknown class",
+			line: `{"seconds":1767323045,"proto":"TCP","src_ap":"2001:db8:0:0:0:0:0:7:4444","dst_ap":"2001:db8::1:22","dst_port":22,"gid":1,"sid":1000001,"rev":1,"class":"local-class","priority":9}`,
+			ok:   true, sid: 1000001, severity: 3, category: "local-class",
+			signature: "Snort rule 1:1000001:1",
+			src:       "2001:db8::7", srcPort: 4444, dst: "2001:db8::1:22", dstPort: 22, proto: "TCP",
+			at: time.Unix(1767323045, 0),
+		},
+		{
+			name: "ICMPv6 address ending in a number",
+			line: `{"seconds":1767323045,"proto":"IPV6-ICMP","src_ap":"2001:db8::1:2","dst_ap":"2001:db8::2","rule":"1:100:1"}`,
+			ok:   true, sid: 100, severity: 3,
+			signature: "Snort rule 1:100:1",
+			src:       "2001:db8::1:2", dst: "2001:db8::2", proto: "IPV6-ICMP",
+			at: time.Unix(1767323045, 0),
+		},
+		{
+			name: "ICMP without ports and separate address fields",
+			line: `{"seconds":1767323045,"proto":"ICMP","src_ap":"","src_addr":"::ffff:198.51.100.9","dst_addr":"192.0.2.1","rule":"1:384:8","priority":1}`,
+			ok:   true, sid: 384, severity: 1,
+			signature: "Snort rule 1:384:8",
+			src:       "198.51.100.9", dst: "192.0.2.1", proto: "ICMP",
+			at: time.Unix(1767323045, 0),
+		},
+		{
+			name: "timestamp with year",
+			line: `{"timestamp":"26/01/02-03:04:05.250000","proto":"TCP","src_ap":"198.51.100.7:1","dst_ap":"192.0.2.1:2","rule":"1:5:1"}`,
+			ok:   true, sid: 5, severity: 3,
+			signature: "Snort rule 1:5:1",
+			src:       "198.51.100.7", srcPort: 1, dst: "192.0.2.1", dstPort: 2, proto: "TCP",
+			at: time.Date(2026, 1, 2, 3, 4, 5, 250_000_000, time.Local),
+		},
+		{name: "no sid", line: `{"seconds":1767323045,"src_ap":"198.51.100.7:1","rule":"1:0:0"}`},
+		{name: "hostname source", line: `{"seconds":1767323045,"src_ap":"scanner.example:1","rule":"1:5:1"}`},
+		{name: "not JSON", line: `[**] [1:5:1] text alert [**]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ok := SnortDecoder{}.Decode([]byte(tt.line))
+			if ok != tt.ok {
+				t.Fatalf("Decode ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if a.Source != SourceSnort || a.EventType != EventAlert {
+				t.Errorf("source %q, event type %q", a.Source, a.EventType)
+			}
+			if a.Alert.SignatureID != tt.sid || a.Alert.Severity != tt.severity ||
+				a.Alert.Category != tt.category || a.Alert.Signature != tt.signature {
+				t.Errorf("alert %+v, want sid %d severity %d category %q signature %q",
+					a.Alert, tt.sid, tt.severity, tt.category, tt.signature)
+			}
+			if a.SrcIP != tt.src || a.SrcPort != tt.srcPort || a.DstIP != tt.dst || a.DstPort != tt.dstPort || a.Proto != tt.proto {
+				t.Errorf("flow %s %s:%d -> %s:%d, want %s %s:%d -> %s:%d",
+					a.Proto, a.SrcIP, a.SrcPort, a.DstIP, a.DstPort, tt.proto, tt.src, tt.srcPort, tt.dst, tt.dstPort)
+			}
+			if !a.ParsedTime.Equal(tt.at) {
+				t.Errorf("time %v, want %v", a.ParsedTime, tt.at)
+			}
+		})
+	}
+}
+
+func TestSplitAddrPort(t *testing.T) {
+	tests := []struct {
+		ap   string
+		host string
+		port int
+	}{
+		{"192.0.2.1:80", "192.0.2.1", 80},
+		{"192.0.2.1", "192.0.2.1", 0},
+		{"2001:db8::1:443", "2001:db8::1:443", 0},
+		{"2001:db8::1:2", "2001:db8::1:2", 0},
+		{"2001:db8:0:0:0:0:0:1:443", "2001:db8:0:0:0:0:0:1", 443},
+		{"[2001:db8::1]:443", "2001:db8::1", 443},
+		{"192.0.2.1:http", "192.0.2.1:http", 0},
+		{"::1", "::1", 0},
+		{"fe80::", "fe80::", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		host, port := splitAddrPort(tt.ap)
+		if host != tt.host || port != tt.port {
+			t.Errorf("splitAddrPort(%q) = %q, %d, want %q, %d", tt.ap, host, port, tt.host, tt.port)
+		}
+	}
+}
@@ -2,8 +2,9 @@ package suricata
 
 import (
 	"bufio"
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"net"
@@ -14,78 +15,95 @@ import (
 
 const SuricataSocketPath = "/var/run/suricata/eve.sock"
 
-func StartServer(socketPath string, out chan<- Alert) error {
+// SocketInput is the unix socket server Suricata's eve-log unix_stream output connects to
+type SocketInput struct {
+	Path    string
+	Decoder LineDecoder
+}
+
+func (s *SocketInput) Name() string {
+	return "socket:" + s.Path
+}
+
+func (s *SocketInput) Run(out chan<- Alert, stop <-chan struct{}) error {
 
-	os.Remove(socketPath)
+	os.Remove(s.Path)
 
-	listener, err := net.Listen("unix", socketPath)
+	listener, err := net.Listen("unix", s.Path)
 	if err != nil {
 		return fmt.Errorf("Cannot create Unix Socket server: %w", err)
 	}
 
 	defer listener.Close()
-	defer os.Remove(socketPath)
+	defer os.Remove(s.Path)
 
-	if err := os.Chmod(socketPath, 0666); err != nil {
+	if err := os.Chmod(s.Path, 0666); err != nil {
 		slog.Warn("Cannot set socket permissions", "error", err)
 	}
 
-	slog.Info("Listening on Unix socket", "path", socketPath)
+	slog.Info("Listening on Unix socket", "path", s.Path)
+
+	if stop != nil {
+		go func() {
+			<-stop
+			listener.Close()
+		}()
+	}
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
 			slog.Error("Socket accept failed", "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
 		}
 		slog.Info("Suricata connected")
 
-		go handleConnection(conn, out)
+		go handleConnection(conn, s.Decoder, out)
 	}
 }
 
-// Parsing alerts and sending to unix socket channel
-func handleConnection(conn net.Conn, out chan<- Alert) {
+// Decoding lines, alerts are sent to the channel
+func handleConnection(conn net.Conn, decoder LineDecoder, out chan<- Alert) {
 	defer conn.Close()
 	defer slog.Warn("Suricata disconnected") // ← DODANE
 
-	scanner := bufio.NewScanner(conn)
-	alertCount := 0
+	err := DecodeLines(conn, decoder, func(alert Alert) {
+		out <- alert
+	})
+	if err != nil {
+		slog.Error("Socket read error", "error", err) // ← DODANE
+		log.Printf("[Suricata] Błąd czytania ze socketu: %v", err)
+	}
+}
+
+// Longest EVE line accepted (alerts with payload/packet can be large)
+const maxLineSize = 1 << 20
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
+// DecodeLines runs every non-empty line of r through the decoder and passes
+// the alerts to fn; shared by the socket reader and offline replay
+func DecodeLines(r io.Reader, decoder LineDecoder, fn func(Alert)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
 
-		var alert Alert
-		if err := json.Unmarshal([]byte(line), &alert); err != nil {
-			log.Printf("[Suricata] Błąd parsowania JSON: %v\nJSON: %s", err, line)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
 			continue
 		}
 
-		if alert.Timestamp != "" {
-			parsed, err := time.Parse(time.RFC3339Nano, alert.Timestamp)
-			if err == nil {
-				alert.ParsedTime = parsed
-			} else {
-				alert.ParsedTime = time.Now()
-			}
-		} else {
-			alert.ParsedTime = time.Now()
-		}
-
-		// filtering SID == 0
-		if alert.Alert.SignatureID == 0 {
+		alert, ok := decoder.Decode(line)
+		if !ok {
 			continue
 		}
-
-		alertCount++
-		out <- alert
-	}
-	if err := scanner.Err(); err != nil {
-		slog.Error("Socket read error", "error", err) // ← DODANE
-		log.Printf("[Suricata] Błąd czytania ze socketu: %v", err)
+		fn(alert)
 	}
+	return scanner.Err()
 }
 
 // Writing alert to console
@@ -98,5 +116,8 @@ func HandleAlert(alert Alert) {
 	fmt.Printf("[ALERT] %s (SID: %d)\n", text, alert.Alert.SignatureID)
 	fmt.Printf("  └─ %s:%d -> %s:%d (%s)\n",
 		alert.SrcIP, alert.SrcPort, alert.DstIP, alert.DstPort, alert.Proto)
+	if name := alert.ServerName(); name != "" {
+		fmt.Printf("  └─ %s\n", name)
+	}
 	fmt.Println(strings.Repeat("-", 50))
 }
@@ -0,0 +1,135 @@
+package suricata
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"firefighter/data"
+)
+
+// Whitelist is an in-memory copy of the whitelist table kept in binary
+// prefix tries (one per family), so lookups from the alert path never hit SQLite
+type Whitelist struct {
+	mu   sync.RWMutex
+	v4   *trieNode
+	v6   *trieNode
+	size int
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	terminal bool
+	// unix seconds, 0 = never; the latest expiry wins when entries overlap
+	expiresAt int64
+}
+
+func NewWhitelist() *Whitelist {
+	return &Whitelist{v4: &trieNode{}, v6: &trieNode{}}
+}
+
+// Rebuilding tries from the database and swapping them in
+func (w *Whitelist) Load(ctx context.Context, db data.Repository) error {
+	items, err := db.GetWhitelistDetails(ctx)
+	if err != nil {
+		return err
+	}
+
+	v4, v6 := &trieNode{}, &trieNode{}
+	for _, item := range items {
+		_, prefixes, err := data.ParseWhitelistEntry(item.IP)
+		if err != nil {
+			slog.Warn("Invalid whitelist entry skipped", "entry", item.IP, "error", err)
+			continue
+		}
+		for _, p := range prefixes {
+			root := v4
+			if p.Addr().Is6() {
+				root = v6
+			}
+			root.insert(p, item.ExpiresAt)
+		}
+	}
+
+	w.mu.Lock()
+	w.v4, w.v6, w.size = v4, v6, len(items)
+	w.mu.Unlock()
+
+	slog.Info("Whitelist loaded", "entries", len(items))
+	return nil
+}
+
+// Number of entries loaded
+func (w *Whitelist) Len() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.size
+}
+
+// Checking an address or prefix (e.g. an aggregated IPv6 /64 window key);
+// a prefix is whitelisted only when an entry covers all of it
+func (w *Whitelist) Contains(ip string) bool {
+	target, err := parseTarget(ip)
+	if err != nil {
+		return false
+	}
+	return w.ContainsPrefix(target, time.Now())
+}
+
+func (w *Whitelist) ContainsPrefix(target netip.Prefix, now time.Time) bool {
+	w.mu.RLock()
+	root := w.v4
+	if target.Addr().Is6() {
+		root = w.v6
+	}
+	w.mu.RUnlock()
+
+	return root.covers(target, now.Unix())
+}
+
+func (n *trieNode) insert(p netip.Prefix, expiresAt int64) {
+	p = p.Masked()
+	addr := p.Addr().AsSlice()
+
+	node := n
+	for i := 0; i < p.Bits(); i++ {
+		bit := (addr[i/8] >> (7 - i%8)) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+		node = node.children[bit]
+	}
+
+	if !node.terminal {
+		node.terminal = true
+		node.expiresAt = expiresAt
+		return
+	}
+	// Overlapping entries: never-expiring wins, otherwise the later expiry
+	if node.expiresAt != 0 && (expiresAt == 0 || expiresAt > node.expiresAt) {
+		node.expiresAt = expiresAt
+	}
+}
+
+// Walking the target's bits and stopping at the first live entry on the path
+func (n *trieNode) covers(target netip.Prefix, now int64) bool {
+	addr := target.Addr().AsSlice()
+
+	node := n
+	for i := 0; ; i++ {
+		if node.terminal && (node.expiresAt == 0 || node.expiresAt > now) {
+			return true
+		}
+		if i >= target.Bits() {
+			return false
+		}
+
+		bit := (addr[i/8] >> (7 - i%8)) & 1
+		node = node.children[bit]
+		if node == nil {
+			return false
+		}
+	}
+}
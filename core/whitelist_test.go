@@ -0,0 +1,111 @@
+package suricata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"firefighter/data"
+)
+
+type whitelistRepo struct {
+	data.Repository
+	items []data.WhitelistDetails
+}
+
+func (r whitelistRepo) GetWhitelistDetails(context.Context) ([]data.WhitelistDetails, error) {
+	return r.items, nil
+}
+
+func TestWhitelistContains(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour).Unix()
+	later := now.Add(time.Hour).Unix()
+
+	w := NewWhitelist()
+	err := w.Load(context.Background(), whitelistRepo{items: []data.WhitelistDetails{
+		{IP: "10.0.0.0/24"},
+		{IP: "192.0.2.10-192.0.2.20"},
+		{IP: "203.0.113.5", ExpiresAt: expired},
+		{IP: "203.0.113.7", ExpiresAt: expired},
+		{IP: "203.0.113.7", ExpiresAt: later},
+		{IP: "198.51.100.1", ExpiresAt: later},
+		{IP: "2001:db8::/48"},
+		{IP: "2001:db8:ffff::1"},
+		{IP: "not an address"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.0.255", true},
+		{"10.0.1.0", false},
+		{"10.0.0.0/25", true},  // prefix inside the entry
+		{"10.0.0.0/23", false}, // wider than the entry
+		{"192.0.2.9", false},
+		{"192.0.2.10", true},
+		{"192.0.2.16", true},
+		{"192.0.2.20", true},
+		{"192.0.2.21", false},
+		{"::ffff:192.0.2.15", true}, // mapped form of a listed address
+		{"203.0.113.5", false},      // expired
+		{"203.0.113.7", true},       // expired entry overlapped by a live one
+		{"198.51.100.1", true},
+		{"2001:db8:0:1::1", true},
+		{"2001:db8:0:1::/64", true}, // IPv6 window key inside the /48
+		{"2001:db9::1", false},
+		{"2001:db8:ffff::1", true},
+		{"2001:db8:ffff::/64", false}, // single address does not cover the /64
+		{"garbage", false},
+	}
+	for _, tt := range tests {
+		if got := w.Contains(tt.ip); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if got := w.Len(); got != 9 {
+		t.Errorf("Len() = %d, want 9", got)
+	}
+}
+
+func TestWhitelistExpiry(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	w := NewWhitelist()
+	err := w.Load(context.Background(), whitelistRepo{items: []data.WhitelistDetails{
+		{IP: "192.0.2.0/24", ExpiresAt: start.Add(time.Hour).Unix()},
+		{IP: "192.0.2.128/25", ExpiresAt: start.Add(2 * time.Hour).Unix()},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		advance time.Duration
+		target  string
+		want    bool
+	}{
+		{0, "192.0.2.1", true},
+		{59 * time.Minute, "192.0.2.1", true},
+		{time.Minute, "192.0.2.1", false}, // expires_at itself no longer counts
+		{0, "192.0.2.200", true},          // the /25 lives longer
+		{time.Hour, "192.0.2.200", false},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		target, err := parseTarget(tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.ContainsPrefix(target, clock.Now()); got != tt.want {
+			t.Errorf("at %s ContainsPrefix(%s) = %v, want %v", clock.Now().Sub(start), tt.target, got, tt.want)
+		}
+	}
+}
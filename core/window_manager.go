@@ -2,48 +2,294 @@ package suricata
 
 import (
 	"fmt"
+	"hash/fnv"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"firefighter/data"
 )
 
-// Manages multiple SlidingWindows by source IP
+// Number of independently locked window maps
+const windowShards = 64
+
+// How far behind the newest event an alert may arrive and still be counted
+const DefaultLateness = 30 * time.Second
+
+// How far ahead of the host clock an alert timestamp is trusted. Later
+// timestamps are clamped, otherwise one future-dated alert would push event
+// time ahead and every current alert after it would count as late.
+const DefaultMaxSkew = 10 * time.Second
+
+// Manages multiple SlidingWindows by source IP. Safe for concurrent use:
+// windows are spread over shards by key hash, each shard has its own lock,
+// so ingestion, analysis and API reads only contend on the same shard.
 type WindowManager struct {
 	Duration time.Duration
-	Windows  map[string]*SlidingWindow
+	shards   [windowShards]windowShard
+
+	// Swapped atomically on reload, see Policy/SetPolicy
+	policy atomic.Pointer[Policy]
+
+	// IPv6 sources are grouped by this prefix length (e.g. 64), 0 = per address
+	IPv6Prefix int
+
+	// Optional in-memory whitelist, AnalyzeAlerts falls back to the database without it
+	Whitelist *Whitelist
+
+	// Windows run on event time (alert ParsedTime). Clock only fills in missing
+	// timestamps and measures idle time between arrivals for Sweep.
+	Clock    Clock
+	Lateness time.Duration
+	MaxSkew  time.Duration // capped at Lateness, see DefaultMaxSkew
+
+	eventTime   atomic.Int64 // newest ParsedTime seen, unix nanos
+	lastArrival atomic.Int64 // Clock time of that alert's arrival
+	lateDropped atomic.Uint64
+}
+
+// SlidingWindows are not synchronized themselves, only touched under mu
+type windowShard struct {
+	mu      sync.Mutex
+	windows map[string]*SlidingWindow
+}
+
+// Point-in-time copy of one window for the API
+type WindowSnapshot struct {
+	IP        string    `json:"ip"`
+	Events    int       `json:"events"`
+	Score     int       `json:"score"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
 }
 
 // Creating new WindowManager
 func NewWindowManager(duration time.Duration) *WindowManager {
-	return &WindowManager{
+	wm := &WindowManager{
 		Duration: duration,
-		Windows:  make(map[string]*SlidingWindow),
+		Clock:    SystemClock{},
+		Lateness: DefaultLateness,
+		MaxSkew:  DefaultMaxSkew,
+	}
+	for i := range wm.shards {
+		wm.shards[i].windows = make(map[string]*SlidingWindow)
+	}
+	wm.policy.Store(DefaultPolicy())
+	return wm
+}
+
+// Current detection policy; callers keep the pointer for a whole pass
+func (wm *WindowManager) Policy() *Policy {
+	return wm.policy.Load()
+}
+
+// Replacing the policy; in-flight analysis finishes on the old one
+func (wm *WindowManager) SetPolicy(p *Policy) {
+	wm.policy.Store(p)
+}
+
+func (wm *WindowManager) shard(key string) *windowShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &wm.shards[h.Sum32()%windowShards]
+}
+
+// Window key for a source address: the address itself or its IPv6 prefix
+func (wm *WindowManager) key(addr netip.Addr) string {
+	if addr.Is6() && wm.IPv6Prefix > 0 && wm.IPv6Prefix < 128 {
+		if prefix, err := addr.Prefix(wm.IPv6Prefix); err == nil {
+			return prefix.String()
+		}
+	}
+	return addr.String()
+}
+
+// Key for alerts that did not pass through the reader (SrcAddr unset)
+func (wm *WindowManager) keyFor(ip string) string {
+	addr, err := netip.ParseAddr(data.CanonicalIP(ip))
+	if err != nil {
+		return data.CanonicalIP(ip)
+	}
+	return wm.key(addr)
+}
+
+// Moving event time forward to t (never back), returns the newest event time
+func (wm *WindowManager) advance(t time.Time) time.Time {
+	n := t.UnixNano()
+	for {
+		cur := wm.eventTime.Load()
+		if n <= cur {
+			return time.Unix(0, cur)
+		}
+		if wm.eventTime.CompareAndSwap(cur, n) {
+			wm.lastArrival.Store(wm.Clock.Now().UnixNano())
+			return t
+		}
 	}
 }
 
-// Adding alert to WindowManager by src IP
-func (wm *WindowManager) Add(alert Alert) {
-	ip := alert.SrcIP
-	if _, exists := wm.Windows[ip]; !exists {
-		wm.Windows[ip] = NewSlidingWindow(wm.Duration)
+// Newest event time seen (zero before the first alert)
+func (wm *WindowManager) EventTime() time.Time {
+	n := wm.eventTime.Load()
+	if n == 0 {
+		return time.Time{}
 	}
-	wm.Windows[ip].Add(alert)
+	return time.Unix(0, n)
 }
 
+// Alerts dropped for arriving later than Lateness behind the newest event
+func (wm *WindowManager) LateDropped() uint64 {
+	return wm.lateDropped.Load()
+}
+
+// Event time extrapolated by the idle time since the newest alert arrived,
+// so quiet windows still expire without trusting sensor and host clocks to agree
+func (wm *WindowManager) streamTime() time.Time {
+	newest := wm.EventTime()
+	if newest.IsZero() {
+		return wm.Clock.Now()
+	}
+	idle := wm.Clock.Now().Sub(time.Unix(0, wm.lastArrival.Load()))
+	if idle < 0 {
+		idle = 0
+	}
+	return newest.Add(idle)
+}
+
+// Adding alert to WindowManager by src IP, returns the window key.
+// Timestamps ahead of Clock by more than MaxSkew are clamped. Alerts older than
+// the newest event minus Lateness are counted in LateDropped and ignored.
+func (wm *WindowManager) Add(alert Alert) string {
+	var ip string
+	if alert.SrcAddr.IsValid() {
+		ip = wm.key(alert.SrcAddr)
+	} else {
+		ip = wm.keyFor(alert.SrcIP)
+	}
+
+	if alert.ParsedTime.IsZero() {
+		alert.ParsedTime = wm.Clock.Now()
+	}
+	// A clamp beyond Lateness would still make current alerts late
+	if limit := wm.Clock.Now().Add(min(wm.MaxSkew, wm.Lateness)); alert.ParsedTime.After(limit) {
+		alert.ParsedTime = limit
+	}
+	now := wm.advance(alert.ParsedTime)
+	if alert.ParsedTime.Before(now.Add(-wm.Lateness)) {
+		wm.lateDropped.Add(1)
+		return ip
+	}
+
+	s := wm.shard(ip)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window, exists := s.windows[ip]
+	if !exists {
+		window = NewSlidingWindow(wm.Duration)
+		s.windows[ip] = window
+	}
+	window.usePolicy(wm.Policy())
+	window.Add(alert, now)
+	return ip
+}
+
+// Evicting expired events from windows that stopped receiving alerts and
+// dropping the empty ones; returns the number of windows removed
+func (wm *WindowManager) Sweep() int {
+	now := wm.streamTime()
+	removed := 0
+	for i := range wm.shards {
+		s := &wm.shards[i]
+		s.mu.Lock()
+		for ip, window := range s.windows {
+			window.evict(now)
+			if window.Events.Len() == 0 {
+				delete(s.windows, ip)
+				removed++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+// Removing window of an address or prefix (and the prefix window an address falls into)
 func (wm *WindowManager) RemoveIP(ip string) {
-	delete(wm.Windows, ip)
+	ip = data.CanonicalIP(ip)
+	for _, key := range []string{ip, wm.keyFor(ip)} {
+		s := wm.shard(key)
+		s.mu.Lock()
+		delete(s.windows, key)
+		s.mu.Unlock()
+	}
 	fmt.Printf("🧹 Wyczyszczono sliding window dla %s\n", ip)
 }
 
+// Number of tracked windows
+func (wm *WindowManager) Len() int {
+	n := 0
+	for i := range wm.shards {
+		s := &wm.shards[i]
+		s.mu.Lock()
+		n += len(s.windows)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Copying window state ordered by score (highest first); each shard is
+// locked only while its own windows are copied so ingestion keeps running
+func (wm *WindowManager) Snapshot() []WindowSnapshot {
+	policy := wm.Policy()
+
+	var out []WindowSnapshot
+	for i := range wm.shards {
+		s := &wm.shards[i]
+		s.mu.Lock()
+		for ip, window := range s.windows {
+			if window.Events.Len() == 0 {
+				continue
+			}
+			stats := scoreWindow(policy, window)
+			out = append(out, WindowSnapshot{
+				IP:        ip,
+				Events:    window.Events.Len(),
+				Score:     stats.Score,
+				FirstSeen: window.Events.Front().Value.(Alert).ParsedTime,
+				LastSeen:  window.Events.Back().Value.(Alert).ParsedTime,
+			})
+		}
+		s.mu.Unlock()
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].IP < out[j].IP
+	})
+	return out
+}
+
 // Print all windows (for debugging)
 func (wm *WindowManager) PrintAll() {
 	fmt.Println("Debug Sliding Window: ")
-	for ip, window := range wm.Windows {
-		fmt.Printf(" IP %s (%d alertów)\n", ip, window.Events.Len())
-		i := 1
-		for e := window.Events.Front(); e != nil; e = e.Next() {
-			a := e.Value.(Alert)
-			fmt.Printf("   %d. %s -> %s:%d (%s)\n",
-				i, a.SrcIP, a.DstIP, a.DstPort, a.Alert.Signature)
+	for i := range wm.shards {
+		s := &wm.shards[i]
+		s.mu.Lock()
+		for ip, window := range s.windows {
+			fmt.Printf(" IP %s (%d alertów)\n", ip, window.Events.Len())
+			i := 1
+			for e := window.Events.Front(); e != nil; e = e.Next() {
+				a := e.Value.(Alert)
+				fmt.Printf("   %d. %s -> %s:%d (%s)\n",
+					i, a.SrcIP, a.DstIP, a.DstPort, a.Alert.Signature)
+			}
+			fmt.Println("--------------------------------")
 		}
-		fmt.Println("--------------------------------")
+		s.mu.Unlock()
 	}
 }
@@ -0,0 +1,306 @@
+package suricata
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"firefighter/data"
+)
+
+// Repository with nothing blocked; AnalyzeIP only asks IsBlocked once the
+// in-memory whitelist is set
+type unblockedRepo struct {
+	data.Repository
+}
+
+func (unblockedRepo) IsBlocked(context.Context, string) (bool, error) { return false, nil }
+
+var testStart = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func testManager(window time.Duration) (*WindowManager, *ManualClock) {
+	clock := NewManualClock(testStart)
+	wm := NewWindowManager(window)
+	wm.Clock = clock
+	wm.Whitelist = NewWhitelist()
+	return wm, clock
+}
+
+// Alert i of a mix that touches every aggregate: severities, categories,
+// ports, protocols, SIDs and flows repeat with different periods
+func testAlert(src string, i int, at time.Time) Alert {
+	protos := []string{"TCP", "UDP", "ICMP"}
+	return Alert{
+		SrcIP:      src,
+		SrcAddr:    netip.MustParseAddr(src),
+		DstPort:    20 + i%7,
+		Proto:      protos[i%len(protos)],
+		FlowID:     uint64(1 + i%11),
+		ParsedTime: at,
+		Alert: AlertInfo{
+			Category:    fmt.Sprintf("cat-%d", i%5),
+			Severity:    1 + i%3,
+			SignatureID: 1000 + i%13,
+		},
+	}
+}
+
+// Aggregates rebuilt from scratch over the window's current events
+func rescan(w *SlidingWindow, p *Policy) windowAgg {
+	ref := &SlidingWindow{Events: w.Events, policy: p, agg: newWindowAgg()}
+	for e := ref.Events.Front(); e != nil; e = e.Next() {
+		ref.account(e.Value.(Alert), 1)
+	}
+	return ref.agg
+}
+
+func checkAggregates(t *testing.T, wm *WindowManager, when string) {
+	t.Helper()
+	policy := wm.Policy()
+	for i := range wm.shards {
+		s := &wm.shards[i]
+		s.mu.Lock()
+		for key, w := range s.windows {
+			w.usePolicy(policy)
+			if want := rescan(w, policy); !reflect.DeepEqual(w.agg, want) {
+				t.Errorf("%s: window %s aggregates %+v, rescan %+v", when, key, w.agg, want)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestAggregatesAfterEviction(t *testing.T) {
+	wm, clock := testManager(time.Minute)
+
+	// One alert every 5s for 10 minutes: the window keeps evicting by time
+	for i := 0; i < 120; i++ {
+		at := testStart.Add(time.Duration(i) * 5 * time.Second)
+		clock.Set(at)
+		wm.Add(testAlert("192.0.2.1", i, at))
+		checkAggregates(t, wm, fmt.Sprintf("alert %d", i))
+	}
+
+	// A burst over windowCapacity evicts from the front by count
+	at := clock.Now()
+	for i := 0; i < windowCapacity+50; i++ {
+		wm.Add(testAlert("192.0.2.2", i, at))
+	}
+	checkAggregates(t, wm, "capacity")
+
+	// Out-of-order alerts inside the lateness bound
+	for i := 0; i < 20; i++ {
+		wm.Add(testAlert("192.0.2.3", i, at.Add(-time.Duration(i)*time.Second)))
+	}
+	checkAggregates(t, wm, "out of order")
+}
+
+func TestAggregatesAfterSweep(t *testing.T) {
+	wm, clock := testManager(time.Minute)
+
+	for i := 0; i < 300; i++ {
+		at := testStart.Add(time.Duration(i) * 200 * time.Millisecond)
+		clock.Set(at)
+		wm.Add(testAlert(fmt.Sprintf("198.51.100.%d", i%30), i, at))
+	}
+
+	// Idle time moves stream time forward: half of each window expires
+	clock.Advance(30 * time.Second)
+	if removed := wm.Sweep(); removed != 0 {
+		t.Errorf("Sweep removed %d windows, want 0", removed)
+	}
+	checkAggregates(t, wm, "partial sweep")
+
+	clock.Advance(time.Hour)
+	if removed := wm.Sweep(); removed != 30 {
+		t.Errorf("Sweep removed %d windows, want 30", removed)
+	}
+	if n := wm.Len(); n != 0 {
+		t.Errorf("%d windows left after full sweep", n)
+	}
+}
+
+func TestAggregatesAfterPolicySwap(t *testing.T) {
+	wm, clock := testManager(time.Minute)
+	ctx := context.Background()
+
+	add := func(from, n int) {
+		for i := from; i < from+n; i++ {
+			at := testStart.Add(time.Duration(i) * time.Second)
+			clock.Set(at)
+			wm.Add(testAlert("203.0.113.7", i, at))
+		}
+	}
+	add(0, 40)
+	checkAggregates(t, wm, "default policy")
+
+	points := 7
+	scoring := DefaultScoring()
+	scoring.Threshold = 1 << 20
+	scoring.SeverityPoints = map[int]int{1: 1, 2: 3}
+	scoring.SIDs = map[int]SIDRule{
+		1001: {Ignore: true},
+		1002: {Points: &points},
+	}
+	policy, err := NewPolicy(scoring, []SuppressRule{{Category: "cat-3"}, {SID: 1004}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wm.SetPolicy(policy)
+
+	// AnalyzeIP rebuilds through usePolicy before scoring
+	key := wm.key(netip.MustParseAddr("203.0.113.7"))
+	if _, ok := wm.AnalyzeIP(ctx, unblockedRepo{}, key); ok {
+		t.Fatal("unexpected block decision")
+	}
+	checkAggregates(t, wm, "after swap")
+
+	// Further adds and evictions stay incremental under the new policy
+	add(40, 100)
+	checkAggregates(t, wm, "after swap and eviction")
+
+	wm.SetPolicy(DefaultPolicy())
+	add(140, 5)
+	checkAggregates(t, wm, "swap back")
+}
+
+func TestLateness(t *testing.T) {
+	tests := []struct {
+		name     string
+		lateness time.Duration
+		offsets  []time.Duration // event times relative to testStart, in arrival order
+		events   int             // left in the window
+		dropped  uint64
+	}{
+		{"in order", 30 * time.Second, []time.Duration{0, time.Second, 2 * time.Second}, 3, 0},
+		{"late within bound", 30 * time.Second, []time.Duration{time.Minute, 40 * time.Second}, 2, 0},
+		{"exactly at bound", 30 * time.Second, []time.Duration{time.Minute, 30 * time.Second}, 2, 0},
+		{"too late", 30 * time.Second, []time.Duration{time.Minute, 29 * time.Second, 0}, 1, 2},
+		{"zero lateness", 0, []time.Duration{time.Minute, time.Minute, 59 * time.Second}, 2, 1},
+		// Accepted late alerts still fall out of the 10 minute window behind the newest event
+		{"late inside window", time.Hour, []time.Duration{0, 11 * time.Minute, 2 * time.Minute}, 2, 0},
+		{"late behind window", time.Hour, []time.Duration{0, 11 * time.Minute, time.Minute}, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm, clock := testManager(10 * time.Minute)
+			wm.Lateness = tt.lateness
+			// Host clock ahead of every event, so none are clamped
+			clock.Set(testStart.Add(time.Hour))
+
+			var key string
+			for i, off := range tt.offsets {
+				clock.Advance(time.Second)
+				key = wm.Add(testAlert("192.0.2.10", i, testStart.Add(off)))
+			}
+
+			s := wm.shard(key)
+			if got := s.windows[key].Events.Len(); got != tt.events {
+				t.Errorf("%d events in window, want %d", got, tt.events)
+			}
+			if got := wm.LateDropped(); got != tt.dropped {
+				t.Errorf("LateDropped() = %d, want %d", got, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestFutureAlert(t *testing.T) {
+	wm, clock := testManager(10 * time.Minute)
+
+	// Sensor with a broken clock a day ahead, then current alerts
+	key := wm.Add(testAlert("192.0.2.30", 0, testStart.Add(24*time.Hour)))
+	for i := 1; i <= 5; i++ {
+		clock.Advance(time.Second)
+		wm.Add(testAlert("192.0.2.30", i, clock.Now()))
+	}
+
+	if got := wm.LateDropped(); got != 0 {
+		t.Errorf("LateDropped() = %d, want 0", got)
+	}
+	if got, want := wm.EventTime(), testStart.Add(DefaultMaxSkew); !got.Equal(want) {
+		t.Errorf("EventTime() = %s, want %s", got, want)
+	}
+	s := wm.shard(key)
+	if got := s.windows[key].Events.Len(); got != 6 {
+		t.Errorf("%d events in window, want 6", got)
+	}
+}
+
+func TestWindowExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		idle    time.Duration // wall time after the last alert arrived
+		windows int           // left after Sweep
+	}{
+		{"still inside the window", 5 * time.Minute, 1},
+		{"last alert just expired", 10 * time.Minute, 0},
+		{"long idle", 24 * time.Hour, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm, clock := testManager(10 * time.Minute)
+
+			// Sensor clock an hour behind the host: only idle time counts
+			eventTime := testStart.Add(-time.Hour)
+			wm.Add(testAlert("198.51.100.20", 0, eventTime))
+			clock.Advance(tt.idle)
+
+			wm.Sweep()
+			if got := wm.Len(); got != tt.windows {
+				t.Errorf("%d windows after Sweep, want %d", got, tt.windows)
+			}
+		})
+	}
+}
+
+// Alert path of the main loop: Add then AnalyzeIP, over thousands of sources
+// so every shard is used
+func BenchmarkAddAnalyzeIP(b *testing.B) {
+	const sources = 4096
+
+	wm, clock := testManager(10 * time.Minute)
+	ctx := context.Background()
+	db := unblockedRepo{}
+
+	// Scoring without decisions, which would log and reset windows
+	scoring := DefaultScoring()
+	scoring.Threshold = 1 << 30
+	policy, err := NewPolicy(scoring, nil, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	wm.SetPolicy(policy)
+
+	alerts := make([]Alert, sources)
+	for i := range alerts {
+		ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		alerts[i] = testAlert(ip, i, testStart)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		a := alerts[i%sources]
+		a.ParsedTime = testStart.Add(time.Duration(i) * time.Millisecond)
+		clock.Set(a.ParsedTime)
+		wm.AnalyzeIP(ctx, db, wm.Add(a))
+	}
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "alerts/s")
+
+	used := 0
+	for i := range wm.shards {
+		if len(wm.shards[i].windows) > 0 {
+			used++
+		}
+	}
+	if b.N >= sources && used != windowShards {
+		b.Fatalf("alerts reached %d of %d shards", used, windowShards)
+	}
+}
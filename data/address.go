@@ -0,0 +1,116 @@
+package data
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// CanonicalIP returns the canonical text form of an address or prefix, so
+// "2001:0db8:0000::0001", "2001:db8::1" and "::ffff:10.0.0.1" / "10.0.0.1"
+// are stored and looked up under one key. Unparseable input is only trimmed.
+func CanonicalIP(ip string) string {
+	ip = strings.TrimSpace(ip)
+
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return addr.Unmap().WithZone("").String()
+	}
+
+	if prefix, err := netip.ParsePrefix(ip); err == nil {
+		addr := prefix.Addr()
+		bits := prefix.Bits()
+		if addr.Is4In6() {
+			addr = addr.Unmap()
+			bits -= 96
+		}
+		if p, err := addr.Prefix(bits); err == nil {
+			if p.IsSingleIP() {
+				return p.Addr().String()
+			}
+			return p.String()
+		}
+	}
+
+	return ip
+}
+
+// ParseWhitelistEntry accepts a single address, a CIDR prefix ("10.0.0.0/24")
+// or an inclusive range ("10.0.0.1-10.0.0.50") and returns its canonical text
+// together with the prefixes covering it.
+func ParseWhitelistEntry(entry string) (string, []netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+
+	if from, to, ok := strings.Cut(entry, "-"); ok {
+		start, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid range start %q", from)
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid range end %q", to)
+		}
+		start, end = start.Unmap().WithZone(""), end.Unmap().WithZone("")
+
+		if start.Is4() != end.Is4() {
+			return "", nil, fmt.Errorf("range %q mixes IPv4 and IPv6", entry)
+		}
+		if end.Less(start) {
+			return "", nil, fmt.Errorf("range %q ends before it starts", entry)
+		}
+		return start.String() + "-" + end.String(), rangeToPrefixes(start, end), nil
+	}
+
+	canonical := CanonicalIP(entry)
+	if addr, err := netip.ParseAddr(canonical); err == nil {
+		return canonical, []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}, nil
+	}
+	if prefix, err := netip.ParsePrefix(canonical); err == nil {
+		return canonical, []netip.Prefix{prefix}, nil
+	}
+
+	return "", nil, fmt.Errorf("invalid whitelist entry %q, expected address, CIDR or range", entry)
+}
+
+// Smallest set of prefixes exactly covering start..end
+func rangeToPrefixes(start, end netip.Addr) []netip.Prefix {
+	var out []netip.Prefix
+	for {
+		bits := start.BitLen()
+		// Widen while the prefix stays aligned on start and ends within range
+		for bits > 0 {
+			wider := netip.PrefixFrom(start, bits-1).Masked()
+			if wider.Addr() != start || end.Less(lastAddr(wider)) {
+				break
+			}
+			bits--
+		}
+
+		prefix := netip.PrefixFrom(start, bits)
+		out = append(out, prefix)
+
+		last := lastAddr(prefix)
+		if last == end {
+			return out
+		}
+		start = last.Next()
+	}
+}
+
+// Last address of a prefix (all host bits set)
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().As16()
+	offset := 0
+	if p.Addr().Is4() {
+		offset = 96
+	}
+
+	for i := offset + p.Bits(); i < 128; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+
+	addr := netip.AddrFrom16(b)
+	if p.Addr().Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func prefixes(s ...string) []netip.Prefix {
+	out := make([]netip.Prefix, len(s))
+	for i, p := range s {
+		out[i] = netip.MustParsePrefix(p)
+	}
+	return out
+}
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       []netip.Prefix
+	}{
+		{"10.0.0.7", "10.0.0.7", prefixes("10.0.0.7/32")},
+		{"10.0.0.0", "10.0.0.255", prefixes("10.0.0.0/24")},
+		{"10.0.0.1", "10.0.0.50", prefixes(
+			"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/29",
+			"10.0.0.16/28", "10.0.0.32/28", "10.0.0.48/31", "10.0.0.50/32")},
+		{"10.0.0.255", "10.0.1.0", prefixes("10.0.0.255/32", "10.0.1.0/32")},
+		{"0.0.0.0", "255.255.255.255", prefixes("0.0.0.0/0")},
+		{"2001:db8::", "2001:db8::ffff", prefixes("2001:db8::/112")},
+		{"2001:db8::1", "2001:db8::2", prefixes("2001:db8::1/128", "2001:db8::2/128")},
+	}
+
+	for _, tt := range tests {
+		got := rangeToPrefixes(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rangeToPrefixes(%s, %s) = %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestParseWhitelistEntry(t *testing.T) {
+	tests := []struct {
+		entry     string
+		canonical string
+		want      []netip.Prefix
+		wantErr   bool
+	}{
+		{entry: " 192.0.2.1 ", canonical: "192.0.2.1", want: prefixes("192.0.2.1/32")},
+		{entry: "::ffff:192.0.2.1", canonical: "192.0.2.1", want: prefixes("192.0.2.1/32")},
+		{entry: "2001:0db8:0000::0001", canonical: "2001:db8::1", want: prefixes("2001:db8::1/128")},
+		{entry: "10.0.0.9/24", canonical: "10.0.0.0/24", want: prefixes("10.0.0.0/24")},
+		{entry: "10.0.0.9/32", canonical: "10.0.0.9", want: prefixes("10.0.0.9/32")},
+		{entry: "10.0.0.0 - 10.0.0.3", canonical: "10.0.0.0-10.0.0.3", want: prefixes("10.0.0.0/30")},
+		{entry: "10.0.0.5-10.0.0.5", canonical: "10.0.0.5-10.0.0.5", want: prefixes("10.0.0.5/32")},
+		{entry: "10.0.0.9-10.0.0.1", wantErr: true},
+		{entry: "10.0.0.1-2001:db8::1", wantErr: true},
+		{entry: "10.0.0.1-", wantErr: true},
+		{entry: "host.example", wantErr: true},
+		{entry: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		canonical, got, err := ParseWhitelistEntry(tt.entry)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseWhitelistEntry(%q) = %q, want error", tt.entry, canonical)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseWhitelistEntry(%q): %v", tt.entry, err)
+			continue
+		}
+		if canonical != tt.canonical || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseWhitelistEntry(%q) = %q %v, want %q %v", tt.entry, canonical, got, tt.canonical, tt.want)
+		}
+	}
+}
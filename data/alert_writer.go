@@ -0,0 +1,142 @@
+package data
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AlertWriter persists alerts behind the main loop: Add only queues, a
+// goroutine writes the queue in transactions of up to BatchSize alerts or
+// whatever arrived within FlushInterval, each under its own Timeout. A full
+// queue drops alerts rather than stall analysis; drops are counted in Stats.
+type AlertWriter struct {
+	db            Repository
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+
+	queue  chan AlertRecord
+	done   chan struct{}
+	mu     sync.RWMutex // guards closed against Add racing Close
+	closed bool
+
+	written   atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+	lastFlush atomic.Int64
+}
+
+// Queue depth and totals since start
+type WriterStats struct {
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	Written   uint64 `json:"written"`
+	Dropped   uint64 `json:"dropped"`
+	Failed    uint64 `json:"failed"` // lost in batches the database rejected
+	LastFlush int64  `json:"last_flush,omitempty"`
+}
+
+// Starting the writer goroutine; Close flushes and stops it
+func NewAlertWriter(db Repository, batchSize int, flushInterval, timeout time.Duration, queueSize int) *AlertWriter {
+	w := &AlertWriter{
+		db:            db,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		timeout:       timeout,
+		queue:         make(chan AlertRecord, queueSize),
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Queuing an alert without blocking; false when it was dropped
+func (w *AlertWriter) Add(a AlertRecord) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return false
+	}
+
+	select {
+	case w.queue <- a:
+		return true
+	default:
+		w.dropped.Add(1)
+		return false
+	}
+}
+
+// Writing what is queued and stopping; later Adds are dropped
+func (w *AlertWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
+
+func (w *AlertWriter) Stats() WriterStats {
+	return WriterStats{
+		Queued:    len(w.queue),
+		Capacity:  cap(w.queue),
+		Written:   w.written.Load(),
+		Dropped:   w.dropped.Load(),
+		Failed:    w.failed.Load(),
+		LastFlush: w.lastFlush.Load(),
+	}
+}
+
+func (w *AlertWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AlertRecord, 0, w.batchSize)
+	var reportedDrops uint64
+	flush := func() {
+		if dropped := w.dropped.Load(); dropped > reportedDrops {
+			slog.Warn("Alert write queue full, alerts dropped", "dropped", dropped-reportedDrops, "capacity", cap(w.queue))
+			reportedDrops = dropped
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		// Not tied to shutdown: the last flush runs after the service is told to stop
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		err := w.db.AddAlerts(ctx, batch)
+		cancel()
+		if err != nil {
+			w.failed.Add(uint64(len(batch)))
+			slog.Error("Failed to save alerts to database", "alerts", len(batch), "error", err)
+		} else {
+			w.written.Add(uint64(len(batch)))
+		}
+		w.lastFlush.Store(time.Now().Unix())
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case a, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, a)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
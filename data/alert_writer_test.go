@@ -0,0 +1,142 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Reports each batch size; with gate set every write waits for it
+type batchRepo struct {
+	Repository
+	batches chan int
+	started chan struct{}
+	gate    chan struct{}
+	err     error
+}
+
+func newBatchRepo() *batchRepo {
+	return &batchRepo{batches: make(chan int, 100), started: make(chan struct{}, 100)}
+}
+
+func (r *batchRepo) AddAlerts(_ context.Context, alerts []AlertRecord) error {
+	r.started <- struct{}{}
+	if r.gate != nil {
+		<-r.gate
+	}
+	r.batches <- len(alerts)
+	return r.err
+}
+
+func (r *batchRepo) next(t *testing.T) int {
+	t.Helper()
+	select {
+	case n := <-r.batches:
+		return n
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch written")
+		return 0
+	}
+}
+
+// Batches written so far, without waiting
+func (r *batchRepo) written() []int {
+	var out []int
+	for {
+		select {
+		case n := <-r.batches:
+			out = append(out, n)
+		default:
+			return out
+		}
+	}
+}
+
+func addN(w *AlertWriter, n int) {
+	for i := range n {
+		w.Add(AlertRecord{IP: "198.51.100.7", SID: i + 1})
+	}
+}
+
+func TestAlertWriterBatchSize(t *testing.T) {
+	db := newBatchRepo()
+	w := NewAlertWriter(db, 3, time.Hour, time.Second, 10)
+
+	addN(w, 7)
+	if got := []int{db.next(t), db.next(t)}; !reflect.DeepEqual(got, []int{3, 3}) {
+		t.Errorf("batches %v, want two full ones", got)
+	}
+	w.Close()
+	if got := db.written(); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("batches on close %v, want the last alert", got)
+	}
+	if st := w.Stats(); st.Written != 7 || st.Dropped != 0 || st.LastFlush == 0 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestAlertWriterInterval(t *testing.T) {
+	db := newBatchRepo()
+	w := NewAlertWriter(db, 100, 20*time.Millisecond, time.Second, 10)
+	defer w.Close()
+
+	addN(w, 2)
+	if n := db.next(t); n != 2 {
+		t.Errorf("batch of %d, want the 2 queued alerts", n)
+	}
+}
+
+func TestAlertWriterDrops(t *testing.T) {
+	db := newBatchRepo()
+	db.gate = make(chan struct{})
+	w := NewAlertWriter(db, 1, time.Hour, time.Second, 2)
+
+	// The first alert is being written, two wait in the queue, the rest are dropped
+	addN(w, 1)
+	<-db.started
+	if !w.Add(AlertRecord{SID: 2}) || !w.Add(AlertRecord{SID: 3}) {
+		t.Fatal("alerts dropped with room in the queue")
+	}
+	if w.Add(AlertRecord{SID: 4}) {
+		t.Error("Add succeeded on a full queue")
+	}
+	if st := w.Stats(); st.Queued != 2 || st.Capacity != 2 || st.Dropped != 1 {
+		t.Errorf("stats %+v, want 2 queued and 1 dropped", st)
+	}
+
+	close(db.gate)
+	w.Close()
+	if w.Add(AlertRecord{SID: 5}) {
+		t.Error("Add succeeded after Close")
+	}
+	if st := w.Stats(); st.Written != 3 || st.Dropped != 2 || st.Queued != 0 {
+		t.Errorf("stats %+v, want 3 written and 2 dropped", st)
+	}
+}
+
+func TestAlertWriterClose(t *testing.T) {
+	db := newBatchRepo()
+	w := NewAlertWriter(db, 100, time.Hour, time.Second, 10)
+	addN(w, 5)
+	w.Close()
+	w.Close() // twice is fine
+
+	if got := db.written(); !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("batches %v, want everything queued in one", got)
+	}
+	if st := w.Stats(); st.Written != 5 {
+		t.Errorf("stats %+v, want 5 written", st)
+	}
+
+	// A rejected batch is counted as failed, not written
+	db = newBatchRepo()
+	db.err = errors.New("disk I/O error")
+	w = NewAlertWriter(db, 100, time.Hour, time.Second, 10)
+	addN(w, 4)
+	w.Close()
+	if st := w.Stats(); st.Written != 0 || st.Failed != 4 {
+		t.Errorf("stats %+v, want 4 failed", st)
+	}
+}
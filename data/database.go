@@ -1,8 +1,11 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"math"
+	"net/netip"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -24,26 +27,48 @@ type BlockedIPDetails struct {
 	Categories    string `json:"categories"`
 	Details       string `json:"details"`
 	Timestamp     int64  `json:"timestamp"`
+	UnblockTime   int64  `json:"unblock_time,omitempty"` // 0 = permanent
 }
 
 type WhitelistDetails struct {
-	IP          string `json:"ip"`
+	IP          string `json:"ip"` // address, CIDR prefix or range
 	Description string `json:"description"`
 	AddedAt     int64  `json:"added_at"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"` // 0 = never
 }
 
-type AlertDetails struct {
-	ID        int       `json:"id"`
-	IP        string    `json:"ip"`
+// Alert as stored; Timestamp is the event time reported by the sensor
+type AlertRecord struct {
+	IP        string    `json:"ip"` // source address
+	SrcPort   int       `json:"src_port"`
+	DstIP     string    `json:"dest_ip"`
+	DstPort   int       `json:"dest_port"`
+	Proto     string    `json:"proto"`
 	SID       int       `json:"sid"`
 	Message   string    `json:"message"`
+	Severity  int       `json:"severity"`
+	Category  string    `json:"category"`
+	FlowID    uint64    `json:"flow_id"`
+	Source    string    `json:"source"` // input that raised it: suricata, snort, auth_log, nginx
 	Timestamp time.Time `json:"timestamp"`
+
+	// Suricata flow metadata, empty for other sources
+	AppProto      string `json:"app_proto,omitempty"`
+	Hostname      string `json:"hostname,omitempty"` // HTTP Host
+	SNI           string `json:"sni,omitempty"`
+	BytesToServer int64  `json:"bytes_toserver,omitempty"`
+	BytesToClient int64  `json:"bytes_toclient,omitempty"`
+}
+
+type AlertDetails struct {
+	ID int `json:"id"`
+	AlertRecord
 }
 
 type Stats struct {
 	TotalAlerts  int `json:"total_alerts"`
 	TotalBlocked int `json:"total_blocked"`
-	UniqueIPs    int `json:"unique_ips"`
+	UniqueIPs    int `json:"unique_ips"` // within retention.alerts
 }
 
 type HourlyData struct {
@@ -67,109 +92,178 @@ type Category struct {
 }
 
 type ActivityEntry struct {
-	Type      string `json:"type"` // "alert", "block", "unblock", "whitelist_add", "whitelist_remove"
+	Type      string `json:"type"` // "alert", "block", "would_block", "unblock", "whitelist_add", "whitelist_remove", "whitelist_expire", "mode_change"
 	Timestamp int64  `json:"timestamp"`
 	IP        string `json:"ip"`
 	Details   string `json:"details"` // message/reason/description
 	Extra     string `json:"extra"`   // SID dla alertów, Score dla bloków
 }
 
+// Read position of a file input, so restarts resume where they left off
+type Checkpoint struct {
+	Name      string `json:"name"`
+	Inode     uint64 `json:"inode"`
+	Offset    int64  `json:"offset"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Decision of a shadow policy; recorded, never enforced
+type ShadowDecision struct {
+	Policy    string `json:"policy"`
+	IP        string `json:"ip"`
+	Score     int    `json:"score"`
+	Reason    string `json:"reason"`
+	Details   string `json:"details"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Agreement of one shadow policy with the live decisions over a time range, per IP
+type ShadowSummary struct {
+	Policy    string `json:"policy"`
+	Decisions int    `json:"decisions"`
+	IPs       int    `json:"ips"`
+	// Decided by both / only by the shadow / only live
+	Agree      int `json:"agree"`
+	ShadowOnly int `json:"shadow_only"`
+	LiveOnly   int `json:"live_only"`
+	// agree / (agree + shadow_only + live_only), 1 when neither decided anything
+	Agreement float64 `json:"agreement"`
+	// Up to shadowSampleIPs examples of each disagreement
+	ShadowOnlyIPs []string `json:"shadow_only_ips"`
+	LiveOnlyIPs   []string `json:"live_only_ips"`
+}
+
+const shadowSampleIPs = 20
+
+// Applied on every pooled connection: WAL lets the API read while alerts are
+// written, NORMAL sync is durable enough with WAL, writers wait for each
+// other instead of failing with SQLITE_BUSY
+const connParams = "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+
 func New(path string) (Repository, error) {
-	db, err := sql.Open("sqlite", path)
+	db, err := sql.Open("sqlite", path+connParams)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS blocked_ips (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            ip TEXT NOT NULL,
-            reason TEXT,
-            score INTEGER DEFAULT 0,
-            alert_count INTEGER DEFAULT 0,
-            severity_score INTEGER DEFAULT 0,
-            unique_ports INTEGER DEFAULT 0,
-            unique_protos INTEGER DEFAULT 0,
-            unique_flows INTEGER DEFAULT 0,
-            categories TEXT,
-            details TEXT,
-            timestamp INTEGER DEFAULT (strftime('%s', 'now')),
-            unblock_time INTEGER,
-            status TEXT DEFAULT 'blocked' CHECK(status IN ('blocked', 'unblocked'))
-        );
-        
-        CREATE TABLE IF NOT EXISTS alerts (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            ip TEXT NOT NULL,
-            sid INTEGER,
-            message TEXT,
-            timestamp INTEGER DEFAULT (strftime('%s', 'now'))
-        );
-        
-        CREATE TABLE IF NOT EXISTS whitelist (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            ip TEXT NOT NULL UNIQUE,
-            description TEXT,
-            added_at INTEGER DEFAULT (strftime('%s', 'now')),
-            removed_at INTEGER DEFAULT NULL
-        );
-
-        CREATE TABLE IF NOT EXISTS activity_log (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            type TEXT NOT NULL,
-            ip TEXT NOT NULL,
-            details TEXT,
-            extra TEXT,
-            timestamp INTEGER DEFAULT (strftime('%s', 'now'))
-        );
-        
-        CREATE INDEX IF NOT EXISTS idx_blocked_ips_ip ON blocked_ips(ip);
-        CREATE INDEX IF NOT EXISTS idx_blocked_ips_status ON blocked_ips(status);
-        CREATE INDEX IF NOT EXISTS idx_alerts_ip ON alerts(ip);
-        CREATE INDEX IF NOT EXISTS idx_alerts_timestamp ON alerts(timestamp);
-        CREATE INDEX IF NOT EXISTS idx_activity_type ON activity_log(type);
-        CREATE INDEX IF NOT EXISTS idx_activity_ip ON activity_log(ip);
-        CREATE INDEX IF NOT EXISTS idx_activity_timestamp ON activity_log(timestamp);
-    `)
-
-	if err != nil {
+	if err := migrate(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return &DbManager{db: db}, nil
 }
 
-func (s *DbManager) AddBlocked(ip, reason string, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows int, categories, details string) error {
-	_, err := s.db.Exec(`
-        INSERT INTO blocked_ips (ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details) 
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-    `, ip, reason, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows, categories, details)
+// unblockTime is the planned expiry (unix seconds), 0 keeps the block permanent
+func (s *DbManager) AddBlocked(ctx context.Context, ip, reason string, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows int, categories, details string, unblockTime int64) error {
+	ip = CanonicalIP(ip)
+
+	var expiry sql.NullInt64
+	if unblockTime > 0 {
+		expiry = sql.NullInt64{Int64: unblockTime, Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+        INSERT INTO blocked_ips (ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details, unblock_time) 
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, ip, reason, score, alertCount, severityScore, uniquePorts, uniqueProtos, uniqueFlows, categories, details, expiry)
 
 	if err != nil {
 		return err
 	}
 
 	// ⬇️ DODAJ LOG
-	_ = s.LogActivity("block", ip, reason, fmt.Sprintf("%d", score))
+	_ = s.LogActivity(ctx, "block", ip, reason, fmt.Sprintf("%d", score))
 
 	return nil
 }
 
-func (s *DbManager) AddAlert(ip string, sid int, message string) error {
-	_, err := s.db.Exec(`INSERT INTO alerts (ip, sid, message) VALUES (?, ?, ?)`, ip, sid, message)
+// Columns read into AlertDetails; rows from before the full record have NULLs
+const alertColumns = `id, ip, COALESCE(src_port, 0), COALESCE(dest_ip, ''), COALESCE(dest_port, 0),
+        COALESCE(proto, ''), sid, message, COALESCE(severity, 0), COALESCE(category, ''),
+        COALESCE(flow_id, 0), COALESCE(source, 'suricata'), timestamp,
+        COALESCE(app_proto, ''), COALESCE(hostname, ''), COALESCE(sni, ''),
+        COALESCE(bytes_toserver, 0), COALESCE(bytes_toclient, 0)`
+
+const insertAlert = `INSERT INTO alerts (ip, src_port, dest_ip, dest_port, proto, sid, message, severity, category, flow_id, source, timestamp,
+            app_proto, hostname, sni, bytes_toserver, bytes_toclient)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func scanAlert(rows *sql.Rows) (AlertDetails, error) {
+	var a AlertDetails
+	var flowID, timestamp int64
+	err := rows.Scan(&a.ID, &a.IP, &a.SrcPort, &a.DstIP, &a.DstPort, &a.Proto, &a.SID, &a.Message,
+		&a.Severity, &a.Category, &flowID, &a.Source, &timestamp,
+		&a.AppProto, &a.Hostname, &a.SNI, &a.BytesToServer, &a.BytesToClient)
+	a.FlowID = uint64(flowID)
+	a.Timestamp = time.Unix(timestamp, 0)
+	return a, err
+}
+
+// Insert arguments; flow_id is stored as its int64 bit pattern (SQLite integers are signed)
+func alertArgs(a AlertRecord) []any {
+	ts := a.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return []any{CanonicalIP(a.IP), a.SrcPort, a.DstIP, a.DstPort, a.Proto, a.SID, a.Message,
+		a.Severity, a.Category, int64(a.FlowID), a.Source, ts.Unix(),
+		a.AppProto, a.Hostname, a.SNI, a.BytesToServer, a.BytesToClient}
+}
+
+func (s *DbManager) AddAlert(ctx context.Context, a AlertRecord) error {
+	return s.AddAlerts(ctx, []AlertRecord{a})
+}
+
+// Live alerts and their activity rows in one transaction
+func (s *DbManager) AddAlerts(ctx context.Context, alerts []AlertRecord) error {
+	return s.insertAlerts(ctx, alerts, true)
+}
+
+// Bulk insert of historical alerts (replay/backfill) in one transaction,
+// keeping their original timestamps; the activity log is left alone
+func (s *DbManager) ImportAlerts(ctx context.Context, alerts []AlertRecord) error {
+	return s.insertAlerts(ctx, alerts, false)
+}
+
+func (s *DbManager) insertAlerts(ctx context.Context, alerts []AlertRecord, logActivity bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// ⬇️ DODAJ LOG
-	_ = s.LogActivity("alert", ip, message, fmt.Sprintf("%d", sid))
+	stmt, err := tx.PrepareContext(ctx, insertAlert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
 
-	return nil
+	var activity *sql.Stmt
+	if logActivity {
+		activity, err = tx.PrepareContext(ctx, insertActivity)
+		if err != nil {
+			return err
+		}
+		defer activity.Close()
+	}
+
+	for _, a := range alerts {
+		if _, err := stmt.ExecContext(ctx, alertArgs(a)...); err != nil {
+			return err
+		}
+		if activity != nil {
+			if _, err := activity.ExecContext(ctx, "alert", CanonicalIP(a.IP), a.Message, fmt.Sprintf("%d", a.SID)); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
 }
 
-func (s *DbManager) GetBlocked() ([]BlockedIPDetails, error) {
-	rows, err := s.db.Query(`
-        SELECT ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details, timestamp 
+func (s *DbManager) GetBlocked(ctx context.Context) ([]BlockedIPDetails, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details, timestamp, unblock_time 
         FROM blocked_ips 
         WHERE status='blocked'
         ORDER BY timestamp DESC
@@ -179,28 +273,52 @@ func (s *DbManager) GetBlocked() ([]BlockedIPDetails, error) {
 	}
 	defer rows.Close()
 
+	return scanBlocked(rows)
+}
+
+// Active blocks whose unblock_time has passed
+func (s *DbManager) GetExpiredBlocks(ctx context.Context, now int64) ([]BlockedIPDetails, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details, timestamp, unblock_time
+        FROM blocked_ips
+        WHERE status='blocked' AND unblock_time IS NOT NULL AND unblock_time <= ?
+        ORDER BY unblock_time ASC
+    `, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBlocked(rows)
+}
+
+func scanBlocked(rows *sql.Rows) ([]BlockedIPDetails, error) {
 	var ips []BlockedIPDetails
 	for rows.Next() {
 		var ip BlockedIPDetails
+		var unblockTime sql.NullInt64
 		if err := rows.Scan(&ip.IP, &ip.Reason, &ip.Score, &ip.AlertCount, &ip.SeverityScore,
 			&ip.UniquePorts, &ip.UniqueProtos, &ip.UniqueFlows,
-			&ip.Categories, &ip.Details, &ip.Timestamp); err != nil {
+			&ip.Categories, &ip.Details, &ip.Timestamp, &unblockTime); err != nil {
 			return nil, err
 		}
+		ip.UnblockTime = unblockTime.Int64
 		ips = append(ips, ip)
 	}
 
 	return ips, rows.Err()
 }
 
-func (s *DbManager) UnblockIP(ip string) error {
+func (s *DbManager) UnblockIP(ctx context.Context, ip string) error {
+	ip = CanonicalIP(ip)
+
 	now := time.Now().Unix()
 
 	// ⬇️ Pobierz reason przed update
 	var reason string
-	s.db.QueryRow("SELECT reason FROM blocked_ips WHERE ip = ? AND status='blocked' LIMIT 1", ip).Scan(&reason)
+	s.db.QueryRowContext(ctx, "SELECT reason FROM blocked_ips WHERE ip = ? AND status='blocked' LIMIT 1", ip).Scan(&reason)
 
-	result, err := s.db.Exec(`
+	result, err := s.db.ExecContext(ctx, `
         UPDATE blocked_ips 
         SET status='unblocked', unblock_time=? 
         WHERE ip=? AND status='blocked'
@@ -220,13 +338,15 @@ func (s *DbManager) UnblockIP(ip string) error {
 	}
 
 	// ⬇️ DODAJ LOG
-	_ = s.LogActivity("unblock", ip, reason, "")
+	_ = s.LogActivity(ctx, "unblock", ip, reason, "")
 
 	return nil
 }
 
-func (s *DbManager) IsBlocked(ip string) (bool, error) {
-	row := s.db.QueryRow(`
+func (s *DbManager) IsBlocked(ctx context.Context, ip string) (bool, error) {
+	ip = CanonicalIP(ip)
+
+	row := s.db.QueryRowContext(ctx, `
         SELECT COUNT(1) 
         FROM blocked_ips 
         WHERE ip=? AND status='blocked'
@@ -240,9 +360,11 @@ func (s *DbManager) IsBlocked(ip string) (bool, error) {
 	return count > 0, nil
 }
 
-func (s *DbManager) GetAlertsByIP(ip string, limit int) ([]AlertDetails, error) {
-	rows, err := s.db.Query(`
-        SELECT id, ip, sid, message, timestamp
+func (s *DbManager) GetAlertsByIP(ctx context.Context, ip string, limit int) ([]AlertDetails, error) {
+	ip = CanonicalIP(ip)
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+alertColumns+`
         FROM alerts
         WHERE ip=?
         ORDER BY timestamp DESC
@@ -256,15 +378,10 @@ func (s *DbManager) GetAlertsByIP(ip string, limit int) ([]AlertDetails, error)
 
 	var alerts []AlertDetails
 	for rows.Next() {
-		var a AlertDetails
-		var timestamp int64
-
-		err := rows.Scan(&a.ID, &a.IP, &a.SID, &a.Message, &timestamp)
+		a, err := scanAlert(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		a.Timestamp = time.Unix(timestamp, 0)
 		alerts = append(alerts, a)
 	}
 
@@ -275,9 +392,11 @@ func (s *DbManager) GetAlertsByIP(ip string, limit int) ([]AlertDetails, error)
 	return alerts, nil
 }
 
-func (s *DbManager) GetBlockedByIP(ip string) ([]BlockedIPDetails, error) {
-	rows, err := s.db.Query(`
-        SELECT ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details, timestamp
+func (s *DbManager) GetBlockedByIP(ctx context.Context, ip string) ([]BlockedIPDetails, error) {
+	ip = CanonicalIP(ip)
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details, timestamp, unblock_time
         FROM blocked_ips
         WHERE ip = ?
         ORDER BY timestamp DESC
@@ -287,54 +406,78 @@ func (s *DbManager) GetBlockedByIP(ip string) ([]BlockedIPDetails, error) {
 	}
 	defer rows.Close()
 
-	var blocks []BlockedIPDetails
-	for rows.Next() {
-		var b BlockedIPDetails
-		if err := rows.Scan(&b.IP, &b.Reason, &b.Score, &b.AlertCount, &b.SeverityScore,
-			&b.UniquePorts, &b.UniqueProtos, &b.UniqueFlows,
-			&b.Categories, &b.Details, &b.Timestamp); err != nil {
-			return nil, err
-		}
-		blocks = append(blocks, b)
+	return scanBlocked(rows)
+}
+
+// Blocks made with from <= timestamp < to (unix seconds, 0 = open), oldest first
+func (s *DbManager) GetBlocksBetween(ctx context.Context, from, to int64) ([]BlockedIPDetails, error) {
+	if to <= 0 {
+		to = math.MaxInt64
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT ip, reason, score, alert_count, severity_score, unique_ports, unique_protos, unique_flows, categories, details, timestamp, unblock_time
+        FROM blocked_ips
+        WHERE timestamp >= ? AND timestamp < ?
+        ORDER BY timestamp ASC
+    `, from, to)
+	if err != nil {
+		return nil, err
 	}
-	return blocks, nil
+	defer rows.Close()
+
+	return scanBlocked(rows)
 }
 
-func (s *DbManager) AddToWhitelist(ip, description string) error {
+// ip may be an address, CIDR prefix or range; expiresAt (unix seconds) 0 = never
+func (s *DbManager) AddToWhitelist(ctx context.Context, ip, description string, expiresAt int64) error {
+	ip, _, err := ParseWhitelistEntry(ip)
+	if err != nil {
+		return err
+	}
+
+	var expiry sql.NullInt64
+	if expiresAt > 0 {
+		expiry = sql.NullInt64{Int64: expiresAt, Valid: true}
+	}
+
 	// Sprawdź czy IP był wcześniej
 	var existingID int
-	err := s.db.QueryRow(`SELECT id FROM whitelist WHERE ip = ?`, ip).Scan(&existingID)
+	err = s.db.QueryRowContext(ctx, `SELECT id FROM whitelist WHERE ip = ?`, ip).Scan(&existingID)
 
 	if err == sql.ErrNoRows {
 		// Nowy wpis
-		_, err = s.db.Exec(`INSERT INTO whitelist (ip, description) VALUES (?, ?)`, ip, description)
+		_, err = s.db.ExecContext(ctx, `INSERT INTO whitelist (ip, description, expires_at) VALUES (?, ?, ?)`, ip, description, expiry)
 		if err != nil {
 			return err
 		}
 	} else {
 		// IP istnieje - reaktywuj
-		_, err = s.db.Exec(`
+		_, err = s.db.ExecContext(ctx, `
             UPDATE whitelist 
-            SET removed_at = NULL, description = ?, added_at = strftime('%s', 'now')
+            SET removed_at = NULL, description = ?, expires_at = ?, added_at = strftime('%s', 'now')
             WHERE ip = ?
-        `, description, ip)
+        `, description, expiry, ip)
 		if err != nil {
 			return err
 		}
 	}
 
 	// ⬇️ DODAJ LOG
-	_ = s.LogActivity("whitelist_add", ip, description, "")
+	_ = s.LogActivity(ctx, "whitelist_add", ip, description, "")
 
 	return nil
 }
 
-func (s *DbManager) RemoveFromWhitelist(ip string) error {
+func (s *DbManager) RemoveFromWhitelist(ctx context.Context, ip string) error {
+	if canonical, _, err := ParseWhitelistEntry(ip); err == nil {
+		ip = canonical
+	}
+
 	// ⬇️ Pobierz description przed soft-delete
 	var description string
-	s.db.QueryRow("SELECT description FROM whitelist WHERE ip = ? AND removed_at IS NULL", ip).Scan(&description)
+	s.db.QueryRowContext(ctx, "SELECT description FROM whitelist WHERE ip = ? AND removed_at IS NULL", ip).Scan(&description)
 
-	_, err := s.db.Exec(`
+	_, err := s.db.ExecContext(ctx, `
         UPDATE whitelist 
         SET removed_at = strftime('%s', 'now')
         WHERE ip = ? AND removed_at IS NULL
@@ -345,31 +488,86 @@ func (s *DbManager) RemoveFromWhitelist(ip string) error {
 	}
 
 	// ⬇️ DODAJ LOG
-	_ = s.LogActivity("whitelist_remove", ip, description, "")
+	_ = s.LogActivity(ctx, "whitelist_remove", ip, description, "")
 
 	return nil
 }
 
-func (s *DbManager) IsWhitelisted(ip string) (bool, error) {
-	row := s.db.QueryRow(`
-        SELECT COUNT(1) 
-        FROM whitelist 
-        WHERE ip = ? AND removed_at IS NULL
-    `, ip)
-	var count int
-	if err := row.Scan(&count); err != nil {
+// Soft-deleting entries whose expires_at has passed, returns the lifted entries
+func (s *DbManager) ExpireWhitelist(ctx context.Context, now int64) ([]string, error) {
+	items, err := s.queryWhitelist(ctx, `
+        SELECT ip, description, added_at, expires_at
+        FROM whitelist
+        WHERE removed_at IS NULL AND expires_at IS NOT NULL AND expires_at <= ?
+    `, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	for _, item := range items {
+		if _, err := s.db.ExecContext(ctx, `
+            UPDATE whitelist
+            SET removed_at = expires_at
+            WHERE ip = ? AND removed_at IS NULL
+        `, item.IP); err != nil {
+			return expired, err
+		}
+
+		_ = s.LogActivity(ctx, "whitelist_expire", item.IP, item.Description, "")
+		expired = append(expired, item.IP)
+	}
+
+	return expired, nil
+}
+
+// Matching ip against every active entry, including CIDR prefixes and ranges
+func (s *DbManager) IsWhitelisted(ctx context.Context, ip string) (bool, error) {
+	target, err := netip.ParseAddr(CanonicalIP(ip))
+	if err != nil {
+		// Not a single address (e.g. prefix key), only exact entries can match
+		row := s.db.QueryRowContext(ctx, `
+            SELECT COUNT(1) 
+            FROM whitelist 
+            WHERE ip = ? AND removed_at IS NULL AND (expires_at IS NULL OR expires_at > strftime('%s', 'now'))
+        `, CanonicalIP(ip))
+		var count int
+		if err := row.Scan(&count); err != nil {
+			return false, err
+		}
+		return count > 0, nil
+	}
+
+	items, err := s.GetWhitelistDetails(ctx)
+	if err != nil {
 		return false, err
 	}
-	return count > 0, nil
+
+	for _, item := range items {
+		_, prefixes, err := ParseWhitelistEntry(item.IP)
+		if err != nil {
+			continue
+		}
+		for _, p := range prefixes {
+			if p.Contains(target) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
 }
 
-func (s *DbManager) GetWhitelistDetails() ([]WhitelistDetails, error) {
-	rows, err := s.db.Query(`
-        SELECT ip, description, added_at 
+func (s *DbManager) GetWhitelistDetails(ctx context.Context) ([]WhitelistDetails, error) {
+	return s.queryWhitelist(ctx, `
+        SELECT ip, description, added_at, expires_at 
         FROM whitelist 
-        WHERE removed_at IS NULL
+        WHERE removed_at IS NULL AND (expires_at IS NULL OR expires_at > strftime('%s', 'now'))
         ORDER BY added_at DESC
     `)
+}
+
+func (s *DbManager) queryWhitelist(ctx context.Context, query string, args ...interface{}) ([]WhitelistDetails, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -378,29 +576,39 @@ func (s *DbManager) GetWhitelistDetails() ([]WhitelistDetails, error) {
 	var items []WhitelistDetails
 	for rows.Next() {
 		var item WhitelistDetails
-		if err := rows.Scan(&item.IP, &item.Description, &item.AddedAt); err != nil {
+		var description sql.NullString
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&item.IP, &description, &item.AddedAt, &expiresAt); err != nil {
 			return nil, err
 		}
+		item.Description = description.String
+		item.ExpiresAt = expiresAt.Int64
 		items = append(items, item)
 	}
 
 	return items, rows.Err()
 }
 
-func (s *DbManager) GetStats() (*Stats, error) {
+func (s *DbManager) GetStats(ctx context.Context) (*Stats, error) {
 	stats := &Stats{}
 
-	err := s.db.QueryRow("SELECT COUNT(*) FROM alerts").Scan(&stats.TotalAlerts)
+	// Purged alerts still count through the rollups
+	err := s.db.QueryRowContext(ctx, `
+        SELECT
+            (SELECT COALESCE(SUM(alerts), 0) FROM rollups_hourly) +
+            (SELECT COALESCE(SUM(alerts), 0) FROM rollups_daily) +
+            (SELECT COUNT(*) FROM alerts WHERE id > `+rollupMark("alerts")+`)`).Scan(&stats.TotalAlerts)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.db.QueryRow("SELECT COUNT(*) FROM blocked_ips WHERE status='blocked'").Scan(&stats.TotalBlocked)
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM blocked_ips WHERE status='blocked'").Scan(&stats.TotalBlocked)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.db.QueryRow("SELECT COUNT(DISTINCT ip) FROM alerts").Scan(&stats.UniqueIPs)
+	// Not rolled up: only sources of the alerts retention still keeps
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(DISTINCT ip) FROM alerts").Scan(&stats.UniqueIPs)
 	if err != nil {
 		return nil, err
 	}
@@ -408,16 +616,25 @@ func (s *DbManager) GetStats() (*Stats, error) {
 	return stats, nil
 }
 
-func (s *DbManager) GetHourlyAlerts(days int) ([]HourlyData, error) {
-	rows, err := s.db.Query(`
-        SELECT 
-            strftime('%Y-%m-%d %H:00', datetime(timestamp, 'unixepoch')) as hour,
-            COUNT(*) as count
-        FROM alerts 
-        WHERE timestamp > (strftime('%s','now',?))
-        GROUP BY hour 
+func (s *DbManager) GetHourlyAlerts(ctx context.Context, days int) ([]HourlyData, error) {
+	// Hours still in rollups_hourly plus alerts not rolled up yet, in local
+	// time like the other buckets and the daily rollups
+	cutoff := fmt.Sprintf("-%d days", days)
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT hour, SUM(n) as count
+        FROM (
+            SELECT strftime('%Y-%m-%d %H:00', datetime(start, 'unixepoch', 'localtime')) as hour, alerts as n
+            FROM rollups_hourly
+            WHERE start > (strftime('%s','now',?)) - 3600
+            UNION ALL
+            SELECT strftime('%Y-%m-%d %H:00', datetime(timestamp, 'unixepoch', 'localtime')), 1
+            FROM alerts
+            WHERE id > `+rollupMark("alerts")+` AND timestamp > (strftime('%s','now',?))
+        )
+        GROUP BY hour
+        HAVING count > 0
         ORDER BY hour DESC
-        LIMIT 168`, fmt.Sprintf("-%d days", days))
+        LIMIT 168`, cutoff, cutoff)
 	if err != nil {
 		return nil, err
 	}
@@ -435,8 +652,9 @@ func (s *DbManager) GetHourlyAlerts(days int) ([]HourlyData, error) {
 	return data, rows.Err()
 }
 
-func (s *DbManager) GetTopIPs(limit int) ([]TopIP, error) {
-	rows, err := s.db.Query(`
+// Counted over the raw alerts, so only as far back as retention.alerts
+func (s *DbManager) GetTopIPs(ctx context.Context, limit int) ([]TopIP, error) {
+	rows, err := s.db.QueryContext(ctx, `
         SELECT ip, COUNT(*) as count 
         FROM alerts 
         GROUP BY ip 
@@ -459,10 +677,10 @@ func (s *DbManager) GetTopIPs(limit int) ([]TopIP, error) {
 	return ips, rows.Err()
 }
 
-func (s *DbManager) GetAlertCategories(days int) ([]Category, error) {
-	rows, err := s.db.Query(`
+func (s *DbManager) GetAlertCategories(ctx context.Context, days int) ([]Category, error) {
+	rows, err := s.db.QueryContext(ctx, `
         SELECT 
-            substr(message,1,50) as category,
+            COALESCE(NULLIF(category, ''), substr(message,1,50)) as category,
             COUNT(*) as count
         FROM alerts 
         WHERE timestamp > (strftime('%s','now',?))
@@ -486,9 +704,9 @@ func (s *DbManager) GetAlertCategories(days int) ([]Category, error) {
 	return cats, rows.Err()
 }
 
-func (s *DbManager) GetRecentAlerts(limit int) ([]AlertDetails, error) {
-	rows, err := s.db.Query(`
-        SELECT id, ip, sid, message, timestamp
+func (s *DbManager) GetRecentAlerts(ctx context.Context, limit int) ([]AlertDetails, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+alertColumns+`
         FROM alerts
         ORDER BY timestamp DESC
         LIMIT ?`, limit)
@@ -499,20 +717,45 @@ func (s *DbManager) GetRecentAlerts(limit int) ([]AlertDetails, error) {
 
 	var alerts []AlertDetails
 	for rows.Next() {
-		var a AlertDetails
-		var timestamp int64
-		err := rows.Scan(&a.ID, &a.IP, &a.SID, &a.Message, &timestamp)
+		a, err := scanAlert(rows)
 		if err != nil {
 			return nil, err
 		}
-		a.Timestamp = time.Unix(timestamp, 0)
 		alerts = append(alerts, a)
 	}
 
 	return alerts, rows.Err()
 }
 
-func (s *DbManager) GetAlertBuckets(days int) ([]TimeBucket, error) {
+// Streaming alerts with from <= timestamp < to (unix seconds, 0 = open) in
+// timestamp order, without loading the whole range into memory
+func (s *DbManager) ScanAlerts(ctx context.Context, from, to int64, fn func(AlertDetails) error) error {
+	if to <= 0 {
+		to = math.MaxInt64
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+alertColumns+`
+        FROM alerts
+        WHERE timestamp >= ? AND timestamp < ?
+        ORDER BY timestamp ASC, id ASC`, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *DbManager) GetAlertBuckets(ctx context.Context, days int) ([]TimeBucket, error) {
 	var format string
 	if days <= 1 {
 		format = "%Y-%m-%d %H:00"
@@ -522,16 +765,7 @@ func (s *DbManager) GetAlertBuckets(days int) ([]TimeBucket, error) {
 
 	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour).Unix()
 
-	rows, err := s.db.Query(fmt.Sprintf(`
-        SELECT 
-            strftime('%s', datetime(timestamp, 'unixepoch', 'localtime')) AS bucket,
-            COUNT(*) AS count
-        FROM alerts
-        WHERE timestamp > ?
-        GROUP BY bucket
-        ORDER BY bucket ASC
-    `, format), cutoff)
-
+	rows, err := s.rollupBuckets(ctx, "alerts", "alerts", format, cutoff)
 	if err != nil {
 		return nil, err
 	}
@@ -548,7 +782,7 @@ func (s *DbManager) GetAlertBuckets(days int) ([]TimeBucket, error) {
 	return out, rows.Err()
 }
 
-func (s *DbManager) GetBlockBuckets(days int) ([]TimeBucket, error) {
+func (s *DbManager) GetBlockBuckets(ctx context.Context, days int) ([]TimeBucket, error) {
 	var format string
 	if days <= 1 {
 		format = "%Y-%m-%d %H:00"
@@ -558,17 +792,7 @@ func (s *DbManager) GetBlockBuckets(days int) ([]TimeBucket, error) {
 
 	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour).Unix()
 
-	query := fmt.Sprintf(`
-        SELECT 
-            strftime('%s', datetime(timestamp, 'unixepoch', 'localtime')) AS bucket,
-            COUNT(*) AS count
-        FROM blocked_ips
-        WHERE timestamp > ?
-        GROUP BY bucket
-        ORDER BY bucket ASC
-    `, format)
-
-	rows, err := s.db.Query(query, cutoff)
+	rows, err := s.rollupBuckets(ctx, "blocked_ips", "blocks", format, cutoff)
 	if err != nil {
 		return nil, err
 	}
@@ -586,7 +810,7 @@ func (s *DbManager) GetBlockBuckets(days int) ([]TimeBucket, error) {
 	return out, rows.Err()
 }
 
-func (s *DbManager) GetActivity(search string, typeFilter string, limit int) ([]ActivityEntry, error) {
+func (s *DbManager) GetActivity(ctx context.Context, search string, typeFilter string, limit int) ([]ActivityEntry, error) {
 	query := "SELECT type, timestamp, ip, details, extra FROM activity_log WHERE 1=1"
 	args := []interface{}{}
 
@@ -604,7 +828,7 @@ func (s *DbManager) GetActivity(search string, typeFilter string, limit int) ([]
 	query += " ORDER BY timestamp DESC LIMIT ?"
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -622,11 +846,154 @@ func (s *DbManager) GetActivity(search string, typeFilter string, limit int) ([]
 	return entries, rows.Err()
 }
 
-func (s *DbManager) LogActivity(activityType, ip, details, extra string) error {
-	_, err := s.db.Exec(`
-        INSERT INTO activity_log (type, ip, details, extra) 
-        VALUES (?, ?, ?, ?)
-    `, activityType, ip, details, extra)
+const insertActivity = `INSERT INTO activity_log (type, ip, details, extra) VALUES (?, ?, ?, ?)`
+
+func (s *DbManager) LogActivity(ctx context.Context, activityType, ip, details, extra string) error {
+	ip = CanonicalIP(ip)
+
+	_, err := s.db.ExecContext(ctx, insertActivity, activityType, ip, details, extra)
+	return err
+}
+
+// Recording what a shadow policy would have blocked; Timestamp 0 = now
+func (s *DbManager) AddShadowDecision(ctx context.Context, d ShadowDecision) error {
+	ts := d.Timestamp
+	if ts == 0 {
+		ts = time.Now().Unix()
+	}
+	_, err := s.db.ExecContext(ctx, `
+        INSERT INTO shadow_decisions (policy, ip, score, reason, details, timestamp)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, d.Policy, CanonicalIP(d.IP), d.Score, d.Reason, d.Details, ts)
+	return err
+}
+
+// Comparing shadow policies with the live decisions (blocks and monitor-mode
+// would_block) made with from <= timestamp < to (unix seconds, 0 = open).
+// Every listed policy is reported, plus any other policy found in the range.
+func (s *DbManager) GetShadowSummary(ctx context.Context, policies []string, from, to int64) ([]ShadowSummary, error) {
+	if to <= 0 {
+		to = math.MaxInt64
+	}
+
+	var liveIPs []string
+	live := make(map[string]bool)
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT ip FROM blocked_ips WHERE timestamp >= ? AND timestamp < ?
+        UNION
+        SELECT ip FROM activity_log WHERE type = 'would_block' AND timestamp >= ? AND timestamp < ?
+        ORDER BY ip
+    `, from, to, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		liveIPs = append(liveIPs, ip)
+		live[ip] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	summaries := make([]ShadowSummary, 0, len(policies))
+	index := make(map[string]int)
+	decided := make(map[string]map[string]bool)
+	add := func(policy string) *ShadowSummary {
+		if i, ok := index[policy]; ok {
+			return &summaries[i]
+		}
+		index[policy] = len(summaries)
+		decided[policy] = make(map[string]bool)
+		summaries = append(summaries, ShadowSummary{Policy: policy, ShadowOnlyIPs: []string{}, LiveOnlyIPs: []string{}})
+		return &summaries[len(summaries)-1]
+	}
+	for _, p := range policies {
+		add(p)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+        SELECT policy, ip, COUNT(*)
+        FROM shadow_decisions
+        WHERE timestamp >= ? AND timestamp < ?
+        GROUP BY policy, ip
+        ORDER BY policy, MIN(timestamp)
+    `, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var policy, ip string
+		var count int
+		if err := rows.Scan(&policy, &ip, &count); err != nil {
+			return nil, err
+		}
+		sum := add(policy)
+		decided[policy][ip] = true
+
+		sum.Decisions += count
+		sum.IPs++
+		if live[ip] {
+			sum.Agree++
+		} else {
+			sum.ShadowOnly++
+			if len(sum.ShadowOnlyIPs) < shadowSampleIPs {
+				sum.ShadowOnlyIPs = append(sum.ShadowOnlyIPs, ip)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range summaries {
+		sum := &summaries[i]
+		for _, ip := range liveIPs {
+			if decided[sum.Policy][ip] {
+				continue
+			}
+			sum.LiveOnly++
+			if len(sum.LiveOnlyIPs) < shadowSampleIPs {
+				sum.LiveOnlyIPs = append(sum.LiveOnlyIPs, ip)
+			}
+		}
+		total := sum.Agree + sum.ShadowOnly + sum.LiveOnly
+		sum.Agreement = 1
+		if total > 0 {
+			sum.Agreement = float64(sum.Agree) / float64(total)
+		}
+	}
+	return summaries, nil
+}
+
+// Returns the saved position, or a zero Checkpoint (Offset 0) when there is none
+func (s *DbManager) GetCheckpoint(ctx context.Context, name string) (Checkpoint, error) {
+	cp := Checkpoint{Name: name}
+	err := s.db.QueryRowContext(ctx, `
+        SELECT inode, offset, updated_at FROM input_checkpoints WHERE name = ?
+    `, name).Scan(&cp.Inode, &cp.Offset, &cp.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return cp, nil
+	}
+	return cp, err
+}
+
+func (s *DbManager) SaveCheckpoint(ctx context.Context, cp Checkpoint) error {
+	_, err := s.db.ExecContext(ctx, `
+        INSERT INTO input_checkpoints (name, inode, offset, updated_at)
+        VALUES (?, ?, ?, strftime('%s', 'now'))
+        ON CONFLICT(name) DO UPDATE SET
+            inode = excluded.inode,
+            offset = excluded.offset,
+            updated_at = excluded.updated_at
+    `, cp.Name, int64(cp.Inode), cp.Offset)
 	return err
 }
 
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Up migrations, NNNN_name.sql, applied in version order and never edited
+// once released; a schema change is a new file
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// State of one migration in a database, for `firefighter migrate status`
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt int64 // 0 = pending
+}
+
+func loadMigrations() ([]migration, error) {
+	paths, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, path := range paths {
+		base := strings.TrimSuffix(strings.TrimPrefix(path, "migrations/"), ".sql")
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.sql", path)
+		}
+		content, err := migrationFiles.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %04d_%s: versions must be consecutive from 1", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Bringing the schema up to date in a single transaction; a failure leaves
+// the database as it was
+func migrate(db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Tables from before schema_version (ensureColumn era) may already have
+	// some of the columns later migrations add
+	adopting, err := tableExists(tx, "alerts")
+	if err != nil {
+		return err
+	}
+	if exists, err := tableExists(tx, "schema_version"); err != nil {
+		return err
+	} else if exists {
+		adopting = false
+	}
+
+	_, err = tx.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_version (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at INTEGER DEFAULT (strftime('%s', 'now'))
+        )`)
+	if err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(tx)
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this build (%d), refusing to start", current, len(migrations))
+	}
+
+	for _, m := range migrations[current:] {
+		for _, stmt := range splitStatements(m.SQL) {
+			if _, err := tx.Exec(stmt); err != nil {
+				if adopting && strings.Contains(err.Error(), "duplicate column name") {
+					continue
+				}
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func schemaVersion(tx *sql.Tx) (int, error) {
+	var version int
+	err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+func tableExists(tx *sql.Tx, name string) (bool, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	return count > 0, err
+}
+
+// Statements end with ";" at the end of a line; "--" lines are comments
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, current.String())
+			current.Reset()
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		stmts = append(stmts, current.String())
+	}
+	return stmts
+}
+
+// Migrations known to this build and whether the database at path has them;
+// the database is only read
+func Migrations(path string) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	applied := make(map[int]int64)
+	rows, err := db.Query(`SELECT version, COALESCE(applied_at, 0) FROM schema_version`)
+	if err != nil && !strings.Contains(err.Error(), "no such table") {
+		return nil, err
+	}
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var version int
+			var at int64
+			if err := rows.Scan(&version, &at); err != nil {
+				return nil, err
+			}
+			applied[version] = at
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{Version: m.Version, Name: m.Name, AppliedAt: applied[m.Version]})
+	}
+	// Applied by a newer build
+	for version, at := range applied {
+		if version > len(migrations) {
+			status = append(status, MigrationStatus{Version: version, Name: "(unknown)", AppliedAt: at})
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Schema the service created before schema_version existed; ensureColumn
+// added expires_at and source on start, depending on the build
+const legacySchema = `
+CREATE TABLE blocked_ips (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip TEXT NOT NULL,
+    reason TEXT,
+    score INTEGER DEFAULT 0,
+    alert_count INTEGER DEFAULT 0,
+    severity_score INTEGER DEFAULT 0,
+    unique_ports INTEGER DEFAULT 0,
+    unique_protos INTEGER DEFAULT 0,
+    unique_flows INTEGER DEFAULT 0,
+    categories TEXT,
+    details TEXT,
+    timestamp INTEGER DEFAULT (strftime('%s', 'now')),
+    unblock_time INTEGER,
+    status TEXT DEFAULT 'blocked' CHECK(status IN ('blocked', 'unblocked'))
+);
+CREATE TABLE alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip TEXT NOT NULL,
+    sid INTEGER,
+    message TEXT,
+    timestamp INTEGER DEFAULT (strftime('%s', 'now'))
+);
+CREATE TABLE whitelist (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip TEXT NOT NULL UNIQUE,
+    description TEXT,
+    added_at INTEGER DEFAULT (strftime('%s', 'now')),
+    removed_at INTEGER DEFAULT NULL
+);
+CREATE TABLE activity_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    details TEXT,
+    extra TEXT,
+    timestamp INTEGER DEFAULT (strftime('%s', 'now'))
+);
+INSERT INTO alerts (ip, sid, message, timestamp) VALUES ('198.51.100.7', 2010935, 'legacy alert', 1700000000);
+INSERT INTO whitelist (ip, description) VALUES ('10.0.0.0/8', 'lan');
+`
+
+func execScript(t *testing.T, path, script string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range splitStatements(script) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+func columns(t *testing.T, db *sql.DB, table string) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		cols = append(cols, name)
+	}
+	return cols
+}
+
+func TestMigrate(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := len(migrations)
+
+	tests := []struct {
+		name    string
+		setup   string // run on the empty file before New
+		opens   int
+		alerts  int // rows expected in alerts afterwards
+		wantErr string
+	}{
+		{name: "fresh database", opens: 1},
+		{name: "reopen is a no-op", opens: 3},
+		{name: "legacy database", setup: legacySchema, opens: 1, alerts: 1},
+		{name: "legacy database with ensureColumn columns", setup: legacySchema + `
+ALTER TABLE whitelist ADD COLUMN expires_at INTEGER DEFAULT NULL;
+ALTER TABLE alerts ADD COLUMN source TEXT DEFAULT 'suricata';
+`, opens: 2, alerts: 1},
+		{name: "newer schema", setup: `
+CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at INTEGER);
+INSERT INTO schema_version (version, name) VALUES (999, 'future');
+`, opens: 1, wantErr: "newer than this build"},
+		{name: "duplicate column outside adoption fails", setup: `
+CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at INTEGER);
+INSERT INTO schema_version (version, name) VALUES (1, 'initial');
+` + legacySchema + `
+ALTER TABLE whitelist ADD COLUMN expires_at INTEGER DEFAULT NULL;
+`, opens: 1, wantErr: "0002_whitelist_expiry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "firefighter.db")
+			if tt.setup != "" {
+				execScript(t, path, tt.setup)
+			}
+
+			for i := 0; i < tt.opens; i++ {
+				repo, err := New(path)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("New() error = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("open %d: %v", i+1, err)
+				}
+				repo.Close()
+			}
+
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			var version, rows int
+			if err := db.QueryRow(`SELECT MAX(version), COUNT(*) FROM schema_version`).Scan(&version, &rows); err != nil {
+				t.Fatal(err)
+			}
+			if version != latest || rows != latest {
+				t.Errorf("schema_version max %d with %d rows, want %d", version, rows, latest)
+			}
+
+			cols := columns(t, db, "alerts")
+			for _, want := range []string{"source", "src_port", "dest_ip", "flow_id", "hostname", "sni", "bytes_toserver"} {
+				if !slices.Contains(cols, want) {
+					t.Errorf("alerts has no %s column: %v", want, cols)
+				}
+			}
+			if !slices.Contains(columns(t, db, "whitelist"), "expires_at") {
+				t.Error("whitelist has no expires_at column")
+			}
+			for _, table := range []string{"input_checkpoints", "shadow_decisions", "rollups_hourly", "rollup_state"} {
+				if len(columns(t, db, table)) == 0 {
+					t.Errorf("table %s missing", table)
+				}
+			}
+
+			var alerts int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM alerts`).Scan(&alerts); err != nil {
+				t.Fatal(err)
+			}
+			if alerts != tt.alerts {
+				t.Errorf("%d alerts, want %d", alerts, tt.alerts)
+			}
+			if tt.alerts > 0 {
+				var source string
+				if err := db.QueryRow(`SELECT source FROM alerts`).Scan(&source); err != nil {
+					t.Fatal(err)
+				}
+				if source != "suricata" {
+					t.Errorf("legacy alert source %q, want suricata", source)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrationsStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "firefighter.db")
+	execScript(t, path, legacySchema)
+
+	status, err := Migrations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.AppliedAt != 0 {
+			t.Errorf("migration %d applied before New", s.Version)
+		}
+	}
+
+	repo, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	status, err = Migrations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range status {
+		if s.Version != i+1 || s.AppliedAt == 0 {
+			t.Errorf("status[%d] = %+v, want version %d applied", i, s, i+1)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment
+CREATE TABLE a (
+    x INTEGER -- trailing
+);
+
+-- another
+INSERT INTO a VALUES (1);
+SELECT 1`
+	got := splitStatements(script)
+	if len(got) != 3 || !strings.HasPrefix(got[0], "CREATE TABLE a") || !strings.HasPrefix(got[2], "SELECT 1") {
+		t.Errorf("splitStatements = %q", got)
+	}
+}
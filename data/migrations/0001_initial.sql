@@ -0,0 +1,50 @@
+-- Schema of the first release; IF NOT EXISTS adopts databases created before migrations
+CREATE TABLE IF NOT EXISTS blocked_ips (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip TEXT NOT NULL,
+    reason TEXT,
+    score INTEGER DEFAULT 0,
+    alert_count INTEGER DEFAULT 0,
+    severity_score INTEGER DEFAULT 0,
+    unique_ports INTEGER DEFAULT 0,
+    unique_protos INTEGER DEFAULT 0,
+    unique_flows INTEGER DEFAULT 0,
+    categories TEXT,
+    details TEXT,
+    timestamp INTEGER DEFAULT (strftime('%s', 'now')),
+    unblock_time INTEGER,
+    status TEXT DEFAULT 'blocked' CHECK(status IN ('blocked', 'unblocked'))
+);
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip TEXT NOT NULL,
+    sid INTEGER,
+    message TEXT,
+    timestamp INTEGER DEFAULT (strftime('%s', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS whitelist (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip TEXT NOT NULL UNIQUE,
+    description TEXT,
+    added_at INTEGER DEFAULT (strftime('%s', 'now')),
+    removed_at INTEGER DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS activity_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    details TEXT,
+    extra TEXT,
+    timestamp INTEGER DEFAULT (strftime('%s', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_blocked_ips_ip ON blocked_ips(ip);
+CREATE INDEX IF NOT EXISTS idx_blocked_ips_status ON blocked_ips(status);
+CREATE INDEX IF NOT EXISTS idx_alerts_ip ON alerts(ip);
+CREATE INDEX IF NOT EXISTS idx_alerts_timestamp ON alerts(timestamp);
+CREATE INDEX IF NOT EXISTS idx_activity_type ON activity_log(type);
+CREATE INDEX IF NOT EXISTS idx_activity_ip ON activity_log(ip);
+CREATE INDEX IF NOT EXISTS idx_activity_timestamp ON activity_log(timestamp);
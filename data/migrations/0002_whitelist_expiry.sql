@@ -0,0 +1,2 @@
+-- Time-limited whitelist entries, NULL = never expires
+ALTER TABLE whitelist ADD COLUMN expires_at INTEGER DEFAULT NULL;
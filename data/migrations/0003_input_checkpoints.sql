@@ -0,0 +1,7 @@
+-- Read positions of file inputs
+CREATE TABLE IF NOT EXISTS input_checkpoints (
+    name TEXT PRIMARY KEY,
+    inode INTEGER NOT NULL DEFAULT 0,
+    offset INTEGER NOT NULL DEFAULT 0,
+    updated_at INTEGER DEFAULT (strftime('%s', 'now'))
+);
@@ -0,0 +1,2 @@
+-- Input that raised the alert: suricata, snort, auth_log, nginx or an ingest source
+ALTER TABLE alerts ADD COLUMN source TEXT DEFAULT 'suricata';
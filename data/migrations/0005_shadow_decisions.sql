@@ -0,0 +1,12 @@
+-- Decisions of shadow policies, never enforced
+CREATE TABLE IF NOT EXISTS shadow_decisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    policy TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    score INTEGER DEFAULT 0,
+    reason TEXT,
+    details TEXT,
+    timestamp INTEGER DEFAULT (strftime('%s', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_policy_timestamp ON shadow_decisions(policy, timestamp);
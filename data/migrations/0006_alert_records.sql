@@ -0,0 +1,8 @@
+-- Complete alert records; older rows keep NULLs
+ALTER TABLE alerts ADD COLUMN src_port INTEGER;
+ALTER TABLE alerts ADD COLUMN dest_ip TEXT;
+ALTER TABLE alerts ADD COLUMN dest_port INTEGER;
+ALTER TABLE alerts ADD COLUMN proto TEXT;
+ALTER TABLE alerts ADD COLUMN severity INTEGER;
+ALTER TABLE alerts ADD COLUMN category TEXT;
+ALTER TABLE alerts ADD COLUMN flow_id INTEGER;
@@ -0,0 +1,22 @@
+-- Alert and block counts that outlive purged raw rows; hours are unix
+-- seconds (local hour start), days are local dates
+CREATE TABLE IF NOT EXISTS rollups_hourly (
+    start INTEGER PRIMARY KEY,
+    alerts INTEGER NOT NULL DEFAULT 0,
+    blocks INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS rollups_daily (
+    day TEXT PRIMARY KEY,
+    alerts INTEGER NOT NULL DEFAULT 0,
+    blocks INTEGER NOT NULL DEFAULT 0
+);
+
+-- Highest row id of each source table already counted in rollups_hourly
+CREATE TABLE IF NOT EXISTS rollup_state (
+    source TEXT PRIMARY KEY,
+    last_id INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_blocked_ips_timestamp ON blocked_ips(timestamp);
+CREATE INDEX IF NOT EXISTS idx_shadow_timestamp ON shadow_decisions(timestamp);
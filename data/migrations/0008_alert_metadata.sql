@@ -0,0 +1,6 @@
+-- Flow metadata next to the alert: HTTP Host, TLS SNI and flow byte counts
+ALTER TABLE alerts ADD COLUMN app_proto TEXT;
+ALTER TABLE alerts ADD COLUMN hostname TEXT;
+ALTER TABLE alerts ADD COLUMN sni TEXT;
+ALTER TABLE alerts ADD COLUMN bytes_toserver INTEGER;
+ALTER TABLE alerts ADD COLUMN bytes_toclient INTEGER;
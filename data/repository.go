@@ -1,31 +1,50 @@
 package data
 
+import "context"
+
+// Every call takes the caller's context: API requests cancel with the client,
+// the alert path runs under database.timeout, background jobs stop on shutdown
 type Repository interface {
-	AddAlert(ip string, sid int, message string) error
-	GetAlertsByIP(ip string, limit int) ([]AlertDetails, error)
-	GetRecentAlerts(limit int) ([]AlertDetails, error)
-	GetAlertBuckets(days int) ([]TimeBucket, error)
-
-	AddBlocked(ip, reason string, score, alertCount, severityScore,
-		uniquePorts, uniqueProtos, uniqueFlows int, categories, details string) error
-	GetBlocked() ([]BlockedIPDetails, error)
-	GetBlockedByIP(ip string) ([]BlockedIPDetails, error)
-	UnblockIP(ip string) error
-	IsBlocked(ip string) (bool, error)
-	GetBlockBuckets(days int) ([]TimeBucket, error)
-
-	AddToWhitelist(ip, description string) error
-	RemoveFromWhitelist(ip string) error
-	IsWhitelisted(ip string) (bool, error)
-	GetWhitelistDetails() ([]WhitelistDetails, error)
-
-	GetStats() (*Stats, error)
-	GetHourlyAlerts(days int) ([]HourlyData, error)
-	GetTopIPs(limit int) ([]TopIP, error)
-	GetAlertCategories(days int) ([]Category, error)
-
-	GetActivity(search string, typeFilter string, limit int) ([]ActivityEntry, error)
-	LogActivity(activityType, ip, details, extra string) error
+	AddAlert(ctx context.Context, a AlertRecord) error
+	AddAlerts(ctx context.Context, alerts []AlertRecord) error
+	ImportAlerts(ctx context.Context, alerts []AlertRecord) error
+	GetAlertsByIP(ctx context.Context, ip string, limit int) ([]AlertDetails, error)
+	GetRecentAlerts(ctx context.Context, limit int) ([]AlertDetails, error)
+	ScanAlerts(ctx context.Context, from, to int64, fn func(AlertDetails) error) error
+	GetAlertBuckets(ctx context.Context, days int) ([]TimeBucket, error)
+
+	AddBlocked(ctx context.Context, ip, reason string, score, alertCount, severityScore,
+		uniquePorts, uniqueProtos, uniqueFlows int, categories, details string, unblockTime int64) error
+	GetBlocked(ctx context.Context) ([]BlockedIPDetails, error)
+	GetExpiredBlocks(ctx context.Context, now int64) ([]BlockedIPDetails, error)
+	GetBlockedByIP(ctx context.Context, ip string) ([]BlockedIPDetails, error)
+	GetBlocksBetween(ctx context.Context, from, to int64) ([]BlockedIPDetails, error)
+	UnblockIP(ctx context.Context, ip string) error
+	IsBlocked(ctx context.Context, ip string) (bool, error)
+	GetBlockBuckets(ctx context.Context, days int) ([]TimeBucket, error)
+
+	AddToWhitelist(ctx context.Context, ip, description string, expiresAt int64) error
+	RemoveFromWhitelist(ctx context.Context, ip string) error
+	ExpireWhitelist(ctx context.Context, now int64) ([]string, error)
+	IsWhitelisted(ctx context.Context, ip string) (bool, error)
+	GetWhitelistDetails(ctx context.Context) ([]WhitelistDetails, error)
+
+	GetStats(ctx context.Context) (*Stats, error)
+	GetHourlyAlerts(ctx context.Context, days int) ([]HourlyData, error)
+	GetTopIPs(ctx context.Context, limit int) ([]TopIP, error)
+	GetAlertCategories(ctx context.Context, days int) ([]Category, error)
+
+	GetActivity(ctx context.Context, search string, typeFilter string, limit int) ([]ActivityEntry, error)
+	LogActivity(ctx context.Context, activityType, ip, details, extra string) error
+
+	AddShadowDecision(ctx context.Context, d ShadowDecision) error
+	GetShadowSummary(ctx context.Context, policies []string, from, to int64) ([]ShadowSummary, error)
+
+	Rollup(ctx context.Context, hourlyBefore int64) error
+	Purge(ctx context.Context, table string, before int64, limit int) (int64, error)
+
+	GetCheckpoint(ctx context.Context, name string) (Checkpoint, error)
+	SaveCheckpoint(ctx context.Context, cp Checkpoint) error
 
 	Close() error
 }
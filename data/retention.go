@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Purge targets and the rows that may go: blocks still in force are never
+// purged, alerts/blocks only once counted in the rollups. would_block rows
+// of activity_log are a target of their own, GetShadowSummary reads them
+// next to shadow_decisions.
+var purgeable = map[string]struct{ table, cond string }{
+	"alerts":           {"alerts", `timestamp < ? AND id <= ` + rollupMark("alerts")},
+	"activity_log":     {"activity_log", `timestamp < ? AND type != 'would_block'`},
+	"would_block":      {"activity_log", `timestamp < ? AND type = 'would_block'`},
+	"blocked_ips":      {"blocked_ips", `timestamp < ? AND status = 'unblocked' AND id <= ` + rollupMark("blocked_ips")},
+	"shadow_decisions": {"shadow_decisions", `timestamp < ?`},
+}
+
+// Source table → rollup column
+var rolledUp = []struct{ table, column string }{
+	{"alerts", "alerts"},
+	{"blocked_ips", "blocks"},
+}
+
+// Highest id of table already counted in the rollups, as a subquery
+func rollupMark(table string) string {
+	return fmt.Sprintf(`(SELECT COALESCE(MAX(last_id), 0) FROM rollup_state WHERE source = '%s')`, table)
+}
+
+// Counting rows added since the last run into rollups_hourly, then folding
+// hours that started before hourlyBefore (unix seconds, 0 = none) into
+// rollups_daily. Rows are picked by id, so alerts imported with old
+// timestamps are counted too. Hours are local, so with a +05:30 offset an
+// hour starts at :30 UTC and never straddles a local day.
+func (s *DbManager) Rollup(ctx context.Context, hourlyBefore int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range rolledUp {
+		var from, to int64
+		err := tx.QueryRowContext(ctx, `SELECT `+rollupMark(r.table)+`, (SELECT COALESCE(MAX(id), 0) FROM `+r.table+`)`).Scan(&from, &to)
+		if err != nil {
+			return err
+		}
+		if to <= from {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+            INSERT INTO rollups_hourly (start, %[1]s)
+            SELECT timestamp - CAST(strftime('%%s', timestamp, 'unixepoch', 'localtime') AS INTEGER) %% 3600, COUNT(*)
+            FROM %[2]s
+            WHERE id > ? AND id <= ?
+            GROUP BY 1
+            ON CONFLICT(start) DO UPDATE SET %[1]s = %[1]s + excluded.%[1]s`, r.column, r.table), from, to)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO rollup_state (source, last_id) VALUES (?, ?)
+            ON CONFLICT(source) DO UPDATE SET last_id = excluded.last_id`, r.table, to)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hourlyBefore > 0 {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO rollups_daily (day, alerts, blocks)
+            SELECT date(start, 'unixepoch', 'localtime'), SUM(alerts), SUM(blocks)
+            FROM rollups_hourly
+            WHERE start < ?
+            GROUP BY 1
+            ON CONFLICT(day) DO UPDATE SET
+                alerts = alerts + excluded.alerts,
+                blocks = blocks + excluded.blocks`, hourlyBefore)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM rollups_hourly WHERE start < ?`, hourlyBefore); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Deleting at most limit rows of a purge target older than before (unix
+// seconds); returns how many went, fewer than limit means the target is done
+func (s *DbManager) Purge(ctx context.Context, target string, before int64, limit int) (int64, error) {
+	p, ok := purgeable[target]
+	if !ok {
+		return 0, fmt.Errorf("no retention for table %q", target)
+	}
+
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+        DELETE FROM %[1]s WHERE id IN (
+            SELECT id FROM %[1]s WHERE %[2]s ORDER BY id LIMIT ?
+        )`, p.table, p.cond), before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Counts per bucket (strftime format of the local time) of rows newer than
+// cutoff, from raw rows not yet rolled up, rollups_hourly and, for daily
+// buckets, rollups_daily
+func (s *DbManager) rollupBuckets(ctx context.Context, table, column, format string, cutoff int64) (*sql.Rows, error) {
+	var daily string
+	if format == "%Y-%m-%d" {
+		daily = fmt.Sprintf(`
+                SELECT day AS bucket, %s AS n
+                FROM rollups_daily
+                WHERE day >= date(%d, 'unixepoch', 'localtime')
+                UNION ALL`, column, cutoff)
+	}
+
+	return s.db.QueryContext(ctx, fmt.Sprintf(`
+        SELECT bucket, SUM(n) AS count
+        FROM (%[1]s
+            SELECT strftime('%[2]s', datetime(start, 'unixepoch', 'localtime')) AS bucket, %[3]s AS n
+            FROM rollups_hourly
+            WHERE start > ? - 3600
+            UNION ALL
+            SELECT strftime('%[2]s', datetime(timestamp, 'unixepoch', 'localtime')), 1
+            FROM %[4]s
+            WHERE id > %[5]s AND timestamp > ?
+        )
+        GROUP BY bucket
+        HAVING count > 0
+        ORDER BY bucket ASC`, daily, format, column, table, rollupMark(table)), cutoff, cutoff)
+}
@@ -0,0 +1,226 @@
+package data
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 2026-01-02 03:00:00 UTC, an hour start
+const testHour int64 = 1767322800
+
+func testDB(t *testing.T) *DbManager {
+	t.Helper()
+	repo, err := New(filepath.Join(t.TempDir(), "firefighter.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo.(*DbManager)
+}
+
+func mustExec(t *testing.T, s *DbManager, query string, args ...any) {
+	t.Helper()
+	if _, err := s.db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func addAlertAt(t *testing.T, s *DbManager, ts int64) {
+	mustExec(t, s, `INSERT INTO alerts (ip, sid, message, timestamp) VALUES ('198.51.100.7', 1, 'x', ?)`, ts)
+}
+
+func addBlockAt(t *testing.T, s *DbManager, ts int64, status string) {
+	mustExec(t, s, `INSERT INTO blocked_ips (ip, reason, timestamp, status) VALUES ('198.51.100.7', 'x', ?, ?)`, ts, status)
+}
+
+type hourCount struct {
+	start          int64
+	alerts, blocks int
+}
+
+func hourly(t *testing.T, s *DbManager) []hourCount {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT start, alerts, blocks FROM rollups_hourly ORDER BY start`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []hourCount
+	for rows.Next() {
+		var h hourCount
+		if err := rows.Scan(&h.start, &h.alerts, &h.blocks); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+func TestRollup(t *testing.T) {
+	ctx := context.Background()
+	s := testDB(t)
+	testHour := localHour(testHour)
+
+	addAlertAt(t, s, testHour+10)
+	addAlertAt(t, s, testHour+3599)
+	addAlertAt(t, s, testHour+3600)
+	addBlockAt(t, s, testHour+20, "blocked")
+	if err := s.Rollup(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := []hourCount{{testHour, 2, 1}, {testHour + 3600, 1, 0}}
+	if got := hourly(t, s); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after first rollup %+v, want %+v", got, want)
+	}
+
+	// Nothing new: counts stay
+	if err := s.Rollup(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := hourly(t, s); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after empty rollup %+v, want %+v", got, want)
+	}
+
+	// Late import into an hour already counted
+	addAlertAt(t, s, testHour+30)
+	if err := s.Rollup(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	want = []hourCount{{testHour, 3, 1}, {testHour + 3600, 1, 0}}
+	if got := hourly(t, s); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after late import %+v, want %+v", got, want)
+	}
+
+	// The first hour moves to the daily table
+	if err := s.Rollup(ctx, testHour+3600); err != nil {
+		t.Fatal(err)
+	}
+	want = []hourCount{{testHour + 3600, 1, 0}}
+	if got := hourly(t, s); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after daily fold %+v, want %+v", got, want)
+	}
+	var alerts, blocks int
+	day := time.Unix(testHour, 0).Format(time.DateOnly)
+	if err := s.db.QueryRow(`SELECT alerts, blocks FROM rollups_daily WHERE day = ?`, day).Scan(&alerts, &blocks); err != nil {
+		t.Fatal(err)
+	}
+	if alerts != 3 || blocks != 1 {
+		t.Errorf("daily %s alerts %d blocks %d, want 3 1", day, alerts, blocks)
+	}
+}
+
+// Start of the local hour of ts; TZ=Asia/Kolkata runs these with hours starting at :30 UTC
+func localHour(ts int64) int64 {
+	t := time.Unix(ts, 0)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local).Unix()
+}
+
+func TestRollupLocalHours(t *testing.T) {
+	s := testDB(t)
+	stamps := []int64{testHour, testHour + 1799, testHour + 1800, testHour + 3599, testHour + 5400}
+	counts := map[int64]int{}
+	for _, ts := range stamps {
+		addAlertAt(t, s, ts)
+		counts[localHour(ts)]++
+	}
+	if err := s.Rollup(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	got := hourly(t, s)
+	if len(got) != len(counts) {
+		t.Fatalf("hours %+v, want %v", got, counts)
+	}
+	for _, h := range got {
+		if h.alerts != counts[h.start] {
+			t.Errorf("hour %s: %d alerts, want %d", time.Unix(h.start, 0), h.alerts, counts[h.start])
+		}
+	}
+}
+
+func TestPurge(t *testing.T) {
+	const old, recent = testHour, testHour + 7200
+	cutoff := testHour + 3600
+
+	// Fixture: some rows counted in the rollups, some added after
+	setup := func(t *testing.T) *DbManager {
+		s := testDB(t)
+		addAlertAt(t, s, old)
+		addAlertAt(t, s, old)
+		addAlertAt(t, s, recent)
+		addBlockAt(t, s, old, "unblocked")
+		addBlockAt(t, s, old, "blocked")
+		if err := s.Rollup(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+		addAlertAt(t, s, old)
+		addBlockAt(t, s, old, "unblocked")
+
+		for _, typ := range []string{"login", "block", "would_block"} {
+			mustExec(t, s, `INSERT INTO activity_log (type, ip, details, timestamp) VALUES (?, '198.51.100.7', '', ?)`, typ, old)
+		}
+		mustExec(t, s, `INSERT INTO activity_log (type, ip, details, timestamp) VALUES ('login', '198.51.100.7', '', ?)`, recent)
+		mustExec(t, s, `INSERT INTO shadow_decisions (policy, ip, timestamp) VALUES ('p', '198.51.100.7', ?)`, old)
+		mustExec(t, s, `INSERT INTO shadow_decisions (policy, ip, timestamp) VALUES ('p', '198.51.100.7', ?)`, recent)
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		before  int64
+		limit   int
+		deleted int64
+		wantErr bool
+	}{
+		{name: "alerts only once rolled up", target: "alerts", before: cutoff, limit: 100, deleted: 2},
+		{name: "limit", target: "alerts", before: cutoff, limit: 1, deleted: 1},
+		{name: "nothing older than before", target: "alerts", before: old, limit: 100, deleted: 0},
+		{name: "blocks only unblocked and rolled up", target: "blocked_ips", before: cutoff, limit: 100, deleted: 1},
+		{name: "activity keeps would_block", target: "activity_log", before: cutoff, limit: 100, deleted: 2},
+		{name: "would_block alone", target: "would_block", before: cutoff, limit: 100, deleted: 1},
+		{name: "shadow decisions", target: "shadow_decisions", before: cutoff, limit: 100, deleted: 1},
+		{name: "unknown target", target: "whitelist", before: cutoff, limit: 100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := setup(t)
+			deleted, err := s.Purge(context.Background(), tt.target, tt.before, tt.limit)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Purge() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != tt.deleted {
+				t.Errorf("Purge() deleted %d, want %d", deleted, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestPurgeKeepsWouldBlock(t *testing.T) {
+	ctx := context.Background()
+	s := testDB(t)
+	for _, typ := range []string{"block", "would_block", "unblock"} {
+		mustExec(t, s, `INSERT INTO activity_log (type, ip, details, timestamp) VALUES (?, '198.51.100.7', '', ?)`, typ, testHour)
+	}
+
+	if _, err := s.Purge(ctx, "activity_log", testHour+1, 100); err != nil {
+		t.Fatal(err)
+	}
+	var typ string
+	if err := s.db.QueryRow(`SELECT group_concat(type) FROM activity_log`).Scan(&typ); err != nil {
+		t.Fatal(err)
+	}
+	if typ != "would_block" {
+		t.Errorf("activity_log left with %q, want would_block", typ)
+	}
+}
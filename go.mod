@@ -6,6 +6,7 @@ toolchain go1.24.6
 
 require (
 	github.com/gin-contrib/cors v1.7.6
+	github.com/goccy/go-yaml v1.18.0
 	github.com/gorilla/websocket v1.5.3
 	modernc.org/sqlite v1.39.1
 )
@@ -20,7 +21,6 @@ require (
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.27.0 // indirect
 	github.com/goccy/go-json v0.10.5 // indirect
-	github.com/goccy/go-yaml v1.18.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.3.0 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect